@@ -0,0 +1,81 @@
+package chimp
+
+// eventSlab allocates events in blocks to reduce the number of heap
+// allocations when producing events at a high rate. A block is garbage
+// collected when none of its events are referenced. An event slab must only be
+// used by one goroutine.
+type eventSlab struct {
+	pens     []EventPositionPen
+	fingers  []EventPositionFinger
+	buttons  []EventButton
+	rings    []EventPadRing
+	strips   []EventPadStrip
+	contacts []Contact
+}
+
+const eventSlabSize = 64
+
+func (slab *eventSlab) positionPen(e EventPositionPen) *EventPositionPen {
+	if len(slab.pens) == 0 {
+		slab.pens = make([]EventPositionPen, eventSlabSize)
+	}
+	p := &slab.pens[0]
+	slab.pens = slab.pens[1:]
+	*p = e
+	return p
+}
+
+func (slab *eventSlab) positionFinger(e EventPositionFinger) *EventPositionFinger {
+	if len(slab.fingers) == 0 {
+		slab.fingers = make([]EventPositionFinger, eventSlabSize)
+	}
+	p := &slab.fingers[0]
+	slab.fingers = slab.fingers[1:]
+	*p = e
+	return p
+}
+
+func (slab *eventSlab) button(e EventButton) *EventButton {
+	if len(slab.buttons) == 0 {
+		slab.buttons = make([]EventButton, eventSlabSize)
+	}
+	p := &slab.buttons[0]
+	slab.buttons = slab.buttons[1:]
+	*p = e
+	return p
+}
+
+func (slab *eventSlab) padRing(e EventPadRing) *EventPadRing {
+	if len(slab.rings) == 0 {
+		slab.rings = make([]EventPadRing, eventSlabSize)
+	}
+	p := &slab.rings[0]
+	slab.rings = slab.rings[1:]
+	*p = e
+	return p
+}
+
+func (slab *eventSlab) padStrip(e EventPadStrip) *EventPadStrip {
+	if len(slab.strips) == 0 {
+		slab.strips = make([]EventPadStrip, eventSlabSize)
+	}
+	p := &slab.strips[0]
+	slab.strips = slab.strips[1:]
+	*p = e
+	return p
+}
+
+// contactSlice returns a non-nil slice of n contacts. The capacity is limited
+// to n so that appending to it never overwrites contacts of other events.
+func (slab *eventSlab) contactSlice(n int) []Contact {
+	if n > len(slab.contacts) {
+		size := eventSlabSize * 4
+		if n > size {
+			size = n
+		}
+		slab.contacts = make([]Contact, size)
+	}
+	s := slab.contacts[:n:n]
+	slab.contacts = slab.contacts[n:]
+	return s
+}
@@ -0,0 +1,243 @@
+package chimp
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventButtonGesture is generated by ButtonGestureDetector for recognized
+// button gestures.
+type EventButtonGesture struct {
+	Timestamp time.Time // Time when the gesture was completed.
+
+	// Time when the event that the gesture was recognized from was read from
+	// device, same clock as Timestamp. It's zero for long-presses recognized
+	// by Tick.
+	Delivered time.Time
+
+	// Time of the device clock when the gesture was completed, see
+	// EventButton.
+	DeviceTimestamp    time.Duration
+	HasDeviceTimestamp bool
+
+	Gesture ButtonGesture // Recognized gesture.
+	Buttons []Button      // Button of the gesture, or buttons of a chord in order of press.
+}
+
+func (e *EventButtonGesture) Time() time.Time {
+	return e.Timestamp
+}
+
+func (e *EventButtonGesture) String() string {
+	return fmt.Sprintf(fmtEventButtonGesture, e.Timestamp, e.Gesture, e.Buttons)
+}
+
+const fmtEventButtonGesture = `EventButtonGesture: {
+    Time:     %s
+    Gesture:  %s
+    Buttons:  %v
+}`
+
+// ButtonGesture is an enumeration of button gestures.
+type ButtonGesture uint32
+
+//go:generate stringer -type=ButtonGesture -trimprefix=ButtonGesture
+
+const (
+	ButtonGestureClick       ButtonGesture = iota // Button pressed and released
+	ButtonGestureDoubleClick                      // Button clicked twice in a row
+	ButtonGestureLongPress                        // Button held down
+	ButtonGestureChord                            // Buttons pressed at the same time
+)
+
+// ButtonGestureConfig holds thresholds used to recognize button gestures.
+type ButtonGestureConfig struct {
+	// Longest time from the release of a click until the press of the next
+	// click for them to be a double-click.
+	DoubleClickInterval time.Duration
+
+	LongPressDuration time.Duration // Shortest press recognized as a long-press.
+	ChordMaxInterval  time.Duration // Longest time between the presses of chord buttons.
+
+	// Buttons that gestures are recognized for. Nil selects all buttons except
+	// the pen tip, the eraser and touch, which are used for positioning.
+	Buttons []Button
+}
+
+// DefaultButtonGestureConfig is a reasonable button gesture configuration.
+var DefaultButtonGestureConfig = ButtonGestureConfig{
+	DoubleClickInterval: 300 * time.Millisecond,
+	LongPressDuration:   500 * time.Millisecond,
+	ChordMaxInterval:    100 * time.Millisecond,
+}
+
+// ButtonGestureDetector is an event filter that recognizes clicks,
+// double-clicks, long-presses and chords from button events of pens, pads and
+// mice. Recognized gestures are emitted as EventButtonGesture after the event
+// that completed them. Button events are passed through unchanged.
+//
+// Long-presses are completed when the button has been held for
+// LongPressDuration, which is also their timestamp. They are recognized when
+// the next event arrives and emitted before that event, or by Tick.
+//
+// A press that is released before it's a long-press is a click. Every click is
+// reported, the second of two clicks in a row is also reported as a
+// double-click. Buttons that are pressed within ChordMaxInterval of each other
+// form a chord, which is reported when it's formed and every time it's
+// extended by another button. Buttons of a chord are not reported as clicks
+// or long-presses.
+//
+// Only event timestamps are used, so that recorded events are recognized the
+// same way when replayed. Long-presses are recognized when events arrive, call
+// Tick periodically to recognize them while no events are generated.
+type ButtonGestureDetector struct {
+	config  ButtonGestureConfig
+	buttons map[Button]*buttonGestureState
+	pressed []Button // Pressed buttons in order of press.
+}
+
+// buttonGestureState is the gesture state of one button.
+type buttonGestureState struct {
+	pressed     bool
+	pressTime   time.Time
+	pressDevice time.Duration // Device timestamp of press.
+	hasDevice   bool          // Device timestamp of press reported.
+	chord       bool          // Part of a chord since it was pressed.
+	longPress   bool          // Long-press reported since it was pressed.
+	clicks      int           // Clicks in a row, reset by double-clicks.
+	releaseTime time.Time     // Time of release of the last click.
+}
+
+// NewButtonGestureDetector creates a button gesture detector.
+func NewButtonGestureDetector(config ButtonGestureConfig) *ButtonGestureDetector {
+	d := &ButtonGestureDetector{
+		config:  config,
+		buttons: map[Button]*buttonGestureState{},
+	}
+	if config.Buttons == nil {
+		for _, button := range []Button{ButtonPen1, ButtonPen2, ButtonPen3, ButtonLeft, ButtonRight, ButtonForward, ButtonBack} {
+			d.buttons[button] = &buttonGestureState{}
+		}
+	}
+	for _, button := range config.Buttons {
+		d.buttons[button] = &buttonGestureState{}
+	}
+	return d
+}
+
+func (d *ButtonGestureDetector) Filter(event Event) []Event {
+	events := append(d.checkLongPress(event.Time(), eventDeliveryTime(event), nil), event)
+	if e, ok := event.(*EventButton); ok {
+		if state, ok := d.buttons[e.Button]; ok {
+			if pressed := e.Pressure > 0; pressed != state.pressed {
+				if pressed {
+					events = d.press(e, state, events)
+				} else {
+					events = d.release(e, state, events)
+				}
+			}
+		}
+	}
+	return events
+}
+
+// Tick recognizes long-presses. It should be called periodically with a time
+// comparable to event timestamps.
+func (d *ButtonGestureDetector) Tick(now time.Time) []Event {
+	return d.checkLongPress(now, time.Time{}, nil)
+}
+
+func (d *ButtonGestureDetector) press(e *EventButton, state *buttonGestureState, events []Event) []Event {
+	timestamp, button := e.Timestamp, e.Button
+	state.pressed = true
+	state.pressTime = timestamp
+	state.pressDevice = e.DeviceTimestamp
+	state.hasDevice = e.HasDeviceTimestamp
+	state.chord = false
+	state.longPress = false
+	if state.clicks > 0 && timestamp.Sub(state.releaseTime) > d.config.DoubleClickInterval {
+		state.clicks = 0
+	}
+
+	// Buttons pressed recently, that are not already held as long-presses,
+	// form a chord with this one.
+	var chord []Button
+	for _, b := range d.pressed {
+		s := d.buttons[b]
+		if !s.longPress && timestamp.Sub(s.pressTime) <= d.config.ChordMaxInterval {
+			chord = append(chord, b)
+		}
+	}
+	d.pressed = append(d.pressed, button)
+	if len(chord) == 0 {
+		return events
+	}
+	chord = append(chord, button)
+	for _, b := range chord {
+		s := d.buttons[b]
+		s.chord = true
+		s.clicks = 0
+	}
+	return append(events, newEventButtonGesture(e, ButtonGestureChord, chord))
+}
+
+func (d *ButtonGestureDetector) release(e *EventButton, state *buttonGestureState, events []Event) []Event {
+	timestamp, button := e.Timestamp, e.Button
+	state.pressed = false
+	for i, b := range d.pressed {
+		if b == button {
+			d.pressed = append(d.pressed[:i], d.pressed[i+1:]...)
+			break
+		}
+	}
+	if state.chord || state.longPress {
+		return events
+	}
+
+	events = append(events, newEventButtonGesture(e, ButtonGestureClick, []Button{button}))
+	state.clicks++
+	state.releaseTime = timestamp
+	if state.clicks == 2 {
+		state.clicks = 0
+		events = append(events, newEventButtonGesture(e, ButtonGestureDoubleClick, []Button{button}))
+	}
+	return events
+}
+
+// checkLongPress reports buttons that have been held long enough at time now.
+// It's called with the time of every event before the event is handled, so that
+// a press released after LongPressDuration is a long-press and not a click.
+// Delivered is the delivery time of the event, if any.
+func (d *ButtonGestureDetector) checkLongPress(now, delivered time.Time, events []Event) []Event {
+	for _, button := range d.pressed {
+		state := d.buttons[button]
+		if !state.chord && !state.longPress && now.Sub(state.pressTime) >= d.config.LongPressDuration {
+			state.longPress = true
+			state.clicks = 0
+			e := &EventButtonGesture{
+				Timestamp: state.pressTime.Add(d.config.LongPressDuration),
+				Delivered: delivered,
+				Gesture:   ButtonGestureLongPress,
+				Buttons:   []Button{button},
+			}
+			if state.hasDevice {
+				e.DeviceTimestamp = state.pressDevice + d.config.LongPressDuration
+				e.HasDeviceTimestamp = true
+			}
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// newEventButtonGesture creates a gesture completed by button event e.
+func newEventButtonGesture(e *EventButton, gesture ButtonGesture, buttons []Button) *EventButtonGesture {
+	return &EventButtonGesture{
+		Timestamp:          e.Timestamp,
+		Delivered:          e.Delivered,
+		DeviceTimestamp:    e.DeviceTimestamp,
+		HasDeviceTimestamp: e.HasDeviceTimestamp,
+		Gesture:            gesture,
+		Buttons:            buttons,
+	}
+}
@@ -0,0 +1,94 @@
+package chimp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestButtonGestureLongPress(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	config := DefaultButtonGestureConfig
+	d := NewButtonGestureDetector(config)
+
+	// A device timestamp of zero is a reported time.
+	press := &EventButton{Timestamp: start, HasDeviceTimestamp: true, Button: ButtonPen1, Pressure: 1}
+	if events := d.Filter(press); len(events) != 1 || events[0] != press {
+		t.Fatalf("press gave %v", events)
+	}
+
+	// The long-press is recognized late, from the release, and is emitted
+	// before it with the time it was completed.
+	release := &EventButton{
+		Timestamp: start.Add(2 * config.LongPressDuration),
+		Delivered: start.Add(2*config.LongPressDuration + time.Millisecond),
+		Button:    ButtonPen1,
+	}
+	events := d.Filter(release)
+	if len(events) != 2 || events[1] != release {
+		t.Fatalf("release gave %v", events)
+	}
+	e, ok := events[0].(*EventButtonGesture)
+	if !ok || e.Gesture != ButtonGestureLongPress {
+		t.Fatalf("got %v, want long-press", events[0])
+	}
+	if want := start.Add(config.LongPressDuration); !e.Timestamp.Equal(want) {
+		t.Errorf("long-press at %s, want %s", e.Timestamp, want)
+	}
+	if want := config.LongPressDuration; e.DeviceTimestamp != want || !e.HasDeviceTimestamp {
+		t.Errorf("long-press device timestamp %s, %t, want %s", e.DeviceTimestamp, e.HasDeviceTimestamp, want)
+	}
+	if !e.Delivered.Equal(release.Delivered) {
+		t.Errorf("long-press delivered %s, want %s", e.Delivered, release.Delivered)
+	}
+}
+
+func TestButtonGestureTick(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	config := DefaultButtonGestureConfig
+	d := NewButtonGestureDetector(config)
+	d.Filter(&EventButton{Timestamp: start, Button: ButtonLeft, Pressure: 1})
+
+	if events := d.Tick(start.Add(config.LongPressDuration / 2)); len(events) != 0 {
+		t.Fatalf("early tick gave %v", events)
+	}
+	events := d.Tick(start.Add(config.LongPressDuration * 3 / 2))
+	if len(events) != 1 {
+		t.Fatalf("tick gave %v", events)
+	}
+	if e := events[0].(*EventButtonGesture); !e.Timestamp.Equal(start.Add(config.LongPressDuration)) || !e.Delivered.IsZero() {
+		t.Errorf("tick gave %v delivered %s", e, e.Delivered)
+	}
+	if events := d.Filter(&EventButton{Timestamp: start.Add(time.Second), Button: ButtonLeft}); len(events) != 1 {
+		t.Errorf("release after long-press gave %v", events)
+	}
+}
+
+func TestButtonGestureClicks(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	d := NewButtonGestureDetector(DefaultButtonGestureConfig)
+	var gestures []ButtonGesture
+	for _, ms := range []int{0, 50, 150, 200} {
+		release := ms == 50 || ms == 200
+		e := &EventButton{Timestamp: start.Add(time.Duration(ms) * time.Millisecond), Button: ButtonRight}
+		if !release {
+			e.Pressure = 1
+		}
+		for _, event := range d.Filter(e) {
+			if g, ok := event.(*EventButtonGesture); ok {
+				if !g.Timestamp.Equal(e.Timestamp) {
+					t.Errorf("gesture at %s, want %s", g.Timestamp, e.Timestamp)
+				}
+				gestures = append(gestures, g.Gesture)
+			}
+		}
+	}
+	want := []ButtonGesture{ButtonGestureClick, ButtonGestureClick, ButtonGestureDoubleClick}
+	if len(gestures) != len(want) {
+		t.Fatalf("got %v, want %v", gestures, want)
+	}
+	for i := range want {
+		if gestures[i] != want[i] {
+			t.Fatalf("got %v, want %v", gestures, want)
+		}
+	}
+}
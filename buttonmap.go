@@ -0,0 +1,335 @@
+package chimp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EventAction is generated by ButtonMapper for buttons bound to application
+// actions. It replaces the EventButton of the bound button.
+type EventAction struct {
+	Timestamp time.Time // Time when event was generated.
+	Delivered time.Time // Time when event was read from device, same clock as Timestamp.
+
+	// Time of the device clock when event was generated, see EventButton.
+	DeviceTimestamp    time.Duration
+	HasDeviceTimestamp bool
+
+	Action  string // Name of action.
+	Button  Button // Button bound to the action.
+	Pressed bool   // True when the button is pressed, false when it's released.
+}
+
+func (e *EventAction) Time() time.Time {
+	return e.Timestamp
+}
+
+func (e *EventAction) String() string {
+	return fmt.Sprintf(fmtEventAction, e.Timestamp, e.Action, e.Button, e.Pressed)
+}
+
+const fmtEventAction = `EventAction: {
+    Time:     %s
+    Action:   %s
+    Button:   %s
+    Pressed:  %t
+}`
+
+// Tool is an enumeration of tools that button bindings can be limited to.
+type Tool uint32
+
+//go:generate stringer -type=Tool -trimprefix=Tool
+
+const (
+	ToolAny    Tool = iota // Any tool.
+	ToolPen                // Tip end of pen, see EventPositionPen.Tool.
+	ToolEraser             // Eraser end of pen.
+)
+
+// ButtonBindingKind is an enumeration of what a bound button is reported as.
+type ButtonBindingKind uint32
+
+const (
+	ButtonBindingButton   ButtonBindingKind = iota // Reported as another button.
+	ButtonBindingAction                            // Reported as an action.
+	ButtonBindingDisabled                          // Not reported.
+)
+
+// ModeAny is used for button bindings that apply in all modes.
+const ModeAny = -1
+
+// ButtonBinding binds a button to another button or an action, or disables
+// it, while a tool is active and the button mapper is in a mode. Use
+// RemapButton, BindButtonAction and DisableButton to create bindings that
+// apply to all tools in all modes.
+type ButtonBinding struct {
+	Button Button            // Button that is bound.
+	Tool   Tool              // Tool that must be active, ToolAny for all tools.
+	Mode   int               // Mode that the mapper must be in, ModeAny for all modes.
+	Kind   ButtonBindingKind // What the button is reported as.
+	To     Button            // Button reported instead, for ButtonBindingButton.
+	Action string            // Name of action, for ButtonBindingAction.
+}
+
+// RemapButton reports button as button to. Buttons are swapped by remapping
+// both of them.
+func RemapButton(button, to Button) ButtonBinding {
+	return ButtonBinding{Button: button, Mode: ModeAny, Kind: ButtonBindingButton, To: to}
+}
+
+// BindButtonAction reports button as action.
+func BindButtonAction(button Button, action string) ButtonBinding {
+	return ButtonBinding{Button: button, Mode: ModeAny, Kind: ButtonBindingAction, Action: action}
+}
+
+// DisableButton does not report button.
+func DisableButton(button Button) ButtonBinding {
+	return ButtonBinding{Button: button, Mode: ModeAny, Kind: ButtonBindingDisabled}
+}
+
+// ForTool returns the binding limited to tool.
+func (b ButtonBinding) ForTool(tool Tool) ButtonBinding {
+	b.Tool = tool
+	return b
+}
+
+// InMode returns the binding limited to mode.
+func (b ButtonBinding) InMode(mode int) ButtonBinding {
+	b.Mode = mode
+	return b
+}
+
+func (b *ButtonBinding) String() string {
+	var s string
+	switch b.Kind {
+	case ButtonBindingButton:
+		s = fmt.Sprintf("%s -> %s", b.Button, b.To)
+	case ButtonBindingAction:
+		s = fmt.Sprintf("%s -> action %q", b.Button, b.Action)
+	case ButtonBindingDisabled:
+		s = fmt.Sprintf("%s disabled", b.Button)
+	}
+	if b.Tool != ToolAny {
+		s += fmt.Sprintf(" with %s", b.Tool)
+	}
+	if b.Mode != ModeAny {
+		s += fmt.Sprintf(" in mode %d", b.Mode)
+	}
+	return s
+}
+
+// specificity of binding, more specific bindings take precedence.
+func (b *ButtonBinding) specificity() int {
+	n := 0
+	if b.Mode != ModeAny {
+		n += 2
+	}
+	if b.Tool != ToolAny {
+		n++
+	}
+	return n
+}
+
+// ButtonMapper is an event filter that remaps, disables or binds buttons to
+// actions. The binding of a button is selected by the active tool, which is
+// the end of the pen last seen in proximity, and the mode of the mapper. When
+// several bindings apply, a binding limited to a mode takes precedence over
+// one limited to a tool, which takes precedence over one that applies to all.
+// Later bindings take precedence over earlier ones that are equally specific.
+//
+// The mapper keeps one mode per pad mode group, which follows the
+// EventPadMode events of the group. A button in a group, as given by the
+// PadModes set with SetPadModes, is bound by the mode of its group. Other
+// buttons, and all buttons if no PadModes is set, are bound by the mode set
+// with SetMode. Devices with a configured mapper set their PadModes.
+//
+// A button that is pressed keeps its binding until released, even if the
+// tool, mode or bindings change in between. Buttons that are not bound are
+// passed through.
+//
+// Bindings and mode may be changed at any time from any goroutine.
+type ButtonMapper struct {
+	mu       sync.Mutex
+	bindings []ButtonBinding
+	mode     int   // Mode of buttons not in a mode group.
+	groups   []int // Mode of each mode group.
+	padModes *PadModes
+	tool     Tool
+	pressed  map[Button]*ButtonBinding // Binding of pressed buttons, nil if unbound.
+}
+
+// NewButtonMapper creates a button mapper with bindings in mode zero.
+func NewButtonMapper(bindings []ButtonBinding) *ButtonMapper {
+	return &ButtonMapper{
+		bindings: append([]ButtonBinding(nil), bindings...),
+		pressed:  map[Button]*ButtonBinding{},
+	}
+}
+
+// SetBindings replaces the bindings.
+func (m *ButtonMapper) SetBindings(bindings []ButtonBinding) {
+	m.mu.Lock()
+	m.bindings = append([]ButtonBinding(nil), bindings...)
+	m.mu.Unlock()
+}
+
+// Bindings returns the bindings.
+func (m *ButtonMapper) Bindings() []ButtonBinding {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]ButtonBinding(nil), m.bindings...)
+}
+
+// SetPadModes sets the mode groups that buttons belong to, nil if there are
+// none.
+func (m *ButtonMapper) SetPadModes(p *PadModes) {
+	m.mu.Lock()
+	m.padModes = p
+	m.mu.Unlock()
+}
+
+// SetMode sets the mode that selects bindings of buttons not in a mode group.
+func (m *ButtonMapper) SetMode(mode int) {
+	m.mu.Lock()
+	m.mode = mode
+	m.mu.Unlock()
+}
+
+// Mode returns the mode that selects bindings of buttons not in a mode group.
+func (m *ButtonMapper) Mode() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mode
+}
+
+// SetGroupMode sets the mode of mode group.
+func (m *ButtonMapper) SetGroupMode(group, mode int) {
+	m.mu.Lock()
+	m.setGroupMode(group, mode)
+	m.mu.Unlock()
+}
+
+// GroupMode returns the mode of mode group, zero until it has changed.
+func (m *ButtonMapper) GroupMode(group int) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.groupMode(group)
+}
+
+func (m *ButtonMapper) setGroupMode(group, mode int) {
+	if group < 0 {
+		return
+	}
+	for len(m.groups) <= group {
+		m.groups = append(m.groups, 0)
+	}
+	m.groups[group] = mode
+}
+
+func (m *ButtonMapper) groupMode(group int) int {
+	if group < 0 || group >= len(m.groups) {
+		return 0
+	}
+	return m.groups[group]
+}
+
+// buttonMode returns the mode that selects bindings of button.
+func (m *ButtonMapper) buttonMode(button Button) int {
+	if m.padModes != nil {
+		if group, ok := m.padModes.GroupOf(button); ok {
+			return m.groupMode(group)
+		}
+	}
+	return m.mode
+}
+
+func (m *ButtonMapper) Filter(event Event) []Event {
+	if event = m.mapEvent(event); event == nil {
+		return nil
+	}
+	return []Event{event}
+}
+
+// mapEvent maps one event. Nil is returned if the event is dropped.
+func (m *ButtonMapper) mapEvent(event Event) Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch e := event.(type) {
+	case *EventProximityPen:
+		if e.InProximity {
+			m.tool = penTool(e.Tool)
+		}
+	case *EventPositionPen:
+		m.tool = penTool(e.Tool)
+	case *EventPadMode:
+		m.setGroupMode(e.Group, e.Mode)
+	case *EventButton:
+		pressed := e.Pressure > 0
+		binding, wasPressed := m.pressed[e.Button]
+		if !wasPressed {
+			binding = m.binding(e.Button)
+		}
+		if pressed {
+			m.pressed[e.Button] = binding
+		} else {
+			delete(m.pressed, e.Button)
+		}
+		if binding == nil {
+			return event
+		}
+
+		switch binding.Kind {
+		case ButtonBindingButton:
+			e.Button = binding.To
+		case ButtonBindingAction:
+			// Pressure changes of pressed buttons are not actions.
+			if pressed == wasPressed {
+				return nil
+			}
+			return &EventAction{
+				Timestamp:          e.Timestamp,
+				Delivered:          e.Delivered,
+				DeviceTimestamp:    e.DeviceTimestamp,
+				HasDeviceTimestamp: e.HasDeviceTimestamp,
+				Action:             binding.Action,
+				Button:             e.Button,
+				Pressed:            pressed,
+			}
+		case ButtonBindingDisabled:
+			return nil
+		}
+	}
+	return event
+}
+
+// penTool returns the tool of the end of the pen reported as button.
+func penTool(button Button) Tool {
+	if button == ButtonPenEraser {
+		return ToolEraser
+	}
+	return ToolPen
+}
+
+// binding returns the binding of button in the current state or nil if it's
+// not bound.
+func (m *ButtonMapper) binding(button Button) *ButtonBinding {
+	var found *ButtonBinding
+	mode := m.buttonMode(button)
+	for i := range m.bindings {
+		b := &m.bindings[i]
+		if b.Button != button || b.Tool != ToolAny && b.Tool != m.tool || b.Mode != ModeAny && b.Mode != mode {
+			continue
+		}
+		if found == nil || b.specificity() >= found.specificity() {
+			found = b
+		}
+	}
+	if found != nil {
+		// Copy as bindings may be replaced while the button is pressed.
+		b := *found
+		found = &b
+	}
+	return found
+}
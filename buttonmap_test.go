@@ -0,0 +1,242 @@
+package chimp
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestButtonMapperGroupModes(t *testing.T) {
+	padModes := NewPadModes([]PadModeGroup{
+		{Modes: 2, Toggle: []Button{ButtonLeft}, Buttons: []Button{ButtonRight}},
+		{Modes: 2, Toggle: []Button{ButtonForward}, Buttons: []Button{ButtonBack}},
+	})
+	m := NewButtonMapper([]ButtonBinding{
+		BindButtonAction(ButtonRight, "left-1").InMode(1),
+		BindButtonAction(ButtonBack, "right-1").InMode(1),
+		BindButtonAction(ButtonPen1, "ungrouped-1").InMode(1),
+	})
+	m.SetPadModes(padModes)
+
+	action := func(button Button) string {
+		t.Helper()
+		for _, pressure := range []float32{1, 0} {
+			events := m.Filter(&EventButton{Button: button, Pressure: pressure})
+			if len(events) != 1 {
+				t.Fatalf("%s gave %v", button, events)
+			}
+			if pressure == 0 {
+				if e, ok := events[0].(*EventAction); ok {
+					return e.Action
+				}
+			}
+		}
+		return ""
+	}
+
+	// Change the mode of the second group only.
+	for _, event := range padModes.Filter(&EventButton{Button: ButtonForward, Pressure: 1}) {
+		m.Filter(event)
+	}
+	if mode := m.GroupMode(1); mode != 1 {
+		t.Fatalf("mode of group 1 is %d", mode)
+	}
+	if a := action(ButtonRight); a != "" {
+		t.Errorf("button of group 0 bound to %q in mode 0", a)
+	}
+	if a := action(ButtonBack); a != "right-1" {
+		t.Errorf("button of group 1 bound to %q in mode 1", a)
+	}
+	if a := action(ButtonPen1); a != "" {
+		t.Errorf("button outside groups bound to %q", a)
+	}
+
+	m.SetMode(1)
+	if a := action(ButtonPen1); a != "ungrouped-1" {
+		t.Errorf("button outside groups bound to %q after SetMode", a)
+	}
+	if a := action(ButtonRight); a != "" {
+		t.Errorf("button of group 0 bound to %q after SetMode", a)
+	}
+}
+
+// mapperStep is an event filtered by the mapper or a function changing it.
+type mapperStep interface{}
+
+func press(button Button) *EventButton   { return &EventButton{Button: button, Pressure: 1} }
+func release(button Button) *EventButton { return &EventButton{Button: button} }
+
+func penPosition(tool Button) *EventPositionPen { return &EventPositionPen{Tool: tool} }
+
+func penProximity(tool Button, in bool) *EventProximityPen {
+	return &EventProximityPen{Tool: tool, InProximity: in}
+}
+
+// mapperSummary describes the button and action events of events.
+func mapperSummary(events []Event) []string {
+	var s []string
+	for _, event := range events {
+		switch e := event.(type) {
+		case *EventButton:
+			s = append(s, fmt.Sprintf("%s %g", e.Button, e.Pressure))
+		case *EventAction:
+			s = append(s, fmt.Sprintf("%s %s %t", e.Action, e.Button, e.Pressed))
+		}
+	}
+	return s
+}
+
+func TestButtonMapper(t *testing.T) {
+	setMode := func(mode int) func(m *ButtonMapper) {
+		return func(m *ButtonMapper) { m.SetMode(mode) }
+	}
+	setBindings := func(bindings ...ButtonBinding) func(m *ButtonMapper) {
+		return func(m *ButtonMapper) { m.SetBindings(bindings) }
+	}
+
+	for _, test := range []struct {
+		name     string
+		bindings []ButtonBinding
+		steps    []mapperStep
+		want     []string
+	}{
+		{
+			"unbound",
+			[]ButtonBinding{RemapButton(ButtonPen1, ButtonPen2)},
+			[]mapperStep{press(ButtonLeft), release(ButtonLeft)},
+			[]string{"Left 1", "Left 0"},
+		},
+		{
+			"remap",
+			[]ButtonBinding{RemapButton(ButtonPen1, ButtonPen2)},
+			[]mapperStep{press(ButtonPen1), release(ButtonPen1), press(ButtonPen2), release(ButtonPen2)},
+			[]string{"Pen2 1", "Pen2 0", "Pen2 1", "Pen2 0"},
+		},
+		{
+			"swap",
+			[]ButtonBinding{RemapButton(ButtonPen1, ButtonPen2), RemapButton(ButtonPen2, ButtonPen1)},
+			[]mapperStep{press(ButtonPen1), press(ButtonPen2), release(ButtonPen1), release(ButtonPen2)},
+			[]string{"Pen2 1", "Pen1 1", "Pen2 0", "Pen1 0"},
+		},
+		{
+			"disable",
+			[]ButtonBinding{DisableButton(ButtonBack)},
+			[]mapperStep{press(ButtonBack), release(ButtonBack), press(ButtonForward)},
+			[]string{"Forward 1"},
+		},
+		{
+			"action",
+			[]ButtonBinding{BindButtonAction(ButtonPenTip, "draw")},
+			[]mapperStep{
+				&EventButton{Button: ButtonPenTip, Pressure: 0.2},
+				&EventButton{Button: ButtonPenTip, Pressure: 0.6},
+				release(ButtonPenTip),
+			},
+			[]string{"draw PenTip true", "draw PenTip false"},
+		},
+		{
+			"tool from position",
+			[]ButtonBinding{
+				RemapButton(ButtonPen1, ButtonPen2),
+				BindButtonAction(ButtonPen1, "erase").ForTool(ToolEraser),
+			},
+			[]mapperStep{
+				penPosition(ButtonPenEraser), press(ButtonPen1), release(ButtonPen1),
+				penPosition(ButtonPenTip), press(ButtonPen1), release(ButtonPen1),
+			},
+			[]string{"erase Pen1 true", "erase Pen1 false", "Pen2 1", "Pen2 0"},
+		},
+		{
+			"tool from proximity",
+			[]ButtonBinding{BindButtonAction(ButtonPen1, "erase").ForTool(ToolEraser)},
+			[]mapperStep{
+				penProximity(ButtonPenEraser, true), press(ButtonPen1), release(ButtonPen1),
+				// The tool that left proximity stays active.
+				penProximity(ButtonPenEraser, false), press(ButtonPen1), release(ButtonPen1),
+				penProximity(ButtonPenTip, true), press(ButtonPen1), release(ButtonPen1),
+			},
+			[]string{"erase Pen1 true", "erase Pen1 false", "erase Pen1 true", "erase Pen1 false", "Pen1 1", "Pen1 0"},
+		},
+		{
+			"mode",
+			[]ButtonBinding{RemapButton(ButtonLeft, ButtonRight).InMode(1)},
+			[]mapperStep{press(ButtonLeft), release(ButtonLeft), setMode(1), press(ButtonLeft), release(ButtonLeft)},
+			[]string{"Left 1", "Left 0", "Right 1", "Right 0"},
+		},
+		{
+			"mode before tool",
+			[]ButtonBinding{
+				RemapButton(ButtonPen1, ButtonPen2),
+				RemapButton(ButtonPen1, ButtonLeft).InMode(1),
+				RemapButton(ButtonPen1, ButtonRight).ForTool(ToolEraser),
+			},
+			[]mapperStep{
+				penPosition(ButtonPenEraser), press(ButtonPen1), release(ButtonPen1),
+				setMode(1), press(ButtonPen1), release(ButtonPen1),
+				penPosition(ButtonPenTip), press(ButtonPen1), release(ButtonPen1),
+				setMode(0), press(ButtonPen1), release(ButtonPen1),
+			},
+			[]string{"Right 1", "Right 0", "Left 1", "Left 0", "Left 1", "Left 0", "Pen2 1", "Pen2 0"},
+		},
+		{
+			"mode and tool before mode",
+			[]ButtonBinding{
+				RemapButton(ButtonPen1, ButtonForward).ForTool(ToolEraser).InMode(1),
+				RemapButton(ButtonPen1, ButtonLeft).InMode(1),
+			},
+			[]mapperStep{
+				setMode(1), penPosition(ButtonPenEraser), press(ButtonPen1), release(ButtonPen1),
+				penPosition(ButtonPenTip), press(ButtonPen1), release(ButtonPen1),
+			},
+			[]string{"Forward 1", "Forward 0", "Left 1", "Left 0"},
+		},
+		{
+			"later binding first",
+			[]ButtonBinding{RemapButton(ButtonPen1, ButtonLeft), RemapButton(ButtonPen1, ButtonRight)},
+			[]mapperStep{press(ButtonPen1), release(ButtonPen1)},
+			[]string{"Right 1", "Right 0"},
+		},
+		{
+			"bindings changed while held",
+			[]ButtonBinding{RemapButton(ButtonPen1, ButtonPen2)},
+			[]mapperStep{
+				press(ButtonPen1), setBindings(DisableButton(ButtonPen1)), release(ButtonPen1),
+				press(ButtonPen1), setBindings(), release(ButtonPen1),
+				press(ButtonPen1), setBindings(BindButtonAction(ButtonPen1, "undo")), release(ButtonPen1),
+			},
+			[]string{"Pen2 1", "Pen2 0", "Pen1 1", "Pen1 0"},
+		},
+		{
+			"action bound while held",
+			nil,
+			[]mapperStep{press(ButtonPen1), setBindings(BindButtonAction(ButtonPen1, "undo")), release(ButtonPen1), press(ButtonPen1)},
+			[]string{"Pen1 1", "Pen1 0", "undo Pen1 true"},
+		},
+		{
+			"tool and mode changed while held",
+			[]ButtonBinding{
+				BindButtonAction(ButtonPen1, "erase").ForTool(ToolEraser),
+				RemapButton(ButtonPen1, ButtonLeft).InMode(1),
+			},
+			[]mapperStep{
+				penProximity(ButtonPenEraser, true), press(ButtonPen1), penProximity(ButtonPenTip, true), release(ButtonPen1),
+				press(ButtonPen1), setMode(1), release(ButtonPen1),
+			},
+			[]string{"erase Pen1 true", "erase Pen1 false", "Pen1 1", "Pen1 0"},
+		},
+	} {
+		m := NewButtonMapper(test.bindings)
+		var got []string
+		for _, step := range test.steps {
+			switch v := step.(type) {
+			case Event:
+				got = append(got, mapperSummary(m.Filter(v))...)
+			case func(m *ButtonMapper):
+				v(m)
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package chimp
+
+// Clock is an enumeration of clocks used for event timestamps.
+type Clock int
+
+//go:generate stringer -type=Clock -trimprefix=Clock
+
+const (
+	// ClockRealtime is wall clock time. It may jump when the system time is
+	// adjusted.
+	ClockRealtime Clock = iota
+
+	// ClockMonotonic is time since an unspecified starting point that never
+	// jumps. Timestamps are represented as time since the Unix epoch and can
+	// only be compared with other times of the same clock.
+	ClockMonotonic
+)
@@ -0,0 +1,25 @@
+package chimp
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Now returns the current time of clock. Wall clock time is returned if the
+// monotonic clock can't be read.
+func (clock Clock) Now() time.Time {
+	if clock != ClockMonotonic {
+		return time.Now()
+	}
+	// clock_gettime only fails for unknown clocks and invalid addresses,
+	// CLOCK_MONOTONIC is supported by all Linux versions that Go supports.
+	var ts syscall.Timespec
+	if _, _, errno := syscall.Syscall(syscall.SYS_CLOCK_GETTIME, clockMonotonicID, uintptr(unsafe.Pointer(&ts)), 0); errno != 0 {
+		return time.Now()
+	}
+	return time.Unix(ts.Sec, ts.Nsec)
+}
+
+// Linux clock ID of CLOCK_MONOTONIC.
+const clockMonotonicID = 1
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package chimp
+
+import "time"
+
+// Now returns the current time of clock. Monotonic clock is not supported on
+// this platform and returns wall clock time.
+func (clock Clock) Now() time.Time {
+	return time.Now()
+}
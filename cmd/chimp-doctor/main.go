@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/johan-bolmsjo/chimp"
+)
+
+func main() {
+	diagnoses, err := chimp.DiagnoseDevices()
+	if err != nil {
+		fatalf("Failed to diagnose devices, error: %s\n", err)
+	}
+
+	if len(diagnoses) == 0 {
+		fmt.Println("No input device nodes found.")
+		return
+	}
+
+	fmt.Printf("Input device nodes:\n\nNode                Access  Owner           Name\n")
+	var supported int
+	for _, d := range diagnoses {
+		access := "ok"
+		if errors.Is(d.Err, chimp.ErrPermission) {
+			access = "denied"
+		} else if d.Err != nil {
+			access = "error"
+		}
+		name := fmt.Sprintf("%q", d.Name)
+		if d.Matcher != "" {
+			name += " (supported)"
+			supported++
+		}
+		fmt.Printf("%-19s %-7s %-15s %s\n", d.Path, access, d.Owner+":"+d.Group, name)
+	}
+
+	// Suggest fixes for supported devices. If none was found, suggest fixes
+	// for all nodes as devices may not be identified without access.
+	if supported == 0 {
+		fmt.Println("\nNo supported devices found.")
+	}
+	printed := map[string]bool{}
+	var problems int
+	for _, d := range diagnoses {
+		if d.Err == nil || (supported > 0 && d.Matcher == "") {
+			continue
+		}
+		if problems == 0 {
+			fmt.Println("\nProblems:")
+		}
+		problems++
+		fmt.Printf("\n%s %q: %s\n", d.Path, d.Name, d.Err)
+		for _, fix := range d.Fixes {
+			if !printed[fix] {
+				printed[fix] = true
+				fmt.Printf("\n%s\n", fix)
+			}
+		}
+	}
+	if problems == 0 && supported > 0 {
+		fmt.Println("\nAll supported devices can be opened.")
+	}
+}
+
+func fatalf(format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, format, a...)
+	os.Exit(1)
+}
@@ -10,7 +10,7 @@ import (
 func main() {
 	devices, err := chimp.ListDevices()
 	if err != nil {
-		fatalf("Failed to list devices, error: %s\n")
+		fatalf("Failed to list devices, error: %s\n", err)
 	}
 
 	if len(devices) == 0 {
@@ -10,7 +10,7 @@ import (
 func main() {
 	devices, err := chimp.ListDevices()
 	if err != nil {
-		fatalf("Failed to list devices, %s\n")
+		fatalf("Failed to list devices, %s\n", err)
 	}
 
 	if len(devices) == 0 {
@@ -18,9 +18,9 @@ func main() {
 		return
 	}
 
-	fmt.Printf("Identified supported devices:\n\nID Type       Name\n")
+	fmt.Printf("Identified supported devices:\n\nID Type       Name                           Stable ID\n")
 	for i, device := range devices {
-		fmt.Printf("%2d %-10s %q\n", i, device.Type, device.Name)
+		fmt.Printf("%2d %-10s %-30q %s\n", i, device.Type, device.Name, device.ID)
 	}
 }
 
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/johan-bolmsjo/chimp"
+)
+
+func main() {
+	skipExercise := flag.Bool("skip-exercise", false, "don't record input, draft descriptor from reported capabilities only")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-skip-exercise] NODE...\n\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "Probe input device nodes, e.g. /dev/input/event13, of one device and draft a\n")
+		fmt.Fprintf(os.Stderr, "device descriptor. Use chimp-doctor to list input device nodes.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	paths := flag.Args()
+	if len(paths) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var probes []*chimp.InputNodeProbe
+	for _, path := range paths {
+		probe, err := chimp.ProbeInputNode(path)
+		if err != nil {
+			fatalf("Failed to probe %s, error: %s\n", path, err)
+		}
+		probes = append(probes, probe)
+		fmt.Printf("%s\n\n", probe)
+	}
+
+	var activity []chimp.InputNodeActivity
+	if !*skipExercise {
+		activity = exercise(paths, probes)
+	}
+
+	desc, notes := chimp.DraftDeviceDescriptor(probes, activity)
+	fmt.Printf("Draft device descriptor, review it and load it with chimp.LoadDeviceDescriptors:\n\n")
+	for _, note := range notes {
+		fmt.Printf("# Note: %s\n", note)
+	}
+	if len(notes) > 0 {
+		fmt.Println()
+	}
+	fmt.Print(desc)
+}
+
+// exercise records input while the user exercises the device.
+func exercise(paths []string, probes []*chimp.InputNodeProbe) []chimp.InputNodeActivity {
+	fmt.Println("Exercise the device: move every tool in and out of proximity, press the tip,")
+	fmt.Println("flip the pen to the eraser if it has one, touch the pad and press every button.")
+	fmt.Printf("New event codes are printed as they are seen. Press Enter when done.\n\n")
+
+	// Event codes seen on each node.
+	type seenCode struct {
+		node      int
+		typ, code uint16
+	}
+	seen := map[seenCode]bool{}
+	onEvent := func(node int, event *chimp.InputEvent) {
+		k := seenCode{node, event.Type, event.Code}
+		if event.Type == 0 || seen[k] {
+			return
+		}
+		seen[k] = true
+		fmt.Printf("%s: %s %s\n", filepath.Base(paths[node]), typeName(probes[node], event.Type), codeName(probes[node], event.Type, event.Code))
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		bufio.NewReader(os.Stdin).ReadString('\n')
+		close(stop)
+	}()
+	activity, err := chimp.RecordInputNodes(paths, stop, onEvent)
+	if err != nil {
+		fatalf("Failed to record input, error: %s\n", err)
+	}
+	fmt.Println()
+	return activity
+}
+
+func typeName(probe *chimp.InputNodeProbe, typ uint16) string {
+	for _, t := range probe.EventTypes {
+		if t.Type == typ {
+			return t.Name
+		}
+	}
+	return fmt.Sprintf("%d", typ)
+}
+
+func codeName(probe *chimp.InputNodeProbe, typ, code uint16) string {
+	for _, t := range probe.EventTypes {
+		if t.Type == typ {
+			for _, c := range t.Codes {
+				if c.Code == code {
+					return c.Name
+				}
+			}
+		}
+	}
+	return fmt.Sprintf("%d", code)
+}
+
+func fatalf(format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, format, a...)
+	os.Exit(1)
+}
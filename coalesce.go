@@ -0,0 +1,63 @@
+package chimp
+
+// MotionCoalescer is implemented by devices supporting motion event coalescing.
+//
+// When the consumer of events falls behind, position events are normally
+// dropped. With coalescing enabled, consecutive position events of the same
+// position device are instead merged into the latest one while the event
+// queue is full. The merged events are kept in the History field of the
+// delivered event so that no geometry is lost. Button events, including pen
+// pressure changes, are not dropped while coalescing is enabled. They are
+// delivered in order after any merged position event, waiting for room in the
+// queue.
+type MotionCoalescer interface {
+	// SetMotionCoalescing enables or disables motion coalescing. Coalescing
+	// is disabled by default.
+	SetMotionCoalescing(enabled bool)
+}
+
+// maxCoalescedHistory limits the number of historical samples kept in a
+// coalesced event. The oldest samples are discarded beyond this.
+const maxCoalescedHistory = 256
+
+// coalesceMotion merges position event prev into next if they are of the same
+// type. Returns false if the events could not be merged.
+func coalesceMotion(prev, next Event) bool {
+	switch n := next.(type) {
+	case *EventPositionPen:
+		p, ok := prev.(*EventPositionPen)
+		if !ok {
+			return false
+		}
+		sample := *p
+		sample.History, sample.Coalesced = nil, 0
+		n.History = trimCoalescedPens(append(p.History, sample))
+		n.Coalesced = p.Coalesced + 1
+	case *EventPositionFinger:
+		p, ok := prev.(*EventPositionFinger)
+		if !ok {
+			return false
+		}
+		sample := *p
+		sample.History, sample.Coalesced = nil, 0
+		n.History = trimCoalescedFingers(append(p.History, sample))
+		n.Coalesced = p.Coalesced + 1
+	default:
+		return false
+	}
+	return true
+}
+
+func trimCoalescedPens(s []EventPositionPen) []EventPositionPen {
+	if len(s) > maxCoalescedHistory {
+		s = s[len(s)-maxCoalescedHistory:]
+	}
+	return s
+}
+
+func trimCoalescedFingers(s []EventPositionFinger) []EventPositionFinger {
+	if len(s) > maxCoalescedHistory {
+		s = s[len(s)-maxCoalescedHistory:]
+	}
+	return s
+}
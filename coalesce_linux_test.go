@@ -0,0 +1,176 @@
+package chimp
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/johan-bolmsjo/chimp/internal/channel"
+)
+
+// newTestMuxProd creates a producer of a pen and a finger source sending to a
+// queue of the given size that is only read by the test.
+func newTestMuxProd(t *testing.T, queueSize int, coalesce bool) (*eventMuxProd, <-chan Event) {
+	events := make(chan Event, queueSize)
+	sync := channel.NewConsSync()
+	t.Cleanup(func() { sync.Shutdown() })
+	prod := &eventMuxProd{
+		events: events,
+		sync:   sync.ProdSync(),
+		stats:  newEventStats([]string{"Pen", "Finger"}, queueSize),
+	}
+	if coalesce {
+		prod.coalesce = 1
+	}
+	return prod, events
+}
+
+// sendInBackground sends events from source without waiting for room in the
+// queue. The returned channel is closed when all events have been sent.
+func sendInBackground(prod *eventMuxProd, events []Event, source int) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		prod.sendEvents(events, source)
+		close(done)
+	}()
+	return done
+}
+
+// receiveQueued receives n events from queue and describes them.
+func receiveQueued(t *testing.T, queue <-chan Event, n int) []string {
+	t.Helper()
+	var got []string
+	for i := 0; i < n; i++ {
+		select {
+		case event := <-queue:
+			got = append(got, describeQueued(event))
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %q, no more events", got)
+		}
+	}
+	return got
+}
+
+func describeQueued(event Event) string {
+	at := event.Time().Sub(testStart)
+	switch e := event.(type) {
+	case *EventPositionPen:
+		var history []time.Duration
+		for _, v := range e.History {
+			history = append(history, v.Timestamp.Sub(testStart))
+		}
+		return fmt.Sprintf("%s pen coalesced %d %v", at, e.Coalesced, history)
+	case *EventPositionFinger:
+		return fmt.Sprintf("%s finger coalesced %d", at, e.Coalesced)
+	case *EventButton:
+		return fmt.Sprintf("%s button %s %g", at, e.Button, e.Pressure)
+	}
+	return fmt.Sprintf("%s %T", at, event)
+}
+
+func fingerEventAt(ms int) *EventPositionFinger {
+	return &EventPositionFinger{Timestamp: testTime(ms)}
+}
+
+func tipAt(ms int, pressure float32) *EventButton {
+	return &EventButton{Timestamp: testTime(ms), Button: ButtonPenTip, Pressure: pressure}
+}
+
+func checkQueueStats(t *testing.T, prod *eventMuxProd, source int, eventType EventType, want EventStats) {
+	t.Helper()
+	got := prod.stats.snapshot().Sources[source].Events[eventType]
+	got.Produced, got.Delivered = 0, 0
+	if got != want {
+		t.Errorf("%s of source %d: got %+v, want %+v", eventType, source, got, want)
+	}
+}
+
+func TestMotionCoalescing(t *testing.T) {
+	prod, queue := newTestMuxProd(t, 2, true)
+
+	// The queue is filled, the following pen events are merged while waiting
+	// for room.
+	prod.sendEvents([]Event{penAt(0, 0.1, 0.1), tipAt(0, 0.5)}, 0)
+	prod.sendEvents([]Event{penAt(10, 0.2, 0.1)}, 0)
+	prod.sendEvents([]Event{penAt(20, 0.3, 0.1)}, 0)
+	if flushed, _ := prod.flush(false); flushed {
+		t.Fatal("pending event flushed to full queue")
+	}
+
+	// The pressure change waits for room after the merged pen event.
+	done := sendInBackground(prod, []Event{tipAt(20, 0.6), penAt(30, 0.4, 0.1)}, 0)
+	want := []string{
+		"0s pen coalesced 0 []",
+		"0s button PenTip 0.5",
+		"20ms pen coalesced 1 [10ms]",
+		"20ms button PenTip 0.6",
+	}
+	if got := receiveQueued(t, queue, len(want)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	<-done
+
+	// The last pen event was queued or is pending.
+	if flushed, _ := prod.flush(true); !flushed {
+		t.Fatal("pending event not flushed")
+	}
+	want = []string{"30ms pen coalesced 0 []"}
+	if got := receiveQueued(t, queue, len(want)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if len(queue) != 0 {
+		t.Errorf("%d unexpected events", len(queue))
+	}
+	checkQueueStats(t, prod, 0, EventTypePositionPen, EventStats{Coalesced: 1})
+	checkQueueStats(t, prod, 0, EventTypeButton, EventStats{})
+}
+
+func TestMotionCoalescingOtherDevice(t *testing.T) {
+	prod, queue := newTestMuxProd(t, 1, true)
+
+	// A pending pen event can't be merged into a finger event, it's dropped
+	// if there is still no room for it.
+	prod.sendEvents([]Event{penAt(0, 0.1, 0.1)}, 0)
+	prod.sendEvents([]Event{penAt(10, 0.2, 0.1)}, 0)
+	prod.sendEvents([]Event{fingerEventAt(15)}, 1)
+	prod.sendEvents([]Event{fingerEventAt(20)}, 1)
+
+	want := []string{"0s pen coalesced 0 []"}
+	if got := receiveQueued(t, queue, len(want)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if flushed, _ := prod.flush(false); !flushed {
+		t.Fatal("pending event not flushed")
+	}
+	want = []string{"20ms finger coalesced 1"}
+	if got := receiveQueued(t, queue, len(want)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if len(queue) != 0 {
+		t.Errorf("%d unexpected events", len(queue))
+	}
+	checkQueueStats(t, prod, 0, EventTypePositionPen, EventStats{Dropped: 1})
+	checkQueueStats(t, prod, 1, EventTypePositionFinger, EventStats{Coalesced: 1})
+}
+
+func TestMotionCoalescingDisabled(t *testing.T) {
+	prod, queue := newTestMuxProd(t, 1, false)
+
+	// Position events and button presses are dropped when the queue is
+	// full, releases wait for room.
+	prod.sendEvents([]Event{penAt(0, 0.1, 0.1)}, 0)
+	prod.sendEvents([]Event{penAt(10, 0.2, 0.1), tipAt(10, 0.5)}, 0)
+	done := sendInBackground(prod, []Event{tipAt(20, 0)}, 0)
+
+	want := []string{"0s pen coalesced 0 []", "20ms button PenTip 0"}
+	if got := receiveQueued(t, queue, len(want)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	<-done
+	if len(queue) != 0 {
+		t.Errorf("%d unexpected events", len(queue))
+	}
+	checkQueueStats(t, prod, 0, EventTypePositionPen, EventStats{Dropped: 1})
+	checkQueueStats(t, prod, 0, EventTypeButton, EventStats{Dropped: 1})
+}
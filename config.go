@@ -0,0 +1,713 @@
+package chimp
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeviceConfig is the user configuration of a device. Configurations are
+// stored in files named by the stable ID of the device, see DeviceConfigPath.
+// The configuration of a device is loaded when it's opened and applied to
+// events read from the device. Changes to the file are applied while the
+// device is open.
+//
+// Settings that are not configured are left as they are, e.g. palm rejection
+// set by the application is kept if the configuration does not set it.
+// Settings that are removed from the configuration while the device is open
+// are restored to what they were when the device was opened.
+type DeviceConfig struct {
+	// Clockwise rotation of the pad from its normal orientation. Positions
+	// are rotated back so that origo is in the upper left corner of the
+	// rotated pad. Properties describe the pad in its normal orientation.
+	Rotation Rotation
+
+	// Part of the pad that pen positions are mapped to, after rotation. The
+	// whole pad is used if zero.
+	Area Area
+
+	// Pressure curve of the pen as points of input and output pressure in
+	// range [0, 1] ordered by input pressure. Pressure is interpolated
+	// linearly between points. Pressure is not changed if nil.
+	PressureCurve []Coord2D
+
+	PalmRejection    *PalmRejection // Palm rejection policy, see PalmRejecter.
+	MotionCoalescing *bool          // Motion coalescing, see MotionCoalescer.
+
+	// Button bindings applied by the button mapper of the device, see
+	// ConfiguredDevice.
+	Buttons []ButtonBinding
+}
+
+// Rotation is an enumeration of pad rotations in degrees clockwise.
+type Rotation int
+
+const (
+	Rotation0   Rotation = 0
+	Rotation90  Rotation = 90
+	Rotation180 Rotation = 180
+	Rotation270 Rotation = 270
+)
+
+// Area is a rectangular part of a pad in normalized coordinates.
+type Area struct {
+	Min, Max Coord2D // Upper left and lower right corners.
+}
+
+func (a Area) empty() bool {
+	return a == Area{}
+}
+
+// ConfiguredDevice is implemented by devices that apply user configuration.
+// Devices opened with OpenOptions.NoConfig have an empty configuration and no
+// button mapper.
+type ConfiguredDevice interface {
+	// Config returns the configuration in use and the error of the last
+	// attempt to load it. The last successfully loaded configuration is kept
+	// in use if loading fails.
+	Config() (DeviceConfig, error)
+
+	// ButtonMapper returns the button mapper that applies the configured
+	// button bindings. Its modes may be changed by the application. The
+	// bindings are replaced when the configuration is reloaded.
+	ButtonMapper() *ButtonMapper
+}
+
+// DeviceConfigDir returns the default directory of device configuration files,
+// chimp/devices in the user configuration directory, e.g.
+// $XDG_CONFIG_HOME/chimp/devices on Linux.
+func DeviceConfigDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "chimp", "devices"), nil
+}
+
+// DeviceConfigPath returns the path of the configuration file of the device
+// with stable ID in directory dir, see DeviceIdentity.StableID.
+func DeviceConfigPath(dir, id string) string {
+	return filepath.Join(dir, id+".conf")
+}
+
+// LoadDeviceConfig loads the configuration of the device with stable ID from
+// directory dir. An empty configuration is returned if there is none.
+func LoadDeviceConfig(dir, id string) (*DeviceConfig, error) {
+	f, err := os.Open(DeviceConfigPath(dir, id))
+	if os.IsNotExist(err) {
+		return &DeviceConfig{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseDeviceConfig(f)
+}
+
+// SaveDeviceConfig saves the configuration of the device with stable ID in
+// directory dir, which is created if needed. The file is replaced atomically so
+// that open devices never see a partially written file.
+func SaveDeviceConfig(dir, id string, config *DeviceConfig) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, id+".conf.*")
+	if err != nil {
+		return err
+	}
+	if _, err = io.WriteString(f, config.String()); err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), DeviceConfigPath(dir, id))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// ParseDeviceConfig parses a device configuration. Configurations use the same
+// format as device descriptors:
+//
+//	[Device]
+//	Rotation=90
+//	MotionCoalescing=true
+//
+//	[Pen]
+//	Area=0.1:0.1:0.9:0.9
+//	PressureCurve=0:0;0.5:0.3;1:1
+//
+//	[PalmRejection]
+//	Enabled=true
+//	GracePeriod=250ms
+//	MaxContactMillimeters=25
+//
+//	[Buttons]
+//	Pen1=Pen2
+//	Pen2=Pen1
+//	Back=Disabled
+//	Forward=Action:undo
+//
+//	[Buttons Eraser Mode 1]
+//	Pen1=Action:eraser-size
+//
+// All settings are optional. Area is given as left:top:right:bottom and curve
+// points as input:output. Palm rejection settings that are left out are taken
+// from DefaultPalmRejection. Button sections may be limited to a tool, a mode
+// or both, see ButtonBinding.
+func ParseDeviceConfig(r io.Reader) (*DeviceConfig, error) {
+	kf, err := parseKeyFile(r)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &DeviceConfig{}
+	for _, section := range kf.sections {
+		var err error
+		switch section.name {
+		case "Device":
+			err = config.parseDevice(section)
+		case "Pen":
+			err = config.parsePen(section)
+		case "PalmRejection":
+			err = config.parsePalmRejection(section)
+		default:
+			if strings.HasPrefix(section.name, "Buttons") {
+				err = config.parseButtons(section)
+				break
+			}
+			err = fmt.Errorf("line %d: unknown section %q", section.line, section.name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (config *DeviceConfig) parseDevice(section *keyFileSection) error {
+	for _, entry := range section.entries {
+		var err error
+		switch entry.key {
+		case "Rotation":
+			var degrees int
+			degrees, err = strconv.Atoi(entry.value)
+			config.Rotation = Rotation(degrees)
+		case "MotionCoalescing":
+			var enabled bool
+			enabled, err = strconv.ParseBool(entry.value)
+			config.MotionCoalescing = &enabled
+		default:
+			err = fmt.Errorf("unknown key")
+		}
+		if err != nil {
+			return entry.error(err)
+		}
+	}
+	return nil
+}
+
+func (config *DeviceConfig) parsePen(section *keyFileSection) error {
+	for _, entry := range section.entries {
+		var err error
+		switch entry.key {
+		case "Area":
+			var v []float64
+			if v, err = parseFloats(entry.value, ":"); err == nil && len(v) != 4 {
+				err = fmt.Errorf("expected left:top:right:bottom, got %q", entry.value)
+			}
+			if err == nil {
+				config.Area = Area{
+					Min: Coord2D{X: float32(v[0]), Y: float32(v[1])},
+					Max: Coord2D{X: float32(v[2]), Y: float32(v[3])},
+				}
+			}
+		case "PressureCurve":
+			config.PressureCurve = nil
+			for _, point := range keyFileList(entry.value) {
+				var v []float64
+				if v, err = parseFloats(point, ":"); err == nil && len(v) != 2 {
+					err = fmt.Errorf("expected input:output, got %q", point)
+				}
+				if err != nil {
+					break
+				}
+				config.PressureCurve = append(config.PressureCurve, Coord2D{X: float32(v[0]), Y: float32(v[1])})
+			}
+		default:
+			err = fmt.Errorf("unknown key")
+		}
+		if err != nil {
+			return entry.error(err)
+		}
+	}
+	return nil
+}
+
+func (config *DeviceConfig) parsePalmRejection(section *keyFileSection) error {
+	policy := DefaultPalmRejection
+	for _, entry := range section.entries {
+		var err error
+		switch entry.key {
+		case "Enabled":
+			policy.Enabled, err = strconv.ParseBool(entry.value)
+		case "GracePeriod":
+			policy.GracePeriod, err = time.ParseDuration(entry.value)
+		case "MaxContactMillimeters":
+			var v float64
+			v, err = strconv.ParseFloat(entry.value, 32)
+			policy.MaxContactMillimeters = float32(v)
+		default:
+			err = fmt.Errorf("unknown key")
+		}
+		if err != nil {
+			return entry.error(err)
+		}
+	}
+	config.PalmRejection = &policy
+	return nil
+}
+
+// parseButtons parses a section of button bindings named "Buttons" optionally
+// followed by a tool and "Mode N".
+func (config *DeviceConfig) parseButtons(section *keyFileSection) error {
+	tool, mode := ToolAny, ModeAny
+	fields := strings.Fields(section.name)
+	if fields[0] != "Buttons" {
+		return fmt.Errorf("line %d: unknown section %q", section.line, section.name)
+	}
+	fields = fields[1:]
+	if len(fields) > 0 && fields[0] != "Mode" {
+		var err error
+		if tool, err = parseTool(fields[0]); err != nil {
+			return fmt.Errorf("line %d: %s", section.line, err)
+		}
+		fields = fields[1:]
+	}
+	if len(fields) > 0 {
+		var err error
+		if len(fields) != 2 || fields[0] != "Mode" {
+			err = fmt.Errorf("expected Buttons [tool] [Mode N], got %q", section.name)
+		} else if mode, err = strconv.Atoi(fields[1]); err == nil && mode < 0 {
+			err = fmt.Errorf("negative mode %d", mode)
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %s", section.line, err)
+		}
+	}
+
+	for _, entry := range section.entries {
+		button, err := parseButton(entry.key)
+		if err != nil {
+			return entry.error(err)
+		}
+		var binding ButtonBinding
+		switch {
+		case entry.value == "Disabled":
+			binding = DisableButton(button)
+		case strings.HasPrefix(entry.value, "Action:"):
+			action := strings.TrimSpace(strings.TrimPrefix(entry.value, "Action:"))
+			if action == "" {
+				return entry.error(fmt.Errorf("action name missing"))
+			}
+			binding = BindButtonAction(button, action)
+		default:
+			to, err := parseButton(entry.value)
+			if err != nil {
+				return entry.error(err)
+			}
+			binding = RemapButton(button, to)
+		}
+		config.Buttons = append(config.Buttons, binding.ForTool(tool).InMode(mode))
+	}
+	return nil
+}
+
+// parseTool parses the name of a tool without prefix, e.g. "Eraser".
+func parseTool(s string) (Tool, error) {
+	for v := Tool(0); !strings.HasPrefix(v.String(), "Tool("); v++ {
+		if v.String() == s {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown tool %q", s)
+}
+
+func parseFloats(s, sep string) ([]float64, error) {
+	var result []float64
+	for _, v := range strings.Split(s, sep) {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, f)
+	}
+	return result, nil
+}
+
+// validate checks that settings are within range.
+func (config *DeviceConfig) validate() error {
+	switch config.Rotation {
+	case Rotation0, Rotation90, Rotation180, Rotation270:
+	default:
+		return fmt.Errorf("rotation %d is not one of 0, 90, 180 or 270", config.Rotation)
+	}
+	if a := config.Area; !a.empty() {
+		if a.Min.X < 0 || a.Min.Y < 0 || a.Max.X > 1 || a.Max.Y > 1 || a.Min.X >= a.Max.X || a.Min.Y >= a.Max.Y {
+			return fmt.Errorf("area %v..%v is not within [0, 1]", a.Min, a.Max)
+		}
+	}
+	for _, b := range config.Buttons {
+		if b.Mode < ModeAny {
+			return fmt.Errorf("button binding %s: invalid mode", &b)
+		}
+		if b.Kind == ButtonBindingAction && b.Action == "" {
+			return fmt.Errorf("button binding %s: action name missing", &b)
+		}
+	}
+	if curve := config.PressureCurve; curve != nil {
+		if len(curve) < 2 {
+			return fmt.Errorf("pressure curve needs at least two points")
+		}
+		for i, p := range curve {
+			if p.X < 0 || p.X > 1 || p.Y < 0 || p.Y > 1 {
+				return fmt.Errorf("pressure curve point %v is not within [0, 1]", p)
+			}
+			if i > 0 && p.X <= curve[i-1].X {
+				return fmt.Errorf("pressure curve points are not ordered by input pressure")
+			}
+		}
+	}
+	return nil
+}
+
+// String returns the configuration in the format read by ParseDeviceConfig.
+func (config *DeviceConfig) String() string {
+	var sb strings.Builder
+	formatFloat := func(v float32) string {
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	}
+
+	if config.Rotation != Rotation0 || config.MotionCoalescing != nil {
+		sb.WriteString("[Device]\n")
+		if config.Rotation != Rotation0 {
+			fmt.Fprintf(&sb, "Rotation=%d\n", config.Rotation)
+		}
+		if config.MotionCoalescing != nil {
+			fmt.Fprintf(&sb, "MotionCoalescing=%t\n", *config.MotionCoalescing)
+		}
+	}
+	if !config.Area.empty() || config.PressureCurve != nil {
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("[Pen]\n")
+		if a := config.Area; !a.empty() {
+			fmt.Fprintf(&sb, "Area=%s:%s:%s:%s\n", formatFloat(a.Min.X), formatFloat(a.Min.Y), formatFloat(a.Max.X), formatFloat(a.Max.Y))
+		}
+		if config.PressureCurve != nil {
+			var points []string
+			for _, p := range config.PressureCurve {
+				points = append(points, formatFloat(p.X)+":"+formatFloat(p.Y))
+			}
+			fmt.Fprintf(&sb, "PressureCurve=%s\n", strings.Join(points, ";"))
+		}
+	}
+	if policy := config.PalmRejection; policy != nil {
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "[PalmRejection]\nEnabled=%t\nGracePeriod=%s\nMaxContactMillimeters=%s\n",
+			policy.Enabled, policy.GracePeriod, formatFloat(policy.MaxContactMillimeters))
+	}
+
+	// One section of button bindings for every tool and mode, in order of
+	// appearance.
+	type scope struct {
+		tool Tool
+		mode int
+	}
+	var scopes []scope
+	bindings := map[scope][]ButtonBinding{}
+	for _, b := range config.Buttons {
+		k := scope{b.Tool, b.Mode}
+		if _, ok := bindings[k]; !ok {
+			scopes = append(scopes, k)
+		}
+		bindings[k] = append(bindings[k], b)
+	}
+	for _, k := range scopes {
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("[Buttons")
+		if k.tool != ToolAny {
+			sb.WriteString(" " + k.tool.String())
+		}
+		if k.mode != ModeAny {
+			fmt.Fprintf(&sb, " Mode %d", k.mode)
+		}
+		sb.WriteString("]\n")
+		for _, b := range bindings[k] {
+			switch b.Kind {
+			case ButtonBindingButton:
+				fmt.Fprintf(&sb, "%s=%s\n", b.Button, b.To)
+			case ButtonBindingAction:
+				fmt.Fprintf(&sb, "%s=Action:%s\n", b.Button, b.Action)
+			case ButtonBindingDisabled:
+				fmt.Fprintf(&sb, "%s=Disabled\n", b.Button)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// rotate rotates a position on the pad to the rotated pad.
+func (r Rotation) rotate(c Coord2D) Coord2D {
+	switch r {
+	case Rotation90:
+		return Coord2D{X: 1 - c.Y, Y: c.X}
+	case Rotation180:
+		return Coord2D{X: 1 - c.X, Y: 1 - c.Y}
+	case Rotation270:
+		return Coord2D{X: c.Y, Y: 1 - c.X}
+	}
+	return c
+}
+
+// mapArea maps a position within area to the whole pad. Positions outside of
+// area are clamped to its edges.
+func (a Area) mapArea(c Coord2D) Coord2D {
+	if a.empty() {
+		return c
+	}
+	return Coord2D{
+		X: clampUnit((c.X - a.Min.X) / (a.Max.X - a.Min.X)),
+		Y: clampUnit((c.Y - a.Min.Y) / (a.Max.Y - a.Min.Y)),
+	}
+}
+
+func clampUnit(v float32) float32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// pressure applies the pressure curve to pressure.
+func (config *DeviceConfig) pressure(pressure float32) float32 {
+	curve := config.PressureCurve
+	if len(curve) == 0 {
+		return pressure
+	}
+	i := sort.Search(len(curve), func(i int) bool { return curve[i].X >= pressure })
+	switch {
+	case i == 0:
+		return curve[0].Y
+	case i == len(curve):
+		return curve[len(curve)-1].Y
+	}
+	a, b := curve[i-1], curve[i]
+	return a.Y + (b.Y-a.Y)*(pressure-a.X)/(b.X-a.X)
+}
+
+// apply applies the configuration to an event read from the device.
+func (config *DeviceConfig) apply(event Event) {
+	switch e := event.(type) {
+	case *EventPositionPen:
+		e.Coord = config.Area.mapArea(config.Rotation.rotate(e.Coord))
+		for i := range e.History {
+			config.apply(&e.History[i])
+		}
+	case *EventPositionFinger:
+		e.Coord = config.Rotation.rotate(e.Coord)
+		for i := range e.Contacts {
+			e.Contacts[i].Coord = config.Rotation.rotate(e.Contacts[i].Coord)
+		}
+		for i := range e.History {
+			config.apply(&e.History[i])
+		}
+	case *EventButton:
+		if e.Button == ButtonPenTip || e.Button == ButtonPenEraser {
+			e.Pressure = config.pressure(e.Pressure)
+		}
+	}
+}
+
+// How often configuration files of open devices are checked for changes.
+var deviceConfigPollInterval = time.Second
+
+// palmRejectionGetter and motionCoalescingGetter are implemented by devices
+// that report their settings. Devices that don't are assumed to have the
+// settings disabled, as they are by default.
+type palmRejectionGetter interface {
+	palmRejection() PalmRejection
+}
+
+type motionCoalescingGetter interface {
+	motionCoalescing() bool
+}
+
+// deviceConfigState is the configuration of an open device. The configuration
+// file is watched for changes until the state is closed.
+type deviceConfigState struct {
+	dev    Device
+	path   string
+	mapper *ButtonMapper
+
+	// Settings of the device before any configuration was applied, restored
+	// when they are removed from the configuration.
+	defaultPalmRejection    PalmRejection
+	defaultMotionCoalescing bool
+
+	mu     sync.Mutex
+	config DeviceConfig
+	err    error // Error of last load.
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newDeviceConfigState loads the configuration of device with stable ID from
+// directory dir, the default directory if empty, and applies the settings that
+// are not applied to events to dev.
+func newDeviceConfigState(dev Device, dir, id string) *deviceConfigState {
+	state := &deviceConfigState{
+		dev:    dev,
+		mapper: NewButtonMapper(nil),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if v, ok := dev.(PadModeDevice); ok {
+		state.mapper.SetPadModes(v.PadModes())
+	}
+	if v, ok := dev.(palmRejectionGetter); ok {
+		state.defaultPalmRejection = v.palmRejection()
+	}
+	if v, ok := dev.(motionCoalescingGetter); ok {
+		state.defaultMotionCoalescing = v.motionCoalescing()
+	}
+	if dir == "" {
+		var err error
+		if dir, err = DeviceConfigDir(); err != nil {
+			state.err = err
+			close(state.done)
+			return state
+		}
+	}
+	state.path = DeviceConfigPath(dir, id)
+
+	fi, _ := os.Stat(state.path)
+	state.load(fi)
+	go state.watch(fi)
+	return state
+}
+
+// load loads the configuration file described by fi, nil if it does not exist.
+func (state *deviceConfigState) load(fi os.FileInfo) {
+	config := &DeviceConfig{}
+	var err error
+	if fi != nil {
+		var f *os.File
+		if f, err = os.Open(state.path); err == nil {
+			config, err = ParseDeviceConfig(f)
+			f.Close()
+		}
+	}
+	if err != nil {
+		state.mu.Lock()
+		state.err = fmt.Errorf("%s: %s", state.path, err)
+		state.mu.Unlock()
+		return
+	}
+
+	state.mu.Lock()
+	prev := state.config
+	state.mu.Unlock()
+
+	// Settings are only restored if they were configured, settings made by
+	// the application are kept otherwise.
+	if v, ok := state.dev.(PalmRejecter); ok {
+		if config.PalmRejection != nil {
+			v.SetPalmRejection(*config.PalmRejection)
+		} else if prev.PalmRejection != nil {
+			v.SetPalmRejection(state.defaultPalmRejection)
+		}
+	}
+	if v, ok := state.dev.(MotionCoalescer); ok {
+		if config.MotionCoalescing != nil {
+			v.SetMotionCoalescing(*config.MotionCoalescing)
+		} else if prev.MotionCoalescing != nil {
+			v.SetMotionCoalescing(state.defaultMotionCoalescing)
+		}
+	}
+	state.mapper.SetBindings(config.Buttons)
+	state.mu.Lock()
+	state.config, state.err = *config, nil
+	state.mu.Unlock()
+}
+
+// watch reloads the configuration file when its modification time or size
+// changes, or when it's created or removed.
+func (state *deviceConfigState) watch(last os.FileInfo) {
+	defer close(state.done)
+	ticker := time.NewTicker(deviceConfigPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-state.stop:
+			return
+		case <-ticker.C:
+		}
+		fi, err := os.Stat(state.path)
+		if err != nil {
+			fi = nil
+		}
+		if (fi == nil) != (last == nil) || fi != nil && (!fi.ModTime().Equal(last.ModTime()) || fi.Size() != last.Size()) {
+			state.load(fi)
+		}
+		last = fi
+	}
+}
+
+// apply applies the configuration to an event read from the device. Nil is
+// returned if the event is dropped.
+func (state *deviceConfigState) apply(event Event) Event {
+	state.mu.Lock()
+	state.config.apply(event)
+	state.mu.Unlock()
+	return state.mapper.mapEvent(event)
+}
+
+func (state *deviceConfigState) get() (DeviceConfig, error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return state.config, state.err
+}
+
+// close stops watching the configuration file.
+func (state *deviceConfigState) close() {
+	select {
+	case <-state.done:
+	default:
+		close(state.stop)
+		<-state.done
+	}
+}
@@ -0,0 +1,242 @@
+package chimp
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testDeviceConfig is a configuration with all settings in the format written
+// by DeviceConfig.String.
+const testDeviceConfig = `[Device]
+Rotation=90
+MotionCoalescing=true
+
+[Pen]
+Area=0.1:0.1:0.9:0.9
+PressureCurve=0:0;0.5:0.3;1:1
+
+[PalmRejection]
+Enabled=true
+GracePeriod=250ms
+MaxContactMillimeters=25
+
+[Buttons]
+Pen1=Pen2
+Pen2=Pen1
+Back=Disabled
+Forward=Action:undo
+
+[Buttons Eraser Mode 1]
+Pen1=Action:eraser-size
+
+[Buttons Mode 0]
+Left=Right
+`
+
+func TestDeviceConfigRoundTrip(t *testing.T) {
+	config, err := ParseDeviceConfig(strings.NewReader(testDeviceConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := config.String(); s != testDeviceConfig {
+		t.Fatalf("String() =\n%s\nwant\n%s", s, testDeviceConfig)
+	}
+	parsed, err := ParseDeviceConfig(strings.NewReader(config.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, config) {
+		t.Fatalf("parsed %+v, want %+v", parsed, config)
+	}
+
+	if s := (&DeviceConfig{}).String(); s != "" {
+		t.Errorf("empty configuration is %q", s)
+	}
+}
+
+func TestParseDeviceConfigButtons(t *testing.T) {
+	for _, test := range []struct {
+		config   string
+		bindings []ButtonBinding
+	}{
+		{"[Buttons]\nPen1=Pen2", []ButtonBinding{RemapButton(ButtonPen1, ButtonPen2)}},
+		{"[Buttons]\nBack=Disabled", []ButtonBinding{DisableButton(ButtonBack)}},
+		{"[Buttons]\nForward=Action: redo ", []ButtonBinding{BindButtonAction(ButtonForward, "redo")}},
+		{"[Buttons Pen]\nPen1=Pen3", []ButtonBinding{RemapButton(ButtonPen1, ButtonPen3).ForTool(ToolPen)}},
+		{"[Buttons Mode 2]\nLeft=Right", []ButtonBinding{RemapButton(ButtonLeft, ButtonRight).InMode(2)}},
+		{"[Buttons Eraser Mode 1]\nPen1=Action:size", []ButtonBinding{BindButtonAction(ButtonPen1, "size").ForTool(ToolEraser).InMode(1)}},
+		{"[Buttons]\nPen1=Pen2\n[Buttons Eraser]\nPen1=Disabled", []ButtonBinding{
+			RemapButton(ButtonPen1, ButtonPen2),
+			DisableButton(ButtonPen1).ForTool(ToolEraser),
+		}},
+	} {
+		config, err := ParseDeviceConfig(strings.NewReader(test.config))
+		if err != nil {
+			t.Errorf("%q: %s", test.config, err)
+			continue
+		}
+		if !reflect.DeepEqual(config.Buttons, test.bindings) {
+			t.Errorf("%q: got %v, want %v", test.config, config.Buttons, test.bindings)
+		}
+	}
+
+	for _, config := range []string{
+		"[Buttons Brush]\nPen1=Pen2",
+		"[Buttons Mode]\nPen1=Pen2",
+		"[Buttons Mode -1]\nPen1=Pen2",
+		"[Buttons Mode 1 Pen]\nPen1=Pen2",
+		"[ButtonsPen]\nPen1=Pen2",
+		"[Buttons]\nPen9=Pen2",
+		"[Buttons]\nPen1=Pen9",
+		"[Buttons]\nPen1=Action:",
+	} {
+		if _, err := ParseDeviceConfig(strings.NewReader(config)); err == nil {
+			t.Errorf("%q: no error", config)
+		}
+	}
+}
+
+func TestDeviceConfigStateReload(t *testing.T) {
+	saved := deviceConfigPollInterval
+	deviceConfigPollInterval = time.Millisecond
+	defer func() { deviceConfigPollInterval = saved }()
+
+	dir := t.TempDir()
+	state := newDeviceConfigState(newTestDevice(), dir, "test")
+	if config, err := state.get(); err != nil || config.Rotation != Rotation0 {
+		t.Fatalf("got %v, %v without configuration file", config, err)
+	}
+
+	if err := SaveDeviceConfig(dir, "test", &DeviceConfig{Rotation: Rotation180}); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if config, _ := state.get(); config.Rotation == Rotation180 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("configuration not reloaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The watching goroutine is stopped by close.
+	state.close()
+	select {
+	case <-state.done:
+	default:
+		t.Fatal("configuration still watched after close")
+	}
+}
+
+// settingsTestDevice records the settings made by the configuration.
+type settingsTestDevice struct {
+	*testDevice
+	mu       sync.Mutex
+	palm     PalmRejection
+	coalesce bool
+}
+
+func (dev *settingsTestDevice) SetPalmRejection(policy PalmRejection) {
+	dev.mu.Lock()
+	dev.palm = policy
+	dev.mu.Unlock()
+}
+
+func (dev *settingsTestDevice) SetMotionCoalescing(enabled bool) {
+	dev.mu.Lock()
+	dev.coalesce = enabled
+	dev.mu.Unlock()
+}
+
+func (dev *settingsTestDevice) palmRejection() PalmRejection {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	return dev.palm
+}
+
+func (dev *settingsTestDevice) motionCoalescing() bool {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	return dev.coalesce
+}
+
+func TestDeviceConfigStateDefaults(t *testing.T) {
+	saved := deviceConfigPollInterval
+	deviceConfigPollInterval = time.Millisecond
+	defer func() { deviceConfigPollInterval = saved }()
+
+	// The device starts with settings that differ from the configured ones.
+	defaultPalm := PalmRejection{Enabled: true, GracePeriod: time.Second}
+	dev := &settingsTestDevice{testDevice: newTestDevice(), palm: defaultPalm, coalesce: true}
+	dir := t.TempDir()
+	state := newDeviceConfigState(dev, dir, "test")
+	defer state.close()
+
+	disabled := false
+	configPalm := PalmRejection{Enabled: true, GracePeriod: 100 * time.Millisecond, MaxContactMillimeters: 20}
+	steps := []struct {
+		name     string
+		config   *DeviceConfig // Nil removes the file.
+		palm     PalmRejection
+		coalesce bool
+	}{
+		{"configured", &DeviceConfig{PalmRejection: &configPalm, MotionCoalescing: &disabled}, configPalm, false},
+		{"palm rejection removed", &DeviceConfig{Rotation: Rotation90, MotionCoalescing: &disabled}, defaultPalm, false},
+		{"motion coalescing removed", &DeviceConfig{Rotation: Rotation180}, defaultPalm, true},
+		{"configured again", &DeviceConfig{PalmRejection: &configPalm, MotionCoalescing: &disabled}, configPalm, false},
+		{"file removed", nil, defaultPalm, true},
+	}
+	for _, step := range steps {
+		var want DeviceConfig
+		if step.config != nil {
+			want = *step.config
+			if err := SaveDeviceConfig(dir, "test", step.config); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := os.Remove(DeviceConfigPath(dir, "test")); err != nil {
+			t.Fatal(err)
+		}
+		for deadline := time.Now().Add(5 * time.Second); ; {
+			if config, _ := state.get(); reflect.DeepEqual(config, want) {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: configuration not reloaded", step.name)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if palm := dev.palmRejection(); palm != step.palm {
+			t.Errorf("%s: palm rejection %+v, want %+v", step.name, palm, step.palm)
+		}
+		if coalesce := dev.motionCoalescing(); coalesce != step.coalesce {
+			t.Errorf("%s: motion coalescing %t, want %t", step.name, coalesce, step.coalesce)
+		}
+	}
+}
+
+func TestDeviceConfigStateApplicationSettings(t *testing.T) {
+	// Settings made by the application are kept if never configured.
+	dev := &settingsTestDevice{testDevice: newTestDevice()}
+	dir := t.TempDir()
+	if err := SaveDeviceConfig(dir, "test", &DeviceConfig{Rotation: Rotation90}); err != nil {
+		t.Fatal(err)
+	}
+	state := newDeviceConfigState(dev, dir, "test")
+	defer state.close()
+	dev.SetPalmRejection(DefaultPalmRejection)
+	dev.SetMotionCoalescing(true)
+
+	fi, err := os.Stat(DeviceConfigPath(dir, "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.load(fi)
+	if palm, coalesce := dev.palmRejection(), dev.motionCoalescing(); palm != DefaultPalmRejection || !coalesce {
+		t.Errorf("application settings replaced by %+v and %t", palm, coalesce)
+	}
+}
@@ -0,0 +1,709 @@
+package chimp
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DeviceDescriptor describes a device declaratively. Devices described by a
+// descriptor are supported without code changes. Descriptors of supported
+// devices are embedded in this package and more can be loaded with
+// LoadDeviceDescriptors.
+type DeviceDescriptor struct {
+	Name              string        // Name of device.
+	Type              DeviceType    // Device type.
+	Match             []DeviceMatch // Supported IDs, only name patterns are matched if empty.
+	Priority          int           // Priority when matching devices, see Driver.
+	WidthMillimeters  float64       // Width of the pad along the X-axis.
+	HeightMillimeters float64       // Height of the pad along the Y-axis.
+
+	// Sub-devices of the device, nil if not present. Each sub-device is a
+	// separate input device node on Linux.
+	Pen, Finger, Pad *SubDeviceDescriptor
+
+	// Mode groups of the pad, see PadModeGroup.
+	ModeGroups []PadModeGroup
+
+	Quirks DeviceQuirks
+}
+
+// DeviceMatch is the bus, vendor and product ID of a supported device.
+type DeviceMatch struct {
+	BusType BusType
+	Vendor  uint16
+	Product uint16
+}
+
+// SubDeviceDescriptor describes a sub-device such as the pen of a tablet.
+type SubDeviceDescriptor struct {
+	NamePattern *regexp.Regexp // Matches the name of the sub-device.
+	X, Y        AxisRange      // Position ranges, required for pen and finger.
+	Pressure    AxisRange      // Pressure range, required for pen.
+	Distance    AxisRange      // Distance range, zero if not reported.
+	Buttons     []Button       // Buttons reported by the sub-device.
+
+	// Ranges of the touch rings and strips of a pad, in order of index. Rings
+	// are reported with ABS_WHEEL and ABS_THROTTLE and strips with ABS_RX and
+	// ABS_RY. Strips report the position as a single bit of the range, as
+	// Wacom strips do.
+	Rings, Strips []AxisRange
+}
+
+// AxisRange is the range of values reported for an axis.
+type AxisRange struct {
+	Min, Max int32
+}
+
+func (r AxisRange) empty() bool {
+	return r.Max <= r.Min
+}
+
+// DeviceQuirks holds workarounds of device specific behavior.
+type DeviceQuirks struct {
+	// The pen may report a positive distance in the same event group as a
+	// positive pressure. The distance is cleared when pressure is positive.
+	ClearDistanceOnPressure bool
+}
+
+// ParseDeviceDescriptor parses a device descriptor. Descriptors use an INI
+// like format similar to libwacom's .tablet files:
+//
+//	# Comment
+//	[Device]
+//	Name=Wacom Bamboo 16FG 6x8
+//	Type=Tablet
+//	DeviceMatch=usb:056a:00df
+//	Priority=0
+//	WidthMillimeters=216
+//	HeightMillimeters=137
+//
+//	[Pen]
+//	NamePattern=^Wacom Bamboo 16FG 6x8 Pen$
+//	X=0:21648
+//	Y=0:13700
+//	Pressure=0:1023
+//	Distance=0:30
+//	Buttons=PenTip;PenEraser;Pen1;Pen2
+//
+//	[Finger]
+//	NamePattern=^Wacom Bamboo 16FG 6x8 Finger$
+//	X=0:4095
+//	Y=0:4095
+//	Buttons=Touch
+//
+//	[Pad]
+//	NamePattern=^Wacom Bamboo 16FG 6x8 Pad$
+//	Buttons=Left;Right;Forward;Back
+//
+//	[Quirks]
+//	ClearDistanceOnPressure=true
+//
+// DeviceMatch and Priority are optional. Name patterns are regular expressions
+// matched against the names of input devices. Ranges are given as min:max,
+// lists are separated by ';' and buttons and types are named as in this
+// package without prefix.
+//
+// Pads with touch rings or strips list their ranges and may group them with
+// buttons in mode groups, one section per group:
+//
+//	[Pad]
+//	NamePattern=^Example Tablet Pad$
+//	Buttons=Left;Right;Forward;Back
+//	Rings=0:71
+//
+//	[ModeGroup]
+//	Modes=4
+//	Toggle=Left
+//	Buttons=Right;Forward;Back
+//	Rings=0
+func ParseDeviceDescriptor(r io.Reader) (*DeviceDescriptor, error) {
+	kf, err := parseKeyFile(r)
+	if err != nil {
+		return nil, err
+	}
+
+	desc := &DeviceDescriptor{}
+	for _, section := range kf.sections {
+		var err error
+		switch section.name {
+		case "Device":
+			err = desc.parseDevice(section)
+		case "Pen":
+			desc.Pen, err = parseSubDeviceDescriptor(section)
+		case "Finger":
+			desc.Finger, err = parseSubDeviceDescriptor(section)
+		case "Pad":
+			desc.Pad, err = parseSubDeviceDescriptor(section)
+		case "ModeGroup":
+			var group PadModeGroup
+			if group, err = parsePadModeGroup(section); err == nil {
+				desc.ModeGroups = append(desc.ModeGroups, group)
+			}
+		case "Quirks":
+			err = desc.Quirks.parse(section)
+		default:
+			err = fmt.Errorf("line %d: unknown section %q", section.line, section.name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := desc.validate(); err != nil {
+		return nil, err
+	}
+	return desc, nil
+}
+
+func (desc *DeviceDescriptor) parseDevice(section *keyFileSection) error {
+	for _, entry := range section.entries {
+		var err error
+		switch entry.key {
+		case "Name":
+			desc.Name = entry.value
+		case "Type":
+			desc.Type, err = parseDeviceType(entry.value)
+		case "DeviceMatch":
+			for _, v := range keyFileList(entry.value) {
+				var match DeviceMatch
+				if match, err = parseDeviceMatch(v); err != nil {
+					break
+				}
+				desc.Match = append(desc.Match, match)
+			}
+		case "Priority":
+			desc.Priority, err = strconv.Atoi(entry.value)
+		case "WidthMillimeters":
+			desc.WidthMillimeters, err = strconv.ParseFloat(entry.value, 64)
+		case "HeightMillimeters":
+			desc.HeightMillimeters, err = strconv.ParseFloat(entry.value, 64)
+		default:
+			err = fmt.Errorf("unknown key")
+		}
+		if err != nil {
+			return entry.error(err)
+		}
+	}
+	return nil
+}
+
+func parseSubDeviceDescriptor(section *keyFileSection) (*SubDeviceDescriptor, error) {
+	sub := &SubDeviceDescriptor{}
+	for _, entry := range section.entries {
+		var err error
+		switch entry.key {
+		case "NamePattern":
+			sub.NamePattern, err = regexp.Compile(entry.value)
+		case "X":
+			sub.X, err = parseAxisRange(entry.value)
+		case "Y":
+			sub.Y, err = parseAxisRange(entry.value)
+		case "Pressure":
+			sub.Pressure, err = parseAxisRange(entry.value)
+		case "Distance":
+			sub.Distance, err = parseAxisRange(entry.value)
+		case "Buttons":
+			sub.Buttons, err = parseButtons(entry.value)
+		case "Rings":
+			sub.Rings, err = parseAxisRanges(entry.value)
+		case "Strips":
+			sub.Strips, err = parseAxisRanges(entry.value)
+		default:
+			err = fmt.Errorf("unknown key")
+		}
+		if err != nil {
+			return nil, entry.error(err)
+		}
+	}
+	if sub.NamePattern == nil {
+		return nil, fmt.Errorf("line %d: section %s lacks NamePattern", section.line, section.name)
+	}
+	return sub, nil
+}
+
+func parsePadModeGroup(section *keyFileSection) (group PadModeGroup, err error) {
+	group.Modes = 1
+	for _, entry := range section.entries {
+		switch entry.key {
+		case "Modes":
+			group.Modes, err = strconv.Atoi(entry.value)
+		case "Toggle":
+			group.Toggle, err = parseButtons(entry.value)
+		case "Buttons":
+			group.Buttons, err = parseButtons(entry.value)
+		case "Rings":
+			group.Rings, err = parseInts(entry.value)
+		case "Strips":
+			group.Strips, err = parseInts(entry.value)
+		default:
+			err = fmt.Errorf("unknown key")
+		}
+		if err != nil {
+			return group, entry.error(err)
+		}
+	}
+	return group, nil
+}
+
+func (quirks *DeviceQuirks) parse(section *keyFileSection) error {
+	for _, entry := range section.entries {
+		var err error
+		switch entry.key {
+		case "ClearDistanceOnPressure":
+			quirks.ClearDistanceOnPressure, err = strconv.ParseBool(entry.value)
+		default:
+			err = fmt.Errorf("unknown key")
+		}
+		if err != nil {
+			return entry.error(err)
+		}
+	}
+	return nil
+}
+
+func (entry *keyFileEntry) error(err error) error {
+	return fmt.Errorf("line %d: %s: %s", entry.line, entry.key, err)
+}
+
+// validate checks that descriptor holds what is needed to support a device.
+func (desc *DeviceDescriptor) validate() error {
+	if desc.Name == "" {
+		return fmt.Errorf("device name missing")
+	}
+	if desc.Pen == nil && desc.Finger == nil && desc.Pad == nil {
+		return fmt.Errorf("%s: no sub-devices", desc.Name)
+	}
+	if desc.Pen != nil || desc.Finger != nil {
+		if desc.WidthMillimeters <= 0 || desc.HeightMillimeters <= 0 {
+			return fmt.Errorf("%s: pad size missing", desc.Name)
+		}
+	}
+	for _, v := range []struct {
+		name     string
+		sub      *SubDeviceDescriptor
+		pressure bool
+	}{
+		{"pen", desc.Pen, true},
+		{"finger", desc.Finger, false},
+	} {
+		if v.sub == nil {
+			continue
+		}
+		if v.sub.X.empty() || v.sub.Y.empty() {
+			return fmt.Errorf("%s: %s position ranges missing", desc.Name, v.name)
+		}
+		if v.pressure && v.sub.Pressure.empty() {
+			return fmt.Errorf("%s: %s pressure range missing", desc.Name, v.name)
+		}
+	}
+	return desc.validateModeGroups()
+}
+
+// validateModeGroups checks that mode groups refer to controls of the pad and
+// that no control is in more than one group.
+func (desc *DeviceDescriptor) validateModeGroups() error {
+	if len(desc.ModeGroups) == 0 {
+		return nil
+	}
+	if desc.Pad == nil {
+		return fmt.Errorf("%s: mode groups without pad", desc.Name)
+	}
+	buttons := map[Button]bool{}
+	rings := map[int]bool{}
+	strips := map[int]bool{}
+	for i := range desc.ModeGroups {
+		g := &desc.ModeGroups[i]
+		if g.Modes < 1 {
+			return fmt.Errorf("%s: mode group %d: at least one mode needed", desc.Name, i)
+		}
+		if g.Modes > 1 && len(g.Toggle) == 0 {
+			return fmt.Errorf("%s: mode group %d: toggle button missing", desc.Name, i)
+		}
+		for _, button := range append(append([]Button(nil), g.Toggle...), g.Buttons...) {
+			if buttons[button] {
+				return fmt.Errorf("%s: mode group %d: button %s is in more than one group", desc.Name, i, button)
+			}
+			buttons[button] = true
+		}
+		for _, v := range []struct {
+			name    string
+			indices []int
+			count   int
+			seen    map[int]bool
+		}{
+			{"ring", g.Rings, len(desc.Pad.Rings), rings},
+			{"strip", g.Strips, len(desc.Pad.Strips), strips},
+		} {
+			for _, index := range v.indices {
+				if index < 0 || index >= v.count {
+					return fmt.Errorf("%s: mode group %d: pad has no %s %d", desc.Name, i, v.name, index)
+				}
+				if v.seen[index] {
+					return fmt.Errorf("%s: mode group %d: %s %d is in more than one group", desc.Name, i, v.name, index)
+				}
+				v.seen[index] = true
+			}
+		}
+	}
+	return nil
+}
+
+// properties returns the properties of the described device.
+func (desc *DeviceDescriptor) properties() Properties {
+	props := Properties{
+		PropertyDeviceName: PropertyValueString(desc.Name),
+		PropertyDeviceType: PropertyValueString(desc.Type.String()),
+	}
+	if desc.WidthMillimeters > 0 && desc.HeightMillimeters > 0 {
+		props[PropertyPadWidthMillimeters] = PropertyValueNumber(desc.WidthMillimeters)
+		props[PropertyPadHeightMillimeters] = PropertyValueNumber(desc.HeightMillimeters)
+		props[PropertyPadWidthHeightRatio] = PropertyValueNumber(desc.WidthMillimeters / desc.HeightMillimeters)
+	}
+	if pad := desc.Pad; pad != nil {
+		if len(pad.Rings) > 0 {
+			props[PropertyRings] = PropertyValueNumber(len(pad.Rings))
+		}
+		if len(pad.Strips) > 0 {
+			props[PropertyStrips] = PropertyValueNumber(len(pad.Strips))
+		}
+	}
+	return props
+}
+
+// capabilities returns the capabilities of the described device.
+func (desc *DeviceDescriptor) capabilities() Capabilities {
+	var caps Capabilities
+	if desc.Pen != nil {
+		caps.PositionDevices = append(caps.PositionDevices, PositionDevicePen)
+	}
+	if desc.Finger != nil {
+		caps.PositionDevices = append(caps.PositionDevices, PositionDeviceFinger)
+	}
+	for _, sub := range []*SubDeviceDescriptor{desc.Pen, desc.Pad, desc.Finger} {
+		if sub == nil {
+			continue
+		}
+		for _, button := range sub.Buttons {
+			if !caps.HasButton(button) {
+				caps.Buttons = append(caps.Buttons, button)
+			}
+		}
+	}
+	return caps
+}
+
+// String returns the descriptor in the format read by ParseDeviceDescriptor.
+func (desc *DeviceDescriptor) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[Device]\nName=%s\nType=%s\n", desc.Name, desc.Type)
+	if len(desc.Match) > 0 {
+		var matches []string
+		for _, v := range desc.Match {
+			matches = append(matches, fmt.Sprintf("%s:%04x:%04x", strings.ToLower(v.BusType.String()), v.Vendor, v.Product))
+		}
+		fmt.Fprintf(&sb, "DeviceMatch=%s\n", strings.Join(matches, ";"))
+	}
+	if desc.Priority != 0 {
+		fmt.Fprintf(&sb, "Priority=%d\n", desc.Priority)
+	}
+	fmt.Fprintf(&sb, "WidthMillimeters=%s\nHeightMillimeters=%s\n",
+		strconv.FormatFloat(desc.WidthMillimeters, 'f', -1, 64), strconv.FormatFloat(desc.HeightMillimeters, 'f', -1, 64))
+
+	for _, v := range []struct {
+		name string
+		sub  *SubDeviceDescriptor
+	}{
+		{"Pen", desc.Pen},
+		{"Finger", desc.Finger},
+		{"Pad", desc.Pad},
+	} {
+		if v.sub == nil {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n[%s]\n", v.name)
+		if v.sub.NamePattern != nil {
+			fmt.Fprintf(&sb, "NamePattern=%s\n", v.sub.NamePattern)
+		}
+		for _, axis := range []struct {
+			name string
+			r    AxisRange
+		}{
+			{"X", v.sub.X},
+			{"Y", v.sub.Y},
+			{"Pressure", v.sub.Pressure},
+			{"Distance", v.sub.Distance},
+		} {
+			if !axis.r.empty() {
+				fmt.Fprintf(&sb, "%s=%d:%d\n", axis.name, axis.r.Min, axis.r.Max)
+			}
+		}
+		if len(v.sub.Buttons) > 0 {
+			fmt.Fprintf(&sb, "Buttons=%s\n", formatButtons(v.sub.Buttons))
+		}
+		for _, axes := range []struct {
+			name   string
+			ranges []AxisRange
+		}{
+			{"Rings", v.sub.Rings},
+			{"Strips", v.sub.Strips},
+		} {
+			if len(axes.ranges) > 0 {
+				var ranges []string
+				for _, r := range axes.ranges {
+					ranges = append(ranges, fmt.Sprintf("%d:%d", r.Min, r.Max))
+				}
+				fmt.Fprintf(&sb, "%s=%s\n", axes.name, strings.Join(ranges, ";"))
+			}
+		}
+	}
+
+	for _, g := range desc.ModeGroups {
+		fmt.Fprintf(&sb, "\n[ModeGroup]\nModes=%d\n", g.Modes)
+		if len(g.Toggle) > 0 {
+			fmt.Fprintf(&sb, "Toggle=%s\n", formatButtons(g.Toggle))
+		}
+		if len(g.Buttons) > 0 {
+			fmt.Fprintf(&sb, "Buttons=%s\n", formatButtons(g.Buttons))
+		}
+		if len(g.Rings) > 0 {
+			fmt.Fprintf(&sb, "Rings=%s\n", formatInts(g.Rings))
+		}
+		if len(g.Strips) > 0 {
+			fmt.Fprintf(&sb, "Strips=%s\n", formatInts(g.Strips))
+		}
+	}
+
+	if desc.Quirks.ClearDistanceOnPressure {
+		fmt.Fprintf(&sb, "\n[Quirks]\nClearDistanceOnPressure=true\n")
+	}
+	return sb.String()
+}
+
+// matchID checks if the identity is matched by the descriptor.
+func (desc *DeviceDescriptor) matchID(id *DeviceIdentity) bool {
+	if len(desc.Match) == 0 {
+		return true
+	}
+	for _, v := range desc.Match {
+		if v.BusType == id.BusType && v.Vendor == id.Vendor && v.Product == id.Product {
+			return true
+		}
+	}
+	return false
+}
+
+func parseAxisRange(s string) (r AxisRange, err error) {
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return r, fmt.Errorf("expected min:max, got %q", s)
+	}
+	min, err := strconv.ParseInt(strings.TrimSpace(s[:i]), 10, 32)
+	if err != nil {
+		return r, err
+	}
+	max, err := strconv.ParseInt(strings.TrimSpace(s[i+1:]), 10, 32)
+	if err != nil {
+		return r, err
+	}
+	return AxisRange{Min: int32(min), Max: int32(max)}, nil
+}
+
+// parseAxisRanges parses a list of ranges such as "0:71;0:71".
+func parseAxisRanges(s string) ([]AxisRange, error) {
+	var ranges []AxisRange
+	for _, v := range keyFileList(s) {
+		r, err := parseAxisRange(v)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// parseInts parses a list of integers such as "0;1".
+func parseInts(s string) ([]int, error) {
+	var ints []int
+	for _, v := range keyFileList(s) {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		ints = append(ints, n)
+	}
+	return ints, nil
+}
+
+func formatInts(ints []int) string {
+	var s []string
+	for _, n := range ints {
+		s = append(s, strconv.Itoa(n))
+	}
+	return strings.Join(s, ";")
+}
+
+// parseDeviceMatch parses a match such as "usb:056a:00df".
+func parseDeviceMatch(s string) (match DeviceMatch, err error) {
+	fields := strings.Split(s, ":")
+	if len(fields) != 3 {
+		return match, fmt.Errorf("expected bus:vendor:product, got %q", s)
+	}
+	if match.BusType, err = parseBusType(fields[0]); err != nil {
+		return
+	}
+	var n uint64
+	if n, err = strconv.ParseUint(fields[1], 16, 16); err != nil {
+		return
+	}
+	match.Vendor = uint16(n)
+	if n, err = strconv.ParseUint(fields[2], 16, 16); err != nil {
+		return
+	}
+	match.Product = uint16(n)
+	return
+}
+
+// parseBusType parses the lower case name of a bus type, e.g. "usb".
+func parseBusType(s string) (BusType, error) {
+	for _, v := range []BusType{BusTypePCI, BusTypeUSB, BusTypeBluetooth, BusTypeVirtual, BusTypeI2C, BusTypeHost, BusTypeSPI} {
+		if strings.ToLower(v.String()) == s {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown bus %q", s)
+}
+
+// parseButton parses the name of a button without prefix, e.g. "Pen1".
+func parseButton(s string) (Button, error) {
+	for v := Button(0); !strings.HasPrefix(v.String(), "Button("); v++ {
+		if v.String() == s {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown button %q", s)
+}
+
+// parseButtons parses a list of button names such as "Left;Right".
+func parseButtons(s string) ([]Button, error) {
+	var buttons []Button
+	for _, v := range keyFileList(s) {
+		button, err := parseButton(v)
+		if err != nil {
+			return nil, err
+		}
+		buttons = append(buttons, button)
+	}
+	return buttons, nil
+}
+
+func formatButtons(buttons []Button) string {
+	var s []string
+	for _, button := range buttons {
+		s = append(s, button.String())
+	}
+	return strings.Join(s, ";")
+}
+
+// parseDeviceType parses the name of a device type without prefix, e.g. "Tablet".
+func parseDeviceType(s string) (DeviceType, error) {
+	for v := DeviceType(0); !strings.HasPrefix(v.String(), "DeviceType("); v++ {
+		if v.String() == s {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown device type %q", s)
+}
+
+// Descriptors of supported devices embedded in the package.
+//
+//go:embed devices/*.device
+var embeddedDescriptorFiles embed.FS
+
+var embeddedDescriptors = mustParseEmbeddedDescriptors()
+
+func mustParseEmbeddedDescriptors() []*DeviceDescriptor {
+	names, err := embeddedDescriptorFiles.ReadDir("devices")
+	if err != nil {
+		panic(err)
+	}
+	var list []*DeviceDescriptor
+	for _, v := range names {
+		f, err := embeddedDescriptorFiles.Open("devices/" + v.Name())
+		if err != nil {
+			panic(err)
+		}
+		desc, err := ParseDeviceDescriptor(f)
+		f.Close()
+		if err != nil {
+			panic(fmt.Sprintf("embedded device descriptor %s: %s", v.Name(), err))
+		}
+		list = append(list, desc)
+	}
+	return list
+}
+
+var descriptors struct {
+	sync.Mutex
+	list []*DeviceDescriptor
+}
+
+// RegisterDeviceDescriptor registers a device descriptor that is used by
+// ListDevices. Registered descriptors take precedence over embedded
+// descriptors with the same priority.
+func RegisterDeviceDescriptor(desc *DeviceDescriptor) error {
+	if err := desc.validate(); err != nil {
+		return err
+	}
+	descriptors.Lock()
+	descriptors.list = append(descriptors.list, desc)
+	descriptors.Unlock()
+	return nil
+}
+
+// LoadDeviceDescriptors parses and registers all device descriptor files
+// (*.device) in directory dir. Nothing is registered if any file fails to
+// parse.
+func LoadDeviceDescriptors(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.device"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	var list []*DeviceDescriptor
+	for _, path := range paths {
+		desc, err := loadDeviceDescriptor(path)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		list = append(list, desc)
+	}
+	for _, desc := range list {
+		RegisterDeviceDescriptor(desc)
+	}
+	return nil
+}
+
+func loadDeviceDescriptor(path string) (*DeviceDescriptor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseDeviceDescriptor(f)
+}
+
+// deviceDescriptors returns registered descriptors followed by embedded
+// descriptors.
+func deviceDescriptors() []*DeviceDescriptor {
+	descriptors.Lock()
+	defer descriptors.Unlock()
+	return append(append([]*DeviceDescriptor(nil), descriptors.list...), embeddedDescriptors...)
+}
@@ -0,0 +1,114 @@
+package chimp
+
+import (
+	evdev "github.com/johan-bolmsjo/golang-evdev"
+)
+
+// deviceMatcherDescriptor matches devices described by a device descriptor.
+type deviceMatcherDescriptor struct {
+	desc *DeviceDescriptor
+}
+
+func (matcher *deviceMatcherDescriptor) name() string {
+	return matcher.desc.Name
+}
+
+func (matcher *deviceMatcherDescriptor) priority() int {
+	return matcher.desc.Priority
+}
+
+func (matcher *deviceMatcherDescriptor) match(devInfo linuxDeviceInfo) (match bool, logicalID string) {
+	if _, ok := descriptorDeviceType(matcher.desc, devInfo.name); ok && matcher.desc.matchID(&devInfo.identity) {
+		match = true
+		logicalID = matcher.desc.Name + " " + devInfo.group
+	}
+	return
+}
+
+func (matcher *deviceMatcherDescriptor) newLogicalDevice() logicalDevice {
+	return &logicalDeviceDescriptor{desc: matcher.desc}
+}
+
+// descriptorDeviceType returns the sub-device that the name of a Linux device
+// matches.
+func descriptorDeviceType(desc *DeviceDescriptor, name string) (wacomLinuxDeviceType, bool) {
+	// matches order of wacomLinuxDeviceType
+	for i, sub := range [wacomLinuxDeviceTypes]*SubDeviceDescriptor{desc.Pen, desc.Finger, desc.Pad} {
+		if sub != nil && sub.NamePattern.MatchString(name) {
+			return wacomLinuxDeviceType(i), true
+		}
+	}
+	return 0, false
+}
+
+type logicalDeviceDescriptor struct {
+	desc         *DeviceDescriptor
+	linuxDevices [wacomLinuxDeviceTypes]linuxDeviceInfo
+}
+
+func (logicalDevice *logicalDeviceDescriptor) addLinuxDevice(devInfo linuxDeviceInfo) {
+	if i, ok := descriptorDeviceType(logicalDevice.desc, devInfo.name); ok {
+		logicalDevice.linuxDevices[i] = devInfo
+	}
+}
+
+func (logicalDevice *logicalDeviceDescriptor) deviceInfo() DeviceInfo {
+	identity := logicalDevice.identity()
+	return DeviceInfo{
+		Name:     logicalDevice.desc.Name,
+		Type:     logicalDevice.desc.Type,
+		Identity: identity,
+		ID:       identity.StableID(),
+		Open:     logicalDevice.Open,
+		OpenWith: logicalDevice.OpenWith,
+	}
+}
+
+// identity returns the identity of the first found Linux device, all of them
+// belong to the same physical device.
+func (logicalDevice *logicalDeviceDescriptor) identity() DeviceIdentity {
+	for _, v := range logicalDevice.linuxDevices {
+		if v.dev != "" {
+			return v.identity
+		}
+	}
+	return DeviceIdentity{}
+}
+
+func (logicalDevice *logicalDeviceDescriptor) Open() (Device, error) {
+	return logicalDevice.OpenWith(OpenOptions{})
+}
+
+func (logicalDevice *logicalDeviceDescriptor) OpenWith(opts OpenOptions) (Device, error) {
+	var err error
+	var inputDevices [wacomLinuxDeviceTypes]*evdev.InputDevice
+
+	closeInputDevices := func() {
+		for _, v := range inputDevices {
+			if v != nil {
+				v.File.Close()
+			}
+		}
+	}
+
+	for i, v := range logicalDevice.linuxDevices {
+		if v.dev != "" {
+			if inputDevices[i], err = v.openDevice(opts); err != nil {
+				closeInputDevices()
+				return nil, err
+			}
+		}
+	}
+
+	desc := logicalDevice.desc
+	identity := logicalDevice.identity()
+	properties := identity.properties(desc.properties())
+	capabilities := libwacomDescribe(&identity, properties, desc.capabilities())
+	dev, err := newWacomDevice(inputDevices, properties, capabilities, newWacomDeviceParams(desc), opts.Clock)
+	if err != nil {
+		closeInputDevices()
+		return nil, err
+	}
+	dev.loadConfig(dev, identity.StableID(), opts)
+	return dev, nil
+}
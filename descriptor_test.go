@@ -0,0 +1,354 @@
+package chimp
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testDescriptorPad = `# Pad with a ring and a strip.
+[Device]
+Name=Example Tablet
+Type=Tablet
+DeviceMatch=usb:056a:0357;bluetooth:056a:0360
+Priority=2
+WidthMillimeters=224.5
+HeightMillimeters=148
+
+[Pen]
+NamePattern=^Example Tablet Pen$
+X=0:44800
+Y=0:29600
+Pressure=0:8191
+Buttons=PenTip;Pen1
+
+[Pad]
+NamePattern=^Example Tablet Pad$
+Buttons=Left;Right;Forward;Back
+Rings=0:71
+Strips=0:4096
+
+[ModeGroup]
+Modes=4
+Toggle=Left
+Buttons=Right
+Rings=0
+
+[ModeGroup]
+Buttons=Forward;Back
+Strips=0
+
+[Quirks]
+ClearDistanceOnPressure=true
+`
+
+func TestParseKeyFile(t *testing.T) {
+	for _, test := range []struct {
+		name, data string
+		want       []keyFileSection
+		err        string
+	}{
+		{"empty", "", nil, ""},
+		{
+			"sections",
+			"# Comment\n\n[Device]\nName = Tablet \n  [ Pen ]  \nX=0:1\nX=2:3\n[Empty]\n",
+			[]keyFileSection{
+				{name: "Device", line: 3, entries: []keyFileEntry{{"Name", "Tablet", 4}}},
+				{name: "Pen", line: 5, entries: []keyFileEntry{{"X", "0:1", 6}, {"X", "2:3", 7}}},
+				{name: "Empty", line: 8},
+			},
+			"",
+		},
+		{"value with separator", "[A]\nB=C=D\n", []keyFileSection{{name: "A", line: 1, entries: []keyFileEntry{{"B", "C=D", 2}}}}, ""},
+		{"malformed section header", "[Device\nName=Tablet\n", nil, "line 1: malformed section header"},
+		{"missing value", "[Device]\nName\n", nil, "line 2: expected key=value"},
+		{"entry outside of section", "# Comment\nName=Tablet\n", nil, "line 2: entry outside of section"},
+	} {
+		kf, err := parseKeyFile(strings.NewReader(test.data))
+		if test.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		var got []keyFileSection
+		for _, section := range kf.sections {
+			got = append(got, *section)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestKeyFileValues(t *testing.T) {
+	kf, err := parseKeyFile(strings.NewReader("[A]\nB=1\nB=2\n[A]\nB=3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first section and the last entry with a key is used.
+	if v, ok := kf.section("A").value("B"); !ok || v != "2" {
+		t.Errorf("got %q %t, want \"2\"", v, ok)
+	}
+	if _, ok := kf.section("Missing").value("B"); ok {
+		t.Error("value found in missing section")
+	}
+	if got, want := keyFileList(" a; ;b ;"), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got list %q, want %q", got, want)
+	}
+}
+
+func TestParseDeviceDescriptor(t *testing.T) {
+	desc, err := ParseDeviceDescriptor(strings.NewReader(testDescriptorPad))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Name != "Example Tablet" || desc.Type != DeviceTypeTablet || desc.Priority != 2 ||
+		desc.WidthMillimeters != 224.5 || desc.HeightMillimeters != 148 || !desc.Quirks.ClearDistanceOnPressure {
+		t.Errorf("device %+v", desc)
+	}
+	wantMatch := []DeviceMatch{
+		{BusType: BusTypeUSB, Vendor: 0x056a, Product: 0x0357},
+		{BusType: BusTypeBluetooth, Vendor: 0x056a, Product: 0x0360},
+	}
+	if !reflect.DeepEqual(desc.Match, wantMatch) {
+		t.Errorf("matches %v, want %v", desc.Match, wantMatch)
+	}
+	if desc.Finger != nil {
+		t.Error("finger present")
+	}
+	pen := desc.Pen
+	if pen == nil || pen.NamePattern.String() != "^Example Tablet Pen$" ||
+		pen.X != (AxisRange{0, 44800}) || pen.Y != (AxisRange{0, 29600}) || pen.Pressure != (AxisRange{0, 8191}) ||
+		!pen.Distance.empty() || !reflect.DeepEqual(pen.Buttons, []Button{ButtonPenTip, ButtonPen1}) {
+		t.Errorf("pen %+v", pen)
+	}
+	pad := desc.Pad
+	if pad == nil || !reflect.DeepEqual(pad.Rings, []AxisRange{{0, 71}}) || !reflect.DeepEqual(pad.Strips, []AxisRange{{0, 4096}}) {
+		t.Errorf("pad %+v", pad)
+	}
+	wantGroups := []PadModeGroup{
+		{Modes: 4, Toggle: []Button{ButtonLeft}, Buttons: []Button{ButtonRight}, Rings: []int{0}},
+		{Modes: 1, Buttons: []Button{ButtonForward, ButtonBack}, Strips: []int{0}},
+	}
+	if !reflect.DeepEqual(desc.ModeGroups, wantGroups) {
+		t.Errorf("mode groups %+v, want %+v", desc.ModeGroups, wantGroups)
+	}
+}
+
+func TestParseDeviceDescriptorErrors(t *testing.T) {
+	const device = "[Device]\nName=Tablet\nType=Tablet\nWidthMillimeters=200\nHeightMillimeters=100\n"
+	const pen = "[Pen]\nNamePattern=Pen\nX=0:100\nY=0:100\nPressure=0:1023\n"
+	const pad = "[Pad]\nNamePattern=Pad\nButtons=Left;Right\nRings=0:71\n"
+
+	for _, test := range []struct {
+		name, data, err string
+	}{
+		{"malformed key file", "[Device\n", "line 1: malformed section header"},
+		{"unknown section", device + pen + "[Eraser]\n", `line 11: unknown section "Eraser"`},
+		{"unknown key", device + "Color=Black\n" + pen, "line 6: Color: unknown key"},
+		{"unknown device type", "[Device]\nType=Phone\n", `line 2: Type: unknown device type "Phone"`},
+		{"malformed device match", "[Device]\nDeviceMatch=usb:056a\n", `line 2: DeviceMatch: expected bus:vendor:product, got "usb:056a"`},
+		{"unknown bus", "[Device]\nDeviceMatch=serial:056a:00df\n", `line 2: DeviceMatch: unknown bus "serial"`},
+		{"malformed product", "[Device]\nDeviceMatch=usb:056a:xyz\n", "line 2: DeviceMatch: strconv.ParseUint"},
+		{"malformed priority", "[Device]\nPriority=high\n", "line 2: Priority: strconv.Atoi"},
+		{"malformed size", "[Device]\nWidthMillimeters=wide\n", "line 2: WidthMillimeters: strconv.ParseFloat"},
+		{"malformed name pattern", device + "[Pen]\nNamePattern=(\n", "line 7: NamePattern: error parsing regexp"},
+		{"name pattern missing", device + "[Pen]\nX=0:100\n", "line 6: section Pen lacks NamePattern"},
+		{"malformed range", device + "[Pen]\nX=100\n", `line 7: X: expected min:max, got "100"`},
+		{"malformed range bound", device + "[Pen]\nX=0:wide\n", "line 7: X: strconv.ParseInt"},
+		{"unknown button", device + "[Pad]\nButtons=Left;Shift\n", `line 7: Buttons: unknown button "Shift"`},
+		{"malformed ring index", device + pad + "[ModeGroup]\nRings=first\n", "line 11: Rings: strconv.Atoi"},
+		{"malformed quirk", device + pen + "[Quirks]\nClearDistanceOnPressure=maybe\n", "line 12: ClearDistanceOnPressure: strconv.ParseBool"},
+		{"invalid descriptor", "[Device]\nName=Tablet\n", "Tablet: no sub-devices"},
+	} {
+		_, err := ParseDeviceDescriptor(strings.NewReader(test.data))
+		if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestDeviceDescriptorValidate(t *testing.T) {
+	// valid returns a descriptor with a pen and a pad with a ring.
+	valid := func() *DeviceDescriptor {
+		desc, err := ParseDeviceDescriptor(strings.NewReader(testDescriptorPad))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return desc
+	}
+
+	for _, test := range []struct {
+		name   string
+		modify func(desc *DeviceDescriptor)
+		err    string
+	}{
+		{"valid", func(desc *DeviceDescriptor) {}, ""},
+		{"pad only without size", func(desc *DeviceDescriptor) {
+			desc.Pen = nil
+			desc.WidthMillimeters, desc.HeightMillimeters = 0, 0
+		}, ""},
+		{"name missing", func(desc *DeviceDescriptor) { desc.Name = "" }, "device name missing"},
+		{"no sub-devices", func(desc *DeviceDescriptor) {
+			desc.Pen, desc.Pad, desc.ModeGroups = nil, nil, nil
+		}, "Example Tablet: no sub-devices"},
+		{"pad size missing", func(desc *DeviceDescriptor) { desc.HeightMillimeters = 0 }, "Example Tablet: pad size missing"},
+		{"pen position missing", func(desc *DeviceDescriptor) { desc.Pen.Y = AxisRange{} }, "Example Tablet: pen position ranges missing"},
+		{"pen pressure missing", func(desc *DeviceDescriptor) { desc.Pen.Pressure = AxisRange{} }, "Example Tablet: pen pressure range missing"},
+		{"finger position missing", func(desc *DeviceDescriptor) {
+			desc.Finger = &SubDeviceDescriptor{X: AxisRange{0, 4095}}
+		}, "Example Tablet: finger position ranges missing"},
+		{"finger without pressure", func(desc *DeviceDescriptor) {
+			desc.Finger = &SubDeviceDescriptor{X: AxisRange{0, 4095}, Y: AxisRange{0, 4095}}
+		}, ""},
+		{"mode groups without pad", func(desc *DeviceDescriptor) { desc.Pad = nil }, "Example Tablet: mode groups without pad"},
+		{"no modes", func(desc *DeviceDescriptor) { desc.ModeGroups[1].Modes = 0 }, "Example Tablet: mode group 1: at least one mode needed"},
+		{"toggle missing", func(desc *DeviceDescriptor) { desc.ModeGroups[0].Toggle = nil }, "Example Tablet: mode group 0: toggle button missing"},
+		{"button in two groups", func(desc *DeviceDescriptor) {
+			desc.ModeGroups[1].Buttons = []Button{ButtonLeft}
+		}, "Example Tablet: mode group 1: button Left is in more than one group"},
+		{"ring missing", func(desc *DeviceDescriptor) { desc.ModeGroups[0].Rings = []int{1} }, "Example Tablet: mode group 0: pad has no ring 1"},
+		{"strip missing", func(desc *DeviceDescriptor) { desc.ModeGroups[1].Strips = []int{-1} }, "Example Tablet: mode group 1: pad has no strip -1"},
+		{"ring in two groups", func(desc *DeviceDescriptor) {
+			desc.ModeGroups[1].Rings = []int{0}
+		}, "Example Tablet: mode group 1: ring 0 is in more than one group"},
+	} {
+		desc := valid()
+		test.modify(desc)
+		err := desc.validate()
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %s", test.name, err)
+		case test.err != "" && (err == nil || err.Error() != test.err):
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestDeviceDescriptorString(t *testing.T) {
+	texts := []string{testDescriptorPad}
+	for _, desc := range embeddedDescriptors {
+		texts = append(texts, desc.String())
+	}
+
+	// Parsing the string of a descriptor gives the same descriptor and string.
+	for _, text := range texts {
+		desc, err := ParseDeviceDescriptor(strings.NewReader(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := desc.String()
+		again, err := ParseDeviceDescriptor(strings.NewReader(s))
+		if err != nil {
+			t.Fatalf("%s: %s in:\n%s", desc.Name, err, s)
+		}
+		if !reflect.DeepEqual(again, desc) {
+			t.Errorf("%s: got %+v, want %+v", desc.Name, again, desc)
+		}
+		if again.String() != s {
+			t.Errorf("%s: got string:\n%s\nwant:\n%s", desc.Name, again.String(), s)
+		}
+	}
+}
+
+func TestDeviceDescriptorMatchID(t *testing.T) {
+	desc, err := ParseDeviceDescriptor(strings.NewReader(testDescriptorPad))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		id   DeviceIdentity
+		want bool
+	}{
+		{DeviceIdentity{BusType: BusTypeUSB, Vendor: 0x056a, Product: 0x0357, Version: 0x0110}, true},
+		{DeviceIdentity{BusType: BusTypeBluetooth, Vendor: 0x056a, Product: 0x0360}, true},
+		{DeviceIdentity{BusType: BusTypeBluetooth, Vendor: 0x056a, Product: 0x0357}, false},
+		{DeviceIdentity{BusType: BusTypeUSB, Vendor: 0x056b, Product: 0x0357}, false},
+	} {
+		if got := desc.matchID(&test.id); got != test.want {
+			t.Errorf("%s: matched %t, want %t", &test.id, got, test.want)
+		}
+	}
+
+	// Descriptors without IDs match on name alone.
+	desc.Match = nil
+	if !desc.matchID(&DeviceIdentity{BusType: BusTypeVirtual}) {
+		t.Error("descriptor without IDs not matched")
+	}
+}
+
+// useDescriptors replaces the registered descriptors until the test ends.
+func useDescriptors(t *testing.T) {
+	descriptors.Lock()
+	saved := descriptors.list
+	descriptors.list = nil
+	descriptors.Unlock()
+	t.Cleanup(func() {
+		descriptors.Lock()
+		descriptors.list = saved
+		descriptors.Unlock()
+	})
+}
+
+func TestLoadDeviceDescriptors(t *testing.T) {
+	useDescriptors(t)
+	writeFiles := func(files map[string]string) string {
+		dir := t.TempDir()
+		for name, data := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+	registered := func() []string {
+		var names []string
+		for _, desc := range deviceDescriptors() {
+			names = append(names, desc.Name)
+		}
+		return names
+	}
+	embedded := registered()
+	other := strings.Replace(testDescriptorPad, "Example Tablet", "Other Tablet", -1)
+
+	// Nothing is registered if any file fails to parse.
+	dir := writeFiles(map[string]string{
+		"a.device": testDescriptorPad,
+		"b.device": "[Device]\nName=Broken\n",
+	})
+	err := LoadDeviceDescriptors(dir)
+	if want := filepath.Join(dir, "b.device") + ": Broken: no sub-devices"; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+	if got := registered(); !reflect.DeepEqual(got, embedded) {
+		t.Errorf("registered %q after error, want %q", got, embedded)
+	}
+
+	// Files are registered in name order, before embedded descriptors. Other
+	// files are ignored.
+	dir = writeFiles(map[string]string{
+		"b.device":   testDescriptorPad,
+		"a.device":   other,
+		"notes.txt":  "Not a descriptor",
+		"c.device~":  "[Device\n",
+		"empty.conf": "",
+	})
+	if err := LoadDeviceDescriptors(dir); err != nil {
+		t.Fatal(err)
+	}
+	want := append([]string{"Other Tablet", "Example Tablet"}, embedded...)
+	if got := registered(); !reflect.DeepEqual(got, want) {
+		t.Errorf("registered %q, want %q", got, want)
+	}
+
+	if err := LoadDeviceDescriptors(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("missing directory: %s", err)
+	}
+}
@@ -1,11 +1,29 @@
 package chimp
 
+import "context"
+
 // DeviceInfo contains some brief device information that can be inspected
 // before deciding to open a device.
 type DeviceInfo struct {
-	Name string                 // Name of device.
-	Type DeviceType             // Device type.
-	Open func() (Device, error) // Function that opens the device.
+	Name     string                                 // Name of device.
+	Type     DeviceType                             // Device type.
+	Identity DeviceIdentity                         // Bus, vendor, product and unique ID of device.
+	ID       string                                 // Stable identifier of device, see DeviceIdentity.StableID.
+	Open     func() (Device, error)                 // Function that opens the device with default options.
+	OpenWith func(opts OpenOptions) (Device, error) // Function that opens the device with options.
+}
+
+// OpenOptions holds options used when opening a device.
+type OpenOptions struct {
+	Clock Clock // Clock used for event timestamps.
+
+	// Directory of device configuration files, DeviceConfigDir if empty.
+	// See DeviceConfig. The configuration file is polled for changes by a
+	// goroutine that is stopped when the device is closed.
+	ConfigDir string
+
+	// Don't load or apply device configuration.
+	NoConfig bool
 }
 
 // DeviceType is an enumeration of basic device types such as "Mouse", "Tablet" etc.
@@ -26,10 +44,29 @@ type Device interface {
 	// Capabilities returns capabilities of device.
 	Capabilities() *Capabilities
 
-	// Read event from device.
-	// TODO: Define how to handle errors, reopening device?
+	// Read event from device. The device is closed on errors, which match
+	// the package errors such as ErrDisconnected with errors.Is when the
+	// cause is known. ErrClosed is returned once the device is closed.
+	// TODO: Reopening device?
 	Read() (Event, error)
 
+	// ReadContext reads an event from device like Read but returns with the
+	// context error if the context is done before an event is available.
+	// The device remains open and may be read again.
+	ReadContext(ctx context.Context) (Event, error)
+
+	// ReadBatch reads all pending events that fit in buf and returns the
+	// number of events read. It blocks until at least one event is available
+	// or the context is done. Events read before an error are returned along
+	// with the error.
+	ReadBatch(ctx context.Context, buf []Event) (int, error)
+
+	// Stats returns event statistics of device.
+	Stats() Stats
+
+	// Clock returns the clock used for event timestamps.
+	Clock() Clock
+
 	// Close device.
 	Close()
 }
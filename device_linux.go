@@ -1,11 +1,12 @@
 package chimp
 
 import (
-	"errors"
-	"fmt"
-	"regexp"
+	"context"
 	"sort"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unsafe"
 
 	"github.com/johan-bolmsjo/chimp/internal/channel"
 	"github.com/johan-bolmsjo/golang-evdev"
@@ -17,33 +18,31 @@ func listDevices() ([]DeviceInfo, error) {
 		return nil, err
 	}
 
-	deviceMatchers := []deviceMatcher{
-		newDeviceMatcherWacomBamboo16FG6x8(),
-	}
-
+	matchers := deviceMatchers()
 	logicalDevices := map[string]logicalDevice{}
 
 	for _, dev := range devices {
+		devInfo := linuxDeviceInfo{
+			dev:      dev.Fn,
+			name:     dev.Name,
+			phys:     dev.Phys,
+			identity: inputDeviceIdentity(dev),
+		}
+		devInfo.group = linuxDeviceGroup(&devInfo)
+
 		// Close opened file to avoid leaking file descriptors. The
 		// parameters will be matched against saved parameters when a
 		// selected device is opened later on to make sure that device
 		// names has not been renumbered when replugging devices.
 		dev.File.Close()
 
-		devInfo := linuxDeviceInfo{
-			dev:  dev.Fn,
-			name: dev.Name,
-			phys: dev.Phys,
-		}
-		for _, matcher := range deviceMatchers {
-			if match, logicalID := matcher.match(devInfo); match {
-				logicalDevice := logicalDevices[logicalID]
-				if logicalDevice == nil {
-					logicalDevice = matcher.newLogicalDevice()
-					logicalDevices[logicalID] = logicalDevice
-				}
-				logicalDevice.addLinuxDevice(devInfo)
+		if matcher, logicalID := matchLinuxDevice(matchers, devInfo); matcher != nil {
+			logicalDevice := logicalDevices[logicalID]
+			if logicalDevice == nil {
+				logicalDevice = matcher.newLogicalDevice()
+				logicalDevices[logicalID] = logicalDevice
 			}
+			logicalDevice.addLinuxDevice(devInfo)
 		}
 	}
 
@@ -70,15 +69,44 @@ func listDevices() ([]DeviceInfo, error) {
 	return deviceInfo, nil
 }
 
-var (
-	reUSBDeviceID = regexp.MustCompile(`^(usb-[0-9a-z:.-]+)/input\d+$`)
-)
+// deviceMatchers returns matchers of all supported devices, device
+// descriptors and registered drivers, in order of decreasing priority.
+func deviceMatchers() []deviceMatcher {
+	var matchers []deviceMatcher
+	for _, desc := range deviceDescriptors() {
+		matchers = append(matchers, &deviceMatcherDescriptor{desc: desc})
+	}
+	for _, driver := range registeredDrivers() {
+		matchers = append(matchers, &driverMatcher{driver: driver})
+	}
+	sort.SliceStable(matchers, func(i, j int) bool { return matchers[i].priority() > matchers[j].priority() })
+	return matchers
+}
+
+// matchLinuxDevice returns the first of matchers that claims the Linux device
+// and the logical device ID it belongs to. Nil is returned if no matcher
+// claims it.
+func matchLinuxDevice(matchers []deviceMatcher, devInfo linuxDeviceInfo) (deviceMatcher, string) {
+	for _, matcher := range matchers {
+		if match, logicalID := matcher.match(devInfo); match {
+			return matcher, logicalID
+		}
+	}
+	return nil, ""
+}
 
 type deviceMatcher interface {
+	// name returns the name of the supported device.
+	name() string
+
+	// priority returns the priority of the matcher, see Driver.
+	priority() int
+
 	// match checks if the Linux device information is known to the device
 	// matcher and if so a unique logical device ID is generated from device
-	// name and physical name. The logical device ID is used to group
-	// separate physical devices into one logical device.
+	// name and device group. The device is claimed by the first matching
+	// matcher. The logical device ID is used to group
+	// separate Linux devices into one logical device.
 	match(devInfo linuxDeviceInfo) (match bool, logicalID string)
 
 	// newLogicalDevice creates a new logical device to which physical devices can be added.
@@ -97,39 +125,114 @@ type logicalDevice interface {
 
 type linuxDeviceInfo struct {
 	dev, name, phys string
+	identity        DeviceIdentity
+	group           string // Shared by Linux devices of one physical device, see linuxDeviceGroup.
+}
+
+// inputDeviceIdentity reads the identity of an opened input device.
+func inputDeviceIdentity(dev *evdev.InputDevice) DeviceIdentity {
+	return DeviceIdentity{
+		BusType: BusType(dev.Bustype),
+		Vendor:  dev.Vendor,
+		Product: dev.Product,
+		Version: dev.Version,
+		Uniq:    inputDeviceUniq(dev),
+	}
+}
+
+// inputDeviceUniq reads the unique ID of an input device. An empty string is
+// returned if the device has none.
+func inputDeviceUniq(dev *evdev.InputDevice) string {
+	var buf [evdev.MAX_NAME_SIZE]byte
+	if err := ioctl(dev.File.Sysfd(), eviocGUniq(len(buf)), unsafe.Pointer(&buf[0])); err != nil {
+		return ""
+	}
+	for i, c := range buf {
+		if c == 0 {
+			return string(buf[:i])
+		}
+	}
+	return string(buf[:])
 }
 
 // openDevice opens an input device and verifies that the device name and
 // physical location remains unchanged from when the information was collected.
-func (info *linuxDeviceInfo) openDevice() (dev *evdev.InputDevice, err error) {
-	if dev, err = evdev.Open(info.dev); err == nil {
-		if dev.Name != info.name || dev.Phys != info.phys {
-			dev.File.Close()
-			return nil, fmt.Errorf("opened input device {%s, %s} does match saved parameters {%s, %s}", dev.Name, dev.Phys, info.dev, info.phys)
+func (info *linuxDeviceInfo) openDevice(opts OpenOptions) (dev *evdev.InputDevice, err error) {
+	if dev, err = evdev.Open(info.dev); err != nil {
+		return nil, newDeviceError("open", info.dev, err)
+	}
+	if dev.Name != info.name || dev.Phys != info.phys {
+		dev.File.Close()
+		return nil, &IdentityChangedError{
+			Path:       info.dev,
+			Name:       info.name,
+			Phys:       info.phys,
+			OpenedName: dev.Name,
+			OpenedPhys: dev.Phys,
 		}
-		if err = dev.Grab(); err != nil {
-			dev.File.Close()
+	}
+	if err = dev.Grab(); err != nil {
+		dev.File.Close()
+		return nil, newDeviceError("grab", info.dev, err)
+	}
+	if err = setInputDeviceClock(dev, opts.Clock); err != nil {
+		dev.File.Close()
+		return nil, newDeviceError("set clock", info.dev, err)
+	}
+	return dev, nil
+}
+
+// setInputDeviceClock sets the clock used for input event timestamps. The
+// kernel replaces any queued events with SYN_DROPPED when the clock is changed
+// so the queue is drained before any events are processed.
+func setInputDeviceClock(dev *evdev.InputDevice, clock Clock) error {
+	if clock != ClockMonotonic {
+		return nil
+	}
+	clockID := int32(clockMonotonicID)
+	if err := ioctl(dev.File.Sysfd(), eviocSClockID, unsafe.Pointer(&clockID)); err != nil {
+		return err
+	}
+
+	buf := make([]evdev.InputEvent, inputSourceEvents)
+	for {
+		if _, err := syscall.Read(dev.File.Sysfd(), inputEventBytes(buf)); err != nil {
+			if err == syscall.EAGAIN {
+				return nil
+			}
+			if err != syscall.EINTR {
+				return err
+			}
 		}
 	}
-	return
 }
 
 type eventMux struct {
-	events       <-chan Event
-	sync         *channel.ConsSync
-	inputDevices []*evdev.InputDevice
-	prod         eventMuxProd
+	clock   Clock
+	events  <-chan Event
+	sync    *channel.ConsSync
+	sources []*inputSource
+	reader  *inputReader
+	prod    eventMuxProd
+	config  *deviceConfigState // Nil if configuration is not used.
 }
 
 type eventMuxProd struct {
-	events chan<- Event
-	sync   channel.ProdSync
+	events   chan<- Event
+	sync     channel.ProdSync
+	coalesce int32 // Motion coalescing enabled, accessed atomically.
+	stats    *eventStats
+
+	// Coalesced position event waiting for room in queue and its source.
+	pending       Event
+	pendingSource int
 }
 
-func newEventMux() eventMux {
+func newEventMux(clock Clock) eventMux {
 	events := make(chan Event, 100)
 	sync := channel.NewConsSync()
 	return eventMux{
+		clock:  clock,
 		events: events,
 		sync:   sync,
 		prod: eventMuxProd{
@@ -140,85 +243,217 @@ func newEventMux() eventMux {
 }
 
 // inputEventFunc processes Linux input events and produce events exposed by this package.
+// The returned slice may be reused by the function on the next call.
 type inputEventFunc func(inputEvents []evdev.InputEvent) []Event
 
-// addEventSource adds input device to mux and starts a goroutine to read and
-// process events from it. The input event function should process the Linux input
-// events and emit events of type Event to the mux using send() or trySend().
+// addEventSource adds input device to mux. The input event function should
+// process the Linux input events and return events of type Event. Events are
+// not read until start is called.
 func (mux *eventMux) addEventSource(inputDevice *evdev.InputDevice, inputEventFunc inputEventFunc) {
-	mux.inputDevices = append(mux.inputDevices, inputDevice)
-	muxProd := &mux.prod
+	mux.sources = append(mux.sources, &inputSource{
+		inputDevice:    inputDevice,
+		inputEventFunc: inputEventFunc,
+	})
+}
 
+// start starts a goroutine that reads events from all event sources. Event
+// groups from different sources that are read together are processed in
+// timestamp order, see inputReader.wait.
+func (mux *eventMux) start() (err error) {
+	if mux.reader, err = newInputReader(mux.sources); err != nil {
+		return err
+	}
+
+	var names []string
+	for _, v := range mux.sources {
+		names = append(names, v.inputDevice.Name)
+	}
+	mux.prod.stats = newEventStats(names, cap(mux.prod.events))
+
+	muxProd := &mux.prod
 	mux.sync.Add(1)
 	go func() {
-	out:
+		defer muxProd.sync.Done()
 		for {
-			inputEvents, err := inputDevice.Read()
+			timeout := -1
+			if muxProd.pending != nil {
+				// Retry delivery of coalesced motion while waiting for input.
+				timeout = coalesceRetryMillis
+			}
+			groups, shutdown, err := mux.reader.wait(timeout)
 			if err != nil {
-				muxProd.send(newEventError(err))
-				break out
+				muxProd.send(newEventError(err), -1)
+				return
 			}
-
-			events := inputEventFunc(inputEvents)
-			for _, event := range events {
-				shutdown := false
-				switch v := event.(type) {
-				case *EventPositionPen, *EventPositionFinger:
-					// Can always drop position events without harm
-					shutdown = muxProd.trySend(event)
-				case *EventButton:
-					if v.Pressure == 0 {
-						// Always emit button release events
-						shutdown = muxProd.send(event)
-					} else {
-						shutdown = muxProd.trySend(event)
-					}
-				case *eventError:
-					muxProd.send(event)
-					shutdown = true
-				default:
-					shutdown = muxProd.send(event)
+			if shutdown {
+				return
+			}
+			if _, shutdown := muxProd.flush(false); shutdown {
+				return
+			}
+			now := mux.clock.Now()
+			for _, group := range groups {
+				last := &group.events[len(group.events)-1]
+				muxProd.stats.readLatency(now.Sub(inputEventTime(last)))
+
+				events := group.source.inputEventFunc(group.events)
+				if last.Code == evdev.SYN_DROPPED {
+					muxProd.stats.synDropped(group.source.index)
+					setBufferOverrunPath(events, group.source.inputDevice.Fn)
 				}
-
-				if shutdown {
-					break out
+				if muxProd.sendEvents(events, group.source.index) {
+					return
 				}
 			}
 		}
-		muxProd.sync.Done()
 	}()
+	return nil
+}
+
+// setBufferOverrunPath records the device node of buffer overruns reported by
+// input event functions, which don't know the path of the node.
+func setBufferOverrunPath(events []Event, path string) {
+	for _, event := range events {
+		if e, ok := event.(*eventError); ok && e.err == ErrBufferOverrun {
+			e.err = newDeviceError("read", path, e.err)
+		}
+	}
 }
 
-var errorEventSourceClosed = errors.New("input event source closed")
+// Interval in milliseconds to retry delivery of a coalesced motion event.
+const coalesceRetryMillis = 2
+
+// Stats returns event statistics.
+func (mux *eventMux) Stats() Stats {
+	return mux.prod.stats.snapshot()
+}
+
+// Clock returns the clock used for event timestamps.
+func (mux *eventMux) Clock() Clock {
+	return mux.clock
+}
+
+// SetMotionCoalescing enables or disables coalescing of position events.
+func (mux *eventMux) SetMotionCoalescing(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&mux.prod.coalesce, v)
+}
+
+// motionCoalescing reports if motion coalescing is enabled.
+func (mux *eventMux) motionCoalescing() bool {
+	return atomic.LoadInt32(&mux.prod.coalesce) != 0
+}
 
 // Read consumes an event.
 func (mux *eventMux) Read() (Event, error) {
-	event, ok := <-mux.events
-	if !ok {
-		return nil, errorEventSourceClosed
+	return mux.read(nil)
+}
+
+// ReadContext consumes an event or returns when context is done.
+func (mux *eventMux) ReadContext(ctx context.Context) (Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
+	event, err := mux.read(ctx.Done())
+	if event == nil && err == nil {
+		err = ctx.Err()
+	}
+	return event, err
+}
+
+// ReadBatch consumes all pending events that fit in buf. It blocks until at
+// least one event is available or context is done.
+func (mux *eventMux) ReadBatch(ctx context.Context, buf []Event) (n int, err error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	if buf[0], err = mux.ReadContext(ctx); err != nil {
+		return 0, err
+	}
+	now := mux.clock.Now()
+	for n = 1; n < len(buf); {
+		select {
+		case event, ok := <-mux.events:
+			if !ok {
+				return n, ErrClosed
+			}
+			if event, err = mux.unbox(event, now); err != nil {
+				return n, err
+			}
+			if event != nil {
+				buf[n] = event
+				n++
+			}
+		default:
+			return n, nil
+		}
+	}
+	return n, nil
+}
+
+// read consumes an event. Nil event and error is returned if done is closed
+// before an event is available.
+func (mux *eventMux) read(done <-chan struct{}) (Event, error) {
+	for {
+		var event Event
+		var ok bool
+		select {
+		case event, ok = <-mux.events:
+		case <-done:
+			return nil, nil
+		}
+		if !ok {
+			return nil, ErrClosed
+		}
+		if event, err := mux.unbox(event, mux.clock.Now()); event != nil || err != nil {
+			return event, err
+		}
+	}
+}
 
+// unbox converts internal error events to errors, applies the device
+// configuration and records the delivery time of other events. Nil is returned
+// for events dropped by the configuration.
+func (mux *eventMux) unbox(event Event, now time.Time) (Event, error) {
 	if event, ok := event.(*eventError); ok {
 		err := event.err
 		if !mux.close() {
 			// This was not the first reported error or caused by a call to
 			// *eventMux.Close(). Convert spurious errors caused by shutting down
 			// producers to a generic error message.
-			err = errorEventSourceClosed
+			err = ErrClosed
 		}
 		return nil, err
 	}
+	if mux.config != nil {
+		if event = mux.config.apply(event); event == nil {
+			return nil, nil
+		}
+	}
+	setEventDeliveryTime(event, now)
 	return event, nil
 }
 
 func (mux *eventMux) close() (shutdown bool) {
 	if wait := mux.sync.Shutdown(); wait != nil {
-		// Close all input sources so that producers wake up if stuck on read.
-		for _, v := range mux.inputDevices {
-			v.File.Close()
+		if mux.reader != nil {
+			// Wake up the reader if it's waiting for input.
+			mux.reader.wakeup()
+			wait()
+
+			// The reader is gone so it's safe to close all input sources.
+			mux.reader.close()
+		}
+		for _, v := range mux.sources {
+			v.inputDevice.File.Close()
 		}
 
-		wait()
+		if mux.config != nil {
+			mux.config.close()
+		}
 
 		// All producers are gone so it's safe to close the event channel to wake up
 		// any consumer stuck on reading from it. Multiple consumers are not
@@ -229,15 +464,79 @@ func (mux *eventMux) close() (shutdown bool) {
 	return false
 }
 
+// loadConfig loads the configuration of device dev with stable ID and applies
+// it until the device is closed.
+func (mux *eventMux) loadConfig(dev Device, id string, opts OpenOptions) {
+	if !opts.NoConfig {
+		mux.config = newDeviceConfigState(dev, opts.ConfigDir, id)
+	}
+}
+
+// Config returns the configuration of the device, see ConfiguredDevice.
+func (mux *eventMux) Config() (DeviceConfig, error) {
+	if mux.config == nil {
+		return DeviceConfig{}, nil
+	}
+	return mux.config.get()
+}
+
+// ButtonMapper returns the mapper of configured button bindings, see
+// ConfiguredDevice. Nil is returned if configuration is not used.
+func (mux *eventMux) ButtonMapper() *ButtonMapper {
+	if mux.config == nil {
+		return nil
+	}
+	return mux.config.mapper
+}
+
 // Close the event source.
 func (mux *eventMux) Close() {
 	mux.close()
 }
 
-// Send event to channel, returns true if shutdown is in progress.
-func (muxProd *eventMuxProd) send(event Event) (shutdown bool) {
+// Send events from source to channel according to their drop policy, returns
+// true if shutdown is in progress.
+func (muxProd *eventMuxProd) sendEvents(events []Event, source int) (shutdown bool) {
+	muxProd.stats.produced(source, events)
+	for _, event := range events {
+		switch v := event.(type) {
+		case *EventPositionPen, *EventPositionFinger:
+			if atomic.LoadInt32(&muxProd.coalesce) != 0 {
+				shutdown = muxProd.sendCoalesced(event, source)
+			} else {
+				// Can always drop position events without harm
+				shutdown = muxProd.trySend(event, source)
+			}
+		case *EventButton:
+			if v.Pressure == 0 || atomic.LoadInt32(&muxProd.coalesce) != 0 {
+				// Always emit button release events. Presses and pressure
+				// changes are not dropped when coalescing, strokes would
+				// keep their geometry but lose pressure.
+				shutdown = muxProd.sendAfterPending(event, source)
+			} else {
+				shutdown = muxProd.trySendAfterPending(event, source)
+			}
+		case *eventError:
+			muxProd.pending = nil
+			muxProd.send(event, source)
+			shutdown = true
+		default:
+			shutdown = muxProd.sendAfterPending(event, source)
+		}
+
+		if shutdown {
+			return
+		}
+	}
+	return
+}
+
+// Send event from source to channel, returns true if shutdown is in progress.
+// Source is only used for statistics, a negative source is not counted.
+func (muxProd *eventMuxProd) send(event Event, source int) (shutdown bool) {
 	select {
 	case muxProd.events <- event:
+		muxProd.stats.delivered(source, event, len(muxProd.events))
 		return false
 	case <-muxProd.sync.SignalChan:
 		return true
@@ -245,15 +544,91 @@ func (muxProd *eventMuxProd) send(event Event) (shutdown bool) {
 }
 
 // Try to send event but drop it if channel queue is full, returns true if shutdown is in progress.
-func (muxProd *eventMuxProd) trySend(event Event) (shutdown bool) {
+func (muxProd *eventMuxProd) trySend(event Event, source int) (shutdown bool) {
+	var sent bool
+	if sent, shutdown = muxProd.offer(event, source); !sent && !shutdown {
+		muxProd.stats.dropped(source, event)
+	}
+	return
+}
+
+// Try to send event, returns true if the event was sent and true if shutdown is in progress.
+func (muxProd *eventMuxProd) offer(event Event, source int) (sent, shutdown bool) {
 	select {
 	case muxProd.events <- event:
-		return false
+		muxProd.stats.delivered(source, event, len(muxProd.events))
+		return true, false
 	case <-muxProd.sync.SignalChan:
-		return true
+		return false, true
 	default:
-		return false
+		return false, false
+	}
+}
+
+// Send position event, merging it with a pending position event of the same
+// position device if the channel queue is full. Returns true if shutdown is in
+// progress.
+func (muxProd *eventMuxProd) sendCoalesced(event Event, source int) (shutdown bool) {
+	if pending := muxProd.pending; pending != nil {
+		muxProd.pending = nil
+		if coalesceMotion(pending, event) {
+			muxProd.stats.coalesced(muxProd.pendingSource, pending)
+		} else {
+			// Pending event from another position device, drop it if there is
+			// still no room for it.
+			if shutdown = muxProd.trySend(pending, muxProd.pendingSource); shutdown {
+				return
+			}
+		}
+	}
+
+	var sent bool
+	if sent, shutdown = muxProd.offer(event, source); !sent && !shutdown {
+		muxProd.pending = event
+		muxProd.pendingSource = source
+	}
+	return
+}
+
+// Send any pending coalesced position event, blocking if block is true.
+// Returns true if there is no pending event left and true if shutdown is in
+// progress.
+func (muxProd *eventMuxProd) flush(block bool) (flushed, shutdown bool) {
+	pending := muxProd.pending
+	if pending == nil {
+		return true, false
+	}
+	if block {
+		flushed, shutdown = true, muxProd.send(pending, muxProd.pendingSource)
+	} else {
+		flushed, shutdown = muxProd.offer(pending, muxProd.pendingSource)
+	}
+	if flushed {
+		muxProd.pending = nil
+	}
+	return
+}
+
+// Send event after any pending coalesced position event to preserve order.
+func (muxProd *eventMuxProd) sendAfterPending(event Event, source int) (shutdown bool) {
+	if _, shutdown = muxProd.flush(true); shutdown {
+		return
+	}
+	return muxProd.send(event, source)
+}
+
+// Try to send event after any pending coalesced position event. The event is
+// dropped if the pending event could not be sent to preserve order.
+func (muxProd *eventMuxProd) trySendAfterPending(event Event, source int) (shutdown bool) {
+	var flushed bool
+	if flushed, shutdown = muxProd.flush(false); shutdown {
+		return
+	}
+	if !flushed {
+		muxProd.stats.dropped(source, event)
+		return
 	}
+	return muxProd.trySend(event, source)
 }
 
 // Translates from Linux button codes to package exported button codes.
@@ -272,7 +647,8 @@ func normalizeDigitalButtonValue(v int32) float32 {
 	return digitalButtonInterval.normalize(float32(v))
 }
 
-// Convert struct timeval like time in input event to Go time type.
+// Convert struct timeval like time in input event to Go time type. The time is
+// in the clock set with setInputDeviceClock.
 func inputEventTime(event *evdev.InputEvent) time.Time {
 	return time.Unix(event.Time.Sec, event.Time.Usec*1000)
 }
@@ -0,0 +1,232 @@
+package chimp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	evdev "github.com/johan-bolmsjo/golang-evdev"
+)
+
+// newTestWacomDevice creates a Bamboo device without input nodes. Events are
+// produced by calling the input event functions and sent with the mux
+// producer from the calling goroutine.
+func newTestWacomDevice(t testing.TB) *wacomDevice {
+	var desc *DeviceDescriptor
+	for _, v := range deviceDescriptors() {
+		if v.Name == "Wacom Bamboo 16FG 6x8" {
+			desc = v
+		}
+	}
+	if desc == nil {
+		t.Fatal("Bamboo descriptor missing")
+	}
+	dev := &wacomDevice{
+		eventMux: newEventMux(ClockRealtime),
+		params:   newWacomDeviceParams(desc),
+	}
+	for i := range dev.state.fingerSlots {
+		dev.state.fingerSlots[i].trackingID = -1
+	}
+	dev.prod.stats = newEventStats([]string{"Pen", "Finger", "Pad"}, cap(dev.prod.events))
+	return dev
+}
+
+func inputEvent(typ, code uint16, value int32) evdev.InputEvent {
+	return evdev.InputEvent{Type: typ, Code: code, Value: value}
+}
+
+// Names of event types and codes used in recordings.
+var (
+	recordingTypes = map[string]uint16{
+		"EV_SYN": evdev.EV_SYN,
+		"EV_KEY": evdev.EV_KEY,
+		"EV_ABS": evdev.EV_ABS,
+		"EV_MSC": evdev.EV_MSC,
+	}
+	recordingCodes = map[string]uint16{
+		"SYN_REPORT":         evdev.SYN_REPORT,
+		"BTN_TOOL_PEN":       evdev.BTN_TOOL_PEN,
+		"BTN_TOOL_RUBBER":    evdev.BTN_TOOL_RUBBER,
+		"BTN_TOUCH":          evdev.BTN_TOUCH,
+		"ABS_X":              evdev.ABS_X,
+		"ABS_Y":              evdev.ABS_Y,
+		"ABS_PRESSURE":       evdev.ABS_PRESSURE,
+		"ABS_DISTANCE":       evdev.ABS_DISTANCE,
+		"ABS_MT_SLOT":        evdev.ABS_MT_SLOT,
+		"ABS_MT_TRACKING_ID": evdev.ABS_MT_TRACKING_ID,
+		"ABS_MT_POSITION_X":  evdev.ABS_MT_POSITION_X,
+		"ABS_MT_POSITION_Y":  evdev.ABS_MT_POSITION_Y,
+		"ABS_MT_TOUCH_MAJOR": evdev.ABS_MT_TOUCH_MAJOR,
+		"MSC_TIMESTAMP":      evdev.MSC_TIMESTAMP,
+	}
+	recordingNodes = map[string]int{"pen": 0, "finger": 1}
+)
+
+// recordingStart is the time that recording times are relative to.
+var recordingStart = time.Unix(1500000000, 0)
+
+// replayRecording passes the input events recorded in testdata/name through
+// the input event functions of dev and returns the events produced from every
+// complete event group. Recordings have one input event per line with node
+// ("pen" or "finger"), time in seconds, type, code and value.
+func replayRecording(t *testing.T, dev *wacomDevice, name string) []Event {
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	funs := []inputEventFunc{dev.inputEventPen, dev.inputEventFinger}
+	groups := make([][]evdev.InputEvent, len(funs))
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		node, nodeOK := recordingNodes[fields[0]]
+		typ, typeOK := recordingTypes[fields[len(fields)-3]]
+		code, codeOK := recordingCodes[fields[len(fields)-2]]
+		seconds, err := strconv.ParseFloat(fields[1], 64)
+		value, err2 := strconv.ParseInt(fields[len(fields)-1], 10, 32)
+		if len(fields) != 5 || !nodeOK || !typeOK || !codeOK || err != nil || err2 != nil {
+			t.Fatalf("%s:%d: malformed event %q", name, line, scanner.Text())
+		}
+
+		v := inputEvent(typ, code, int32(value))
+		timestamp := recordingStart.Add(time.Duration(seconds * float64(time.Second)))
+		v.Time = syscall.NsecToTimeval(timestamp.UnixNano())
+		groups[node] = append(groups[node], v)
+		if typ == evdev.EV_SYN && code == evdev.SYN_REPORT {
+			events = append(events, funs[node](groups[node])...)
+			groups[node] = groups[node][:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+// benchInputGroups are event groups of a pen and two fingers moving on the pad.
+var benchInputGroups = []struct {
+	source int
+	events []evdev.InputEvent
+}{
+	{0, []evdev.InputEvent{
+		inputEvent(evdev.EV_KEY, evdev.BTN_TOOL_PEN, 1),
+		inputEvent(evdev.EV_ABS, evdev.ABS_X, 1000),
+		inputEvent(evdev.EV_ABS, evdev.ABS_Y, 2000),
+		inputEvent(evdev.EV_ABS, evdev.ABS_DISTANCE, 10),
+		inputEvent(evdev.EV_SYN, evdev.SYN_REPORT, 0),
+	}},
+	{1, []evdev.InputEvent{
+		inputEvent(evdev.EV_ABS, evdev.ABS_MT_SLOT, 0),
+		inputEvent(evdev.EV_ABS, evdev.ABS_MT_TRACKING_ID, 1),
+		inputEvent(evdev.EV_ABS, evdev.ABS_MT_POSITION_X, 100),
+		inputEvent(evdev.EV_ABS, evdev.ABS_MT_POSITION_Y, 200),
+		inputEvent(evdev.EV_ABS, evdev.ABS_MT_SLOT, 1),
+		inputEvent(evdev.EV_ABS, evdev.ABS_MT_TRACKING_ID, 2),
+		inputEvent(evdev.EV_ABS, evdev.ABS_MT_POSITION_X, 300),
+		inputEvent(evdev.EV_ABS, evdev.ABS_MT_POSITION_Y, 400),
+		inputEvent(evdev.EV_ABS, evdev.ABS_X, 100),
+		inputEvent(evdev.EV_ABS, evdev.ABS_Y, 200),
+		inputEvent(evdev.EV_SYN, evdev.SYN_REPORT, 0),
+	}},
+}
+
+// produceBenchEvents produces one event of every benchmark input group.
+func produceBenchEvents(dev *wacomDevice) int {
+	funs := []inputEventFunc{dev.inputEventPen, dev.inputEventFinger}
+	n := 0
+	for _, group := range benchInputGroups {
+		events := funs[group.source](group.events)
+		n += len(events)
+		dev.prod.sendEvents(events, group.source)
+	}
+	return n
+}
+
+func BenchmarkRead(b *testing.B) {
+	dev := newTestWacomDevice(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for n := produceBenchEvents(dev); n > 0; n-- {
+			if _, err := dev.Read(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkReadBatch(b *testing.B) {
+	dev := newTestWacomDevice(b)
+	buf := make([]Event, 64)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for n := produceBenchEvents(dev); n > 0; {
+			m, err := dev.ReadBatch(ctx, buf)
+			if err != nil {
+				b.Fatal(err)
+			}
+			n -= m
+		}
+	}
+}
+
+func TestPenProximityEvents(t *testing.T) {
+	dev := newTestWacomDevice(t)
+	var got []string
+	for _, event := range replayRecording(t, dev, "palm-grace.txt") {
+		at := event.Time().Sub(recordingStart).Round(time.Millisecond)
+		switch e := event.(type) {
+		case *EventProximityPen:
+			got = append(got, fmt.Sprintf("%s proximity %t %s", at, e.InProximity, e.Tool))
+		case *EventPositionPen:
+			got = append(got, fmt.Sprintf("%s position", at))
+		}
+	}
+	want := []string{"0s proximity true PenTip", "0s position", "100ms proximity false PenTip"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDeviceTimestampEvents(t *testing.T) {
+	dev := newTestWacomDevice(t)
+	var got []string
+	for _, event := range replayRecording(t, dev, "device-timestamp.txt") {
+		at := event.Time().Sub(recordingStart).Round(time.Millisecond)
+		switch e := event.(type) {
+		case *EventPositionPen:
+			got = append(got, fmt.Sprintf("%s pen %t %s", at, e.HasDeviceTimestamp, e.DeviceTimestamp))
+		case *EventPositionFinger:
+			got = append(got, fmt.Sprintf("%s finger %t %s", at, e.HasDeviceTimestamp, e.DeviceTimestamp))
+		case *EventButton:
+			got = append(got, fmt.Sprintf("%s %s %t %s", at, e.Button, e.HasDeviceTimestamp, e.DeviceTimestamp))
+		}
+	}
+	want := []string{
+		"0s pen true 0s",
+		"10ms pen true 10ms",
+		"20ms pen false 0s",
+		"500ms finger false 0s",
+		"500ms Touch false 0s",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -1,7 +1,28 @@
+//go:build !linux
 // +build !linux
 
 package chimp
 
+import "errors"
+
 func listDevices() ([]DeviceInfo, error) {
 	return nil, nil
 }
+
+func diagnoseDevices() ([]NodeDiagnosis, error) {
+	return nil, nil
+}
+
+var errProbeUnsupported = errors.New("probing input nodes is only supported on Linux")
+
+func probeInputNode(path string) (*InputNodeProbe, error) {
+	return nil, errProbeUnsupported
+}
+
+func recordInputNodes(paths []string, stop <-chan struct{}, onEvent func(node int, event *InputEvent)) ([]InputNodeActivity, error) {
+	return nil, errProbeUnsupported
+}
+
+func draftDeviceDescriptor(probes []*InputNodeProbe, activity []InputNodeActivity) (*DeviceDescriptor, []string) {
+	return &DeviceDescriptor{}, nil
+}
@@ -1,48 +1,6 @@
 package chimp
 
-const (
-	wacomBamboo16FG6x8PadWidthMillimeters  = 216.0
-	wacomBamboo16FG6x8PadHeightMillimeters = 137.0
-	wacomBamboo16FG6x8PadWidthHeightRatio  = wacomBamboo16FG6x8PadWidthMillimeters / wacomBamboo16FG6x8PadHeightMillimeters
-	wacomBamboo16FG6x8PenXMax              = 21648
-	wacomBamboo16FG6x8PenYMax              = 13700
-	wacomBamboo16FG6x8PenPressureMax       = 1023
-	wacomBamboo16FG6x8PenDistanceMax       = 30
-	wacomBamboo16FG6x8FingerXMax           = 4095
-	wacomBamboo16FG6x8FingerYMax           = 4095
-)
-
-var wacomBamboo16FG6x8Properties = Properties{
-	PropertyDeviceName:           PropertyValueString("Wacom Bamboo 16FG 6x8"),
-	PropertyDeviceType:           PropertyValueString(DeviceTypeTablet.String()),
-	PropertyPadWidthMillimeters:  PropertyValueNumber(wacomBamboo16FG6x8PadWidthMillimeters),
-	PropertyPadHeightMillimeters: PropertyValueNumber(wacomBamboo16FG6x8PadHeightMillimeters),
-	PropertyPadWidthHeightRatio:  PropertyValueNumber(wacomBamboo16FG6x8PadWidthHeightRatio),
-}
-
-var wacomBamboo16FG6x8Capabilities = Capabilities{
-	PositionDevices: []PositionDevice{PositionDevicePen, PositionDeviceFinger},
-	Buttons: []Button{
-		ButtonPenTip,
-		ButtonPenEraser,
-		ButtonPen1,
-		ButtonPen2,
-		ButtonLeft,
-		ButtonRight,
-		ButtonForward,
-		ButtonBack,
-		ButtonTouch,
-	},
-}
-
-var wacomBamboo16FG6x8DeviceParams = wacomDeviceParams{
-	penXInterval:        f32cival{b: wacomBamboo16FG6x8PenXMax},
-	penYInterval:        f32cival{b: wacomBamboo16FG6x8PenYMax},
-	penPressureInterval: f32cival{b: wacomBamboo16FG6x8PenPressureMax},
-	penDistanceInterval: f32cival{b: wacomBamboo16FG6x8PenDistanceMax},
-	fingerXInterval:     f32cival{b: wacomBamboo16FG6x8FingerXMax},
-	fingerYInterval:     f32cival{b: wacomBamboo16FG6x8FingerYMax},
-}
+import "math"
 
 // Like properties but internal.
 type wacomDeviceParams struct {
@@ -52,4 +10,72 @@ type wacomDeviceParams struct {
 	penDistanceInterval f32cival
 	fingerXInterval     f32cival
 	fingerYInterval     f32cival
+	fingerMillimeters   float32 // Millimeters per finger X-axis unit, used for contact size.
+	padRings            []AxisRange
+	padStrips           []AxisRange
+	padModeGroups       []PadModeGroup
+
+	clearDistanceOnPressure bool // See DeviceQuirks.
+}
+
+// newWacomDeviceParams creates device parameters from a device descriptor.
+func newWacomDeviceParams(desc *DeviceDescriptor) wacomDeviceParams {
+	params := wacomDeviceParams{
+		clearDistanceOnPressure: desc.Quirks.ClearDistanceOnPressure,
+	}
+	if pen := desc.Pen; pen != nil {
+		params.penXInterval = axisInterval(pen.X)
+		params.penYInterval = axisInterval(pen.Y)
+		params.penPressureInterval = axisInterval(pen.Pressure)
+		params.penDistanceInterval = axisInterval(pen.Distance)
+	}
+	if finger := desc.Finger; finger != nil {
+		params.fingerXInterval = axisInterval(finger.X)
+		params.fingerYInterval = axisInterval(finger.Y)
+		params.fingerMillimeters = float32(desc.WidthMillimeters) / float32(finger.X.Max-finger.X.Min)
+	}
+	if pad := desc.Pad; pad != nil {
+		params.padRings = pad.Rings
+		params.padStrips = pad.Strips
+		params.padModeGroups = desc.ModeGroups
+	}
+	return params
+}
+
+// ringPosition normalizes a ring value to the range [0, 1). The last value of
+// the range is next to the first one.
+func ringPosition(r AxisRange, v int32) float32 {
+	if r.empty() {
+		return 0
+	}
+	p := float32(v-r.Min) / float32(r.Max-r.Min+1)
+	switch {
+	case p < 0:
+		p = 0
+	case p >= 1:
+		p = float32(r.Max-r.Min) / float32(r.Max-r.Min+1)
+	}
+	return p
+}
+
+// stripPosition normalizes a strip value, which has a single bit set for the
+// touched position, to the range [0, 1].
+func stripPosition(r AxisRange, v int32) float32 {
+	if r.Max <= 1 || v <= 0 {
+		return 0
+	}
+	p := float32(math.Log2(float64(v)) / math.Log2(float64(r.Max)))
+	if p > 1 {
+		p = 1
+	}
+	return p
+}
+
+// axisInterval returns the interval of an axis range. Axes without range are
+// given the interval [0, 1] to avoid division by zero when normalizing.
+func axisInterval(r AxisRange) f32cival {
+	if r.empty() {
+		return f32cival{b: 1}
+	}
+	return f32cival{a: float32(r.Min), b: float32(r.Max)}
 }
@@ -2,95 +2,26 @@ package chimp
 
 import (
 	"fmt"
-	"regexp"
+	"sync"
+	"time"
 
 	evdev "github.com/johan-bolmsjo/golang-evdev"
 )
 
-type deviceMatcherWacomBamboo16FG6x8 struct {
-	reName *regexp.Regexp
-}
-
-func newDeviceMatcherWacomBamboo16FG6x8() *deviceMatcherWacomBamboo16FG6x8 {
-	return &deviceMatcherWacomBamboo16FG6x8{
-		reName: regexp.MustCompile(`^(Wacom Bamboo 16FG 6x8) (Pen|Finger|Pad)$`),
-	}
-}
-
-func (matcher *deviceMatcherWacomBamboo16FG6x8) match(devInfo linuxDeviceInfo) (match bool, logicalID string) {
-	reMatchName := matcher.reName.FindStringSubmatch(devInfo.name)
-	reMatchPhys := reUSBDeviceID.FindStringSubmatch(devInfo.phys)
-
-	if reMatchName != nil && reMatchPhys != nil {
-		match = true
-		logicalID = reMatchName[1] + " " + reMatchPhys[1]
-	}
-
-	return
-}
-
-func (matcher *deviceMatcherWacomBamboo16FG6x8) newLogicalDevice() logicalDevice {
-	return &logicalDeviceWacomBamboo16FG6x8{
-		reName: matcher.reName,
-	}
-}
-
-type logicalDeviceWacomBamboo16FG6x8 struct {
-	reName       *regexp.Regexp
-	linuxDevices [wacomLinuxDeviceTypes]linuxDeviceInfo
-}
-
-func (logicalDevice *logicalDeviceWacomBamboo16FG6x8) addLinuxDevice(devInfo linuxDeviceInfo) {
-	reMatchName := logicalDevice.reName.FindStringSubmatch(devInfo.name)
-	if reMatchName != nil {
-		logicalDevice.linuxDevices[wacomLinuxDeviceTypeFromString(reMatchName[2])] = devInfo
-	}
-}
-
-func (logicalDevice *logicalDeviceWacomBamboo16FG6x8) deviceInfo() DeviceInfo {
-	return DeviceInfo{
-		Name: wacomBamboo16FG6x8Properties[PropertyDeviceName].String(),
-		Type: DeviceTypeTablet,
-		Open: logicalDevice.Open,
-	}
-}
-
-func (logicalDevice *logicalDeviceWacomBamboo16FG6x8) Open() (Device, error) {
-	var err error
-	var inputDevices [wacomLinuxDeviceTypes]*evdev.InputDevice
-
-	closeInputDevices := func() {
-		for _, v := range inputDevices {
-			if v != nil {
-				v.File.Close()
-			}
-		}
-	}
-
-	for i, v := range logicalDevice.linuxDevices {
-		if v.dev != "" {
-			if inputDevices[i], err = v.openDevice(); err != nil {
-				closeInputDevices()
-				return nil, err
-			}
-		}
-	}
-
-	return newWacomDevice(inputDevices, wacomBamboo16FG6x8Properties,
-		wacomBamboo16FG6x8Capabilities, wacomBamboo16FG6x8DeviceParams), nil
-}
-
 type wacomDevice struct {
 	eventMux
 	properties   Properties
 	capabilities Capabilities
 	params       wacomDeviceParams
+	padModes     *PadModes // Nil if the pad has no mode groups.
 
 	// Recorded state that is used to produce an event when SYN_REPORT is observed.
 	state struct {
 		penCoord           Coord2D
 		penTool            Button // ButtonPenTip or ButtonPenEraser
 		penToolSelected    bool
+		penInProximity     bool           // Proximity of last EventProximityPen
+		penToolID          uint32         // Tool ID reported with ABS_MISC
 		penInputEventFlags inputEventFlag // Flags about content of one event group
 		penDistance        float32
 		penPressure        float32
@@ -102,9 +33,41 @@ type wacomDevice struct {
 		fingerCoord           Coord2D
 		fingerTouchPressure   float32
 		fingerInputEventFlags inputEventFlag // Flags about content of one event group
+		fingerTouchReported   bool           // Touch press has been emitted
+		fingerRejected        bool           // Contact rejected by palm rejection until it leaves the pad
+		fingerSlot            int32          // Current multi touch slot
+		fingerSlots           [wacomFingerSlots]wacomFingerSlot
+
+		padRingsTouched  []bool // Rings reported since the pad controls were released.
+		padStripsTouched []bool
+
+		// Device timestamps of each event source.
+		penTimestamp, fingerTimestamp, padTimestamp deviceTimestamp
+
+		// Event allocation and reused event slices of each event source.
+		penAlloc, fingerAlloc, padAlloc    eventSlab
+		penEvents, fingerEvents, padEvents []Event
+	}
+
+	// Palm rejection policy that may be set from any goroutine and pen
+	// proximity used when processing finger events.
+	palm struct {
+		sync.Mutex
+		policy         PalmRejection
+		penInProximity bool
+		penLeftTime    time.Time // Time when pen tool left proximity.
 	}
 }
 
+// Multi touch slot state.
+type wacomFingerSlot struct {
+	trackingID int32 // -1 if slot is unused
+	touchMajor int32
+	coord      Coord2D
+}
+
+const wacomFingerSlots = 16
+
 func (dev *wacomDevice) Properties() Properties {
 	return dev.properties
 }
@@ -113,15 +76,73 @@ func (dev *wacomDevice) Capabilities() *Capabilities {
 	return &dev.capabilities
 }
 
+func (dev *wacomDevice) PadModes() *PadModes {
+	return dev.padModes
+}
+
+func (dev *wacomDevice) SetPalmRejection(policy PalmRejection) {
+	dev.palm.Lock()
+	dev.palm.policy = policy
+	dev.palm.Unlock()
+}
+
+// palmRejection returns the palm rejection policy.
+func (dev *wacomDevice) palmRejection() PalmRejection {
+	dev.palm.Lock()
+	defer dev.palm.Unlock()
+	return dev.palm.policy
+}
+
+// setPenProximity records pen tool proximity for palm rejection.
+func (dev *wacomDevice) setPenProximity(inProximity bool, timestamp time.Time) {
+	dev.palm.Lock()
+	if dev.palm.penInProximity && !inProximity {
+		dev.palm.penLeftTime = timestamp
+	}
+	dev.palm.penInProximity = inProximity
+	dev.palm.Unlock()
+}
+
+// rejectTouch checks if touch input at timestamp should be rejected by the
+// palm rejection policy.
+func (dev *wacomDevice) rejectTouch(timestamp time.Time) bool {
+	dev.palm.Lock()
+	defer dev.palm.Unlock()
+
+	policy := &dev.palm.policy
+	if !policy.Enabled {
+		return false
+	}
+	if dev.palm.penInProximity || timestamp.Sub(dev.palm.penLeftTime) < policy.GracePeriod {
+		return true
+	}
+	if policy.MaxContactMillimeters > 0 {
+		for _, slot := range dev.state.fingerSlots {
+			if slot.trackingID >= 0 && float32(slot.touchMajor)*dev.params.fingerMillimeters > policy.MaxContactMillimeters {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func newWacomDevice(inputDevices [wacomLinuxDeviceTypes]*evdev.InputDevice, properties Properties,
-	capabilities Capabilities, params wacomDeviceParams) *wacomDevice {
+	capabilities Capabilities, params wacomDeviceParams, clock Clock) (*wacomDevice, error) {
 
 	dev := &wacomDevice{
-		eventMux:     newEventMux(),
+		eventMux:     newEventMux(clock),
 		properties:   properties,
 		capabilities: capabilities,
 		params:       params,
 	}
+	for i := range dev.state.fingerSlots {
+		dev.state.fingerSlots[i].trackingID = -1
+	}
+	dev.state.padRingsTouched = make([]bool, len(params.padRings))
+	dev.state.padStripsTouched = make([]bool, len(params.padStrips))
+	if len(params.padModeGroups) > 0 {
+		dev.padModes = NewPadModes(params.padModeGroups)
+	}
 
 	funs := [wacomLinuxDeviceTypes]inputEventFunc{
 		// matches order of wacomLinuxDeviceType
@@ -135,7 +156,10 @@ func newWacomDevice(inputDevices [wacomLinuxDeviceTypes]*evdev.InputDevice, prop
 			dev.addEventSource(v, funs[i])
 		}
 	}
-	return dev
+	if err := dev.start(); err != nil {
+		return nil, err
+	}
+	return dev, nil
 }
 
 // Can be used when adding support for a device to see what Linux input events are available.
@@ -146,13 +170,17 @@ func dumpInputEvents(inputEvents []evdev.InputEvent) {
 }
 
 func (dev *wacomDevice) inputEventPen(inputEvents []evdev.InputEvent) (events []Event) {
+	events = dev.state.penEvents[:0]
 	for _, v := range inputEvents {
 		switch v.Type {
 		case evdev.EV_SYN:
 			switch v.Code {
 			case evdev.SYN_REPORT:
+				deviceTimestamp, hasDeviceTimestamp := dev.state.penTimestamp.take()
+				proximityChanged := dev.state.penToolSelected != dev.state.penInProximity
+				dev.state.penInProximity = dev.state.penToolSelected
 				emitPressureEvent := dev.state.penInputEventFlags.has(inputEventFlagPressure)
-				if emitPressureEvent && dev.state.penPressure > 0 {
+				if emitPressureEvent && dev.state.penPressure > 0 && dev.params.clearDistanceOnPressure {
 					// The Linux device driver seems to be able to generate a
 					// positive pressure event and a positive distance in the
 					// same event group. This is in my view not logically
@@ -167,12 +195,19 @@ func (dev *wacomDevice) inputEventPen(inputEvents []evdev.InputEvent) (events []
 				// the pressure beforehand. Note that all events form the same event
 				// group have the same timestamp so ordering is not really important
 				// if it is used by the application.
+				// A tool entering proximity is reported before its events and
+				// a tool leaving after them.
+				if proximityChanged && dev.state.penToolSelected {
+					events = append(events, dev.proximityPen(&v, deviceTimestamp, hasDeviceTimestamp))
+				}
 				if emitPressureEvent {
-					events = append(events, &EventButton{
-						Timestamp: inputEventTime(&v),
-						Button:    dev.state.penTool,
-						Pressure:  dev.state.penPressure,
-					})
+					events = append(events, dev.state.penAlloc.button(EventButton{
+						Timestamp:          inputEventTime(&v),
+						DeviceTimestamp:    deviceTimestamp,
+						HasDeviceTimestamp: hasDeviceTimestamp,
+						Button:             dev.state.penTool,
+						Pressure:           dev.state.penPressure,
+					}))
 				}
 
 				// The position event is not generated if no pen tool is selected.
@@ -182,21 +217,31 @@ func (dev *wacomDevice) inputEventPen(inputEvents []evdev.InputEvent) (events []
 				if dev.state.penInputEventFlags.has(inputEventFlagPosition) &&
 					dev.state.penToolSelected {
 
-					events = append(events, &EventPositionPen{
-						Timestamp: inputEventTime(&v),
-						Coord:     dev.state.penCoord,
-						Distance:  dev.state.penDistance,
-					})
+					events = append(events, dev.state.penAlloc.positionPen(EventPositionPen{
+						Timestamp:          inputEventTime(&v),
+						DeviceTimestamp:    deviceTimestamp,
+						HasDeviceTimestamp: hasDeviceTimestamp,
+						Coord:              dev.state.penCoord,
+						Distance:           dev.state.penDistance,
+						Tool:               dev.state.penTool,
+						ToolID:             dev.state.penToolID,
+					}))
+				}
+				if hasDeviceTimestamp {
+					setDeviceTimestamp(dev.state.penButtonEvents, deviceTimestamp)
 				}
 				for _, event := range dev.state.penButtonEvents {
 					events = append(events, event)
 				}
+				if proximityChanged && !dev.state.penToolSelected {
+					events = append(events, dev.proximityPen(&v, deviceTimestamp, hasDeviceTimestamp))
+				}
 
 				dev.state.penButtonEvents = dev.state.penButtonEvents[:0]
 				dev.state.penInputEventFlags = 0
 			case evdev.SYN_DROPPED:
 				// TODO(jb): See comment in inputEventPad about this condition.
-				return []Event{newEventError(eventBufferOverrunError)}
+				return []Event{newEventError(ErrBufferOverrun)}
 			}
 		case evdev.EV_ABS:
 			switch v.Code {
@@ -213,6 +258,12 @@ func (dev *wacomDevice) inputEventPen(inputEvents []evdev.InputEvent) (events []
 				dev.state.penPressure = dev.params.penPressureInterval.normalize(float32(v.Value))
 				// Pressure is emitted as a synthesized button event.
 				dev.state.penInputEventFlags.set(inputEventFlagPressure)
+			case evdev.ABS_MISC:
+				dev.state.penToolID = uint32(v.Value)
+			}
+		case evdev.EV_MSC:
+			if v.Code == evdev.MSC_TIMESTAMP {
+				dev.state.penTimestamp.update(v.Value, inputEventTime(&v))
 			}
 
 		case evdev.EV_KEY:
@@ -222,56 +273,87 @@ func (dev *wacomDevice) inputEventPen(inputEvents []evdev.InputEvent) (events []
 				if v.Value == 1 {
 					dev.state.penTool = ButtonPenTip
 				}
+				dev.setPenProximity(dev.state.penToolSelected, inputEventTime(&v))
 			case evdev.BTN_TOOL_RUBBER:
 				dev.state.penToolSelected = v.Value == 1
 				if v.Value == 1 {
 					dev.state.penTool = ButtonPenEraser
 				}
+				dev.setPenProximity(dev.state.penToolSelected, inputEventTime(&v))
 			case evdev.BTN_TOUCH:
 				// The touch event is not needed since it can be dervied from the
 				// pressure event.
 			default:
 				if button, ok := buttonCodeTrans[v.Code]; ok {
 					s := &dev.state.penButtonEvents
-					*s = append(*s, &EventButton{
+					*s = append(*s, dev.state.penAlloc.button(EventButton{
 						Timestamp: inputEventTime(&v),
 						Button:    button,
 						Pressure:  normalizeDigitalButtonValue(v.Value),
-					})
+					}))
 				}
 			}
 		}
 	}
+	dev.state.penEvents = events
 	return
 }
 
 func (dev *wacomDevice) inputEventFinger(inputEvents []evdev.InputEvent) (events []Event) {
-	// Don't care about multi touch events for now.
-	// Just generate position events from the absolute X and Y positions and
-	// button events for finger touching and leaving the pad.
+	// Generate position events from the absolute X and Y positions and button
+	// events for finger touching and leaving the pad. Multi touch slots are
+	// reported as contacts of the position events.
+
+	events = dev.state.fingerEvents[:0]
 
 	for _, v := range inputEvents {
 		switch v.Type {
 		case evdev.EV_SYN:
 			switch v.Code {
 			case evdev.SYN_REPORT:
+				deviceTimestamp, hasDeviceTimestamp := dev.state.fingerTimestamp.take()
+				touching := dev.state.fingerTouchPressure > 0
+				if dev.state.fingerRejected || dev.rejectTouch(inputEventTime(&v)) {
+					if dev.state.fingerTouchReported {
+						// Don't leave the user with a stuck touch.
+						events = append(events, dev.state.fingerAlloc.button(EventButton{
+							Timestamp:          inputEventTime(&v),
+							DeviceTimestamp:    deviceTimestamp,
+							HasDeviceTimestamp: hasDeviceTimestamp,
+							Button:             ButtonTouch,
+							Pressure:           0,
+						}))
+						dev.state.fingerTouchReported = false
+					}
+					// Rejection is kept until the contact leaves the pad.
+					dev.state.fingerRejected = touching
+					dev.state.fingerInputEventFlags = 0
+					break
+				}
+
 				if dev.state.fingerInputEventFlags.has(inputEventFlagPosition) {
-					events = append(events, &EventPositionFinger{
-						Timestamp: inputEventTime(&v),
-						Coord:     dev.state.fingerCoord,
-					})
+					events = append(events, dev.state.fingerAlloc.positionFinger(EventPositionFinger{
+						Timestamp:          inputEventTime(&v),
+						DeviceTimestamp:    deviceTimestamp,
+						HasDeviceTimestamp: hasDeviceTimestamp,
+						Coord:              dev.state.fingerCoord,
+						Contacts:           dev.fingerContacts(),
+					}))
 				}
 				if dev.state.fingerInputEventFlags.has(inputEventFlagButton) {
-					events = append(events, &EventButton{
-						Timestamp: inputEventTime(&v),
-						Button:    ButtonTouch,
-						Pressure:  dev.state.fingerTouchPressure,
-					})
+					events = append(events, dev.state.fingerAlloc.button(EventButton{
+						Timestamp:          inputEventTime(&v),
+						DeviceTimestamp:    deviceTimestamp,
+						HasDeviceTimestamp: hasDeviceTimestamp,
+						Button:             ButtonTouch,
+						Pressure:           dev.state.fingerTouchPressure,
+					}))
+					dev.state.fingerTouchReported = touching
 				}
 				dev.state.fingerInputEventFlags = 0
 			case evdev.SYN_DROPPED:
 				// TODO(jb): See comment in inputEventPad about this condition.
-				return []Event{newEventError(eventBufferOverrunError)}
+				return []Event{newEventError(ErrBufferOverrun)}
 			}
 		case evdev.EV_ABS:
 			switch v.Code {
@@ -281,6 +363,31 @@ func (dev *wacomDevice) inputEventFinger(inputEvents []evdev.InputEvent) (events
 			case evdev.ABS_Y:
 				dev.state.fingerCoord.Y = dev.params.fingerYInterval.normalize(float32(v.Value))
 				dev.state.fingerInputEventFlags.set(inputEventFlagPosition)
+			case evdev.ABS_MT_SLOT:
+				dev.state.fingerSlot = v.Value
+			case evdev.ABS_MT_TRACKING_ID:
+				if slot := dev.fingerSlot(); slot != nil {
+					slot.trackingID = v.Value
+					dev.state.fingerInputEventFlags.set(inputEventFlagPosition)
+				}
+			case evdev.ABS_MT_POSITION_X:
+				if slot := dev.fingerSlot(); slot != nil {
+					slot.coord.X = dev.params.fingerXInterval.normalize(float32(v.Value))
+					dev.state.fingerInputEventFlags.set(inputEventFlagPosition)
+				}
+			case evdev.ABS_MT_POSITION_Y:
+				if slot := dev.fingerSlot(); slot != nil {
+					slot.coord.Y = dev.params.fingerYInterval.normalize(float32(v.Value))
+					dev.state.fingerInputEventFlags.set(inputEventFlagPosition)
+				}
+			case evdev.ABS_MT_TOUCH_MAJOR:
+				if slot := dev.fingerSlot(); slot != nil {
+					slot.touchMajor = v.Value
+				}
+			}
+		case evdev.EV_MSC:
+			if v.Code == evdev.MSC_TIMESTAMP {
+				dev.state.fingerTimestamp.update(v.Value, inputEventTime(&v))
 			}
 
 		case evdev.EV_KEY:
@@ -291,14 +398,65 @@ func (dev *wacomDevice) inputEventFinger(inputEvents []evdev.InputEvent) (events
 			}
 		}
 	}
+	dev.state.fingerEvents = events
 	return
 }
 
+// proximityPen returns a proximity event of the current pen tool. Proximity
+// changes are rare so the event is not allocated from the slab.
+func (dev *wacomDevice) proximityPen(v *evdev.InputEvent, deviceTimestamp time.Duration, hasDeviceTimestamp bool) *EventProximityPen {
+	return &EventProximityPen{
+		Timestamp:          inputEventTime(v),
+		DeviceTimestamp:    deviceTimestamp,
+		HasDeviceTimestamp: hasDeviceTimestamp,
+		InProximity:        dev.state.penToolSelected,
+		Tool:               dev.state.penTool,
+		ToolID:             dev.state.penToolID,
+	}
+}
+
+// fingerSlot returns the current multi touch slot or nil if it's out of range.
+func (dev *wacomDevice) fingerSlot() *wacomFingerSlot {
+	if i := dev.state.fingerSlot; i >= 0 && i < wacomFingerSlots {
+		return &dev.state.fingerSlots[i]
+	}
+	return nil
+}
+
+// fingerContacts returns the contacts of all used multi touch slots.
+func (dev *wacomDevice) fingerContacts() []Contact {
+	n := 0
+	for i := range dev.state.fingerSlots {
+		if dev.state.fingerSlots[i].trackingID >= 0 {
+			n++
+		}
+	}
+	contacts := dev.state.fingerAlloc.contactSlice(n)
+	n = 0
+	for _, slot := range dev.state.fingerSlots {
+		if slot.trackingID >= 0 {
+			contacts[n] = Contact{ID: slot.trackingID, Coord: slot.coord}
+			n++
+		}
+	}
+	return contacts
+}
+
 func (dev *wacomDevice) inputEventPad(inputEvents []evdev.InputEvent) (events []Event) {
-	// The pad only generates button events so don't bother synching with SYN_REPORT.
+	// The pad generates button, ring and strip events that are independent of
+	// each other so don't bother synching with SYN_REPORT, except for setting
+	// the device timestamp of the event group.
+	events = dev.state.padEvents[:0]
+	group := 0 // Index of first event of current event group.
 	for _, v := range inputEvents {
 		switch v.Type {
 		case evdev.EV_SYN:
+			if v.Code == evdev.SYN_REPORT {
+				if deviceTimestamp, ok := dev.state.padTimestamp.take(); ok {
+					setDeviceTimestamp(events[group:], deviceTimestamp)
+				}
+				group = len(events)
+			}
 			if v.Code == evdev.SYN_DROPPED {
 				// Buffer overrun in the evdev client's event queue.
 				// Client should ignore all events up to and including next
@@ -308,21 +466,95 @@ func (dev *wacomDevice) inputEventPad(inputEvents []evdev.InputEvent) (events []
 				//           synthesize events. Since we don't do that just raise an error and
 				//           see how often this happens in practice. There is code in eventMux
 				//           to drop some events so hopefully we can keep up.
-				return []Event{newEventError(eventBufferOverrunError)}
+				return []Event{newEventError(ErrBufferOverrun)}
+			}
+		case evdev.EV_MSC:
+			if v.Code == evdev.MSC_TIMESTAMP {
+				dev.state.padTimestamp.update(v.Value, inputEventTime(&v))
 			}
 		case evdev.EV_KEY:
 			if button, ok := buttonCodeTrans[v.Code]; ok {
-				events = append(events, &EventButton{
+				events = append(events, dev.state.padAlloc.button(EventButton{
 					Timestamp: inputEventTime(&v),
 					Button:    button,
 					Pressure:  normalizeDigitalButtonValue(v.Value),
-				})
+				}))
 			}
+		case evdev.EV_ABS:
+			events = dev.padAxis(events, &v)
 		}
 	}
+	if dev.padModes != nil {
+		n := 0
+		for _, event := range events {
+			if event = dev.padModes.mapEvent(event); event != nil {
+				events[n] = event
+				n++
+			}
+		}
+		events = events[:n]
+	}
+	dev.state.padEvents = events
 	return
 }
 
+// padAxis appends ring and strip events of a pad axis input event.
+func (dev *wacomDevice) padAxis(events []Event, v *evdev.InputEvent) []Event {
+	ring, strip := -1, -1
+	switch v.Code {
+	case evdev.ABS_WHEEL:
+		ring = 0
+	case evdev.ABS_THROTTLE:
+		ring = 1
+	case evdev.ABS_RX:
+		strip = 0
+	case evdev.ABS_RY:
+		strip = 1
+	case evdev.ABS_MISC:
+		// Zero is reported when all pad controls are released.
+		if v.Value != 0 {
+			break
+		}
+		for i, touched := range dev.state.padRingsTouched {
+			if touched {
+				events = append(events, dev.state.padAlloc.padRing(EventPadRing{Timestamp: inputEventTime(v), Ring: i, Position: -1}))
+				dev.state.padRingsTouched[i] = false
+			}
+		}
+		for i, touched := range dev.state.padStripsTouched {
+			if touched {
+				events = append(events, dev.state.padAlloc.padStrip(EventPadStrip{Timestamp: inputEventTime(v), Strip: i, Position: -1}))
+				dev.state.padStripsTouched[i] = false
+			}
+		}
+	}
+
+	if ring >= 0 && ring < len(dev.params.padRings) {
+		dev.state.padRingsTouched[ring] = true
+		events = append(events, dev.state.padAlloc.padRing(EventPadRing{
+			Timestamp: inputEventTime(v),
+			Ring:      ring,
+			Position:  ringPosition(dev.params.padRings[ring], v.Value),
+		}))
+	}
+	if strip >= 0 && strip < len(dev.params.padStrips) {
+		// Zero is reported when the finger is lifted from the strip.
+		position := float32(-1)
+		if v.Value != 0 {
+			position = stripPosition(dev.params.padStrips[strip], v.Value)
+		} else if !dev.state.padStripsTouched[strip] {
+			return events
+		}
+		dev.state.padStripsTouched[strip] = v.Value != 0
+		events = append(events, dev.state.padAlloc.padStrip(EventPadStrip{
+			Timestamp: inputEventTime(v),
+			Strip:     strip,
+			Position:  position,
+		}))
+	}
+	return events
+}
+
 type wacomLinuxDeviceType int
 
 const (
@@ -331,15 +563,3 @@ const (
 	wacomLinuxDeviceTypePad
 	wacomLinuxDeviceTypes
 )
-
-func wacomLinuxDeviceTypeFromString(s string) wacomLinuxDeviceType {
-	switch s {
-	case "Pen":
-		return wacomLinuxDeviceTypePen
-	case "Finger":
-		return wacomLinuxDeviceTypeFinger
-	case "Pad":
-		return wacomLinuxDeviceTypePad
-	}
-	return wacomLinuxDeviceTypePen
-}
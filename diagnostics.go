@@ -0,0 +1,45 @@
+package chimp
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// NodeDiagnosis describes whether an input device node can be used and how to
+// fix it if not.
+type NodeDiagnosis struct {
+	Path     string         // Path of device node, e.g. /dev/input/event3.
+	Name     string         // Device name, empty if unknown.
+	Phys     string         // Physical location of device, empty if unknown.
+	Identity DeviceIdentity // Bus, vendor, product and unique ID of device.
+	Mode     os.FileMode    // Permissions of device node.
+	Owner    string         // Owner of device node.
+	Group    string         // Group of device node.
+	Err      error          // Error opening device node, nil if it could be opened.
+	Matcher  string         // Name of supported device claiming the node, empty if unsupported.
+	Fixes    []string       // Suggested fixes if the node could not be opened.
+}
+
+// DiagnoseDevices checks every input device node and reports whether it could
+// be opened, why not and which supported device would claim it. It can be used
+// to find out why ListDevices does not return an expected device.
+func DiagnoseDevices() ([]NodeDiagnosis, error) {
+	return diagnoseDevices()
+}
+
+func (d *NodeDiagnosis) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %q {Phys: %q, Mode: %s, Owner: %s:%s}\n    Identity:  %s",
+		d.Path, d.Name, d.Phys, d.Mode, d.Owner, d.Group, &d.Identity)
+	if d.Matcher != "" {
+		fmt.Fprintf(&sb, "\n    Supported: %s", d.Matcher)
+	}
+	if d.Err != nil {
+		fmt.Fprintf(&sb, "\n    Error:     %s", d.Err)
+	}
+	for _, v := range d.Fixes {
+		fmt.Fprintf(&sb, "\n    Fix:       %s", strings.Replace(v, "\n", "\n               ", -1))
+	}
+	return sb.String()
+}
@@ -0,0 +1,152 @@
+package chimp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/johan-bolmsjo/golang-evdev"
+)
+
+func diagnoseDevices() ([]NodeDiagnosis, error) {
+	paths, err := filepath.Glob("/dev/input/event*")
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(paths, func(i, j int) bool { return eventNodeNumber(paths[i]) < eventNodeNumber(paths[j]) })
+
+	matchers := deviceMatchers()
+	var diagnoses []NodeDiagnosis
+	for _, path := range paths {
+		diagnoses = append(diagnoses, diagnoseNode(path, matchers))
+	}
+	return diagnoses, nil
+}
+
+// eventNodeNumber returns the number of an event device node such as
+// /dev/input/event3 or -1 if it's not numbered.
+func eventNodeNumber(path string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(path), "event"))
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+func diagnoseNode(path string, matchers []deviceMatcher) NodeDiagnosis {
+	d := NodeDiagnosis{Path: path}
+
+	gid := -1
+	if fi, err := os.Stat(path); err == nil {
+		d.Mode = fi.Mode()
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			d.Owner = userName(st.Uid)
+			d.Group = groupName(st.Gid)
+			gid = int(st.Gid)
+		}
+	}
+
+	// Sysfs identifies the device even if the node can't be opened.
+	if info, err := readSysfsInputInfo(path); err == nil {
+		d.Name, d.Phys, d.Identity = info.name, info.phys, info.identity
+	}
+	if dev, err := evdev.Open(path); err != nil {
+		d.Err = newDeviceError("open", path, err)
+	} else {
+		d.Name, d.Phys, d.Identity = dev.Name, dev.Phys, inputDeviceIdentity(dev)
+		dev.File.Close()
+	}
+
+	devInfo := linuxDeviceInfo{dev: path, name: d.Name, phys: d.Phys, identity: d.Identity}
+	devInfo.group = linuxDeviceGroup(&devInfo)
+	for _, matcher := range matchers {
+		if match, _ := matcher.match(devInfo); match {
+			d.Matcher = matcher.name()
+			break
+		}
+	}
+
+	if d.Err != nil {
+		d.Fixes = diagnosisFixes(&d, gid)
+	}
+	return d
+}
+
+// diagnosisFixes suggests fixes for a device node that could not be opened.
+// The group ID of the node is -1 if unknown.
+func diagnosisFixes(d *NodeDiagnosis, gid int) []string {
+	if errors.Is(d.Err, ErrDisconnected) {
+		return []string{"The device was removed, reconnect it."}
+	}
+	if !errors.Is(d.Err, ErrPermission) {
+		return nil
+	}
+
+	var fixes []string
+	if u, err := user.Current(); err == nil && gid > 0 && d.Mode&0060 == 0060 {
+		switch {
+		case !processInGroup(gid) && userInGroup(u, gid):
+			fixes = append(fixes, fmt.Sprintf("User %s is a member of group %s but this session is not, log out and in again.",
+				u.Username, d.Group))
+		case !userInGroup(u, gid):
+			fixes = append(fixes, fmt.Sprintf("Add user %s to group %s, then log out and in again:\n  sudo usermod -aG %s %s",
+				u.Username, d.Group, d.Group, u.Username))
+		}
+	}
+	if d.Identity.Vendor != 0 || d.Identity.Product != 0 {
+		fixes = append(fixes, fmt.Sprintf(udevRuleFix, d.Identity.Vendor, d.Identity.Product))
+	}
+	return fixes
+}
+
+const udevRuleFix = `Grant access to logged in users with a udev rule in /etc/udev/rules.d/70-chimp.rules:
+  SUBSYSTEM=="input", KERNEL=="event*", ATTRS{id/vendor}=="%04x", ATTRS{id/product}=="%04x", TAG+="uaccess"
+Then reload and apply the rules:
+  sudo udevadm control --reload-rules && sudo udevadm trigger`
+
+// processInGroup checks if the process has group ID among its groups.
+func processInGroup(gid int) bool {
+	if os.Getegid() == gid {
+		return true
+	}
+	groups, _ := os.Getgroups()
+	for _, v := range groups {
+		if v == gid {
+			return true
+		}
+	}
+	return false
+}
+
+// userInGroup checks if the user is configured to be a member of group ID.
+func userInGroup(u *user.User, gid int) bool {
+	ids, _ := u.GroupIds()
+	for _, v := range ids {
+		if v == strconv.Itoa(gid) {
+			return true
+		}
+	}
+	return false
+}
+
+func userName(uid uint32) string {
+	id := strconv.FormatUint(uint64(uid), 10)
+	if u, err := user.LookupId(id); err == nil {
+		return u.Username
+	}
+	return id
+}
+
+func groupName(gid uint32) string {
+	id := strconv.FormatUint(uint64(gid), 10)
+	if g, err := user.LookupGroupId(id); err == nil {
+		return g.Name
+	}
+	return id
+}
@@ -7,6 +7,23 @@ Only Linux is supported at the moment but the Linux device events are converted
 to another representation exported by this package in a platform independent
 manner.
 
+Tablets that work like the supported ones can be described by a
+DeviceDescriptor, see ParseDeviceDescriptor and LoadDeviceDescriptors. Support
+for other devices can be added by implementing a Driver and registering it with
+RegisterDriver.
+
+Pad controls may be grouped in mode groups that are cycled by toggle buttons,
+see PadModeGroup.
+
+Properties and capabilities of supported devices can be completed with data from
+libwacom, see LoadLibwacomDatabase and UseLibwacomDatabase.
+
+User settings of devices are loaded from configuration files when they are
+opened and reloaded when the files change, see DeviceConfig. Buttons may be
+remapped or bound to application actions, see ButtonMapper. Clicks,
+double-clicks, long-presses and chords of buttons are recognized by
+ButtonGestureDetector.
+
 Supported devices:
 
 	Wacom Bamboo 16FG 6x8 (Linux)
@@ -0,0 +1,102 @@
+package chimp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Driver adds support for a device. Drivers are registered with RegisterDriver
+// and are consulted when devices are listed.
+//
+// Every input node, e.g. /dev/input/event3 on Linux, is offered to all drivers
+// in order of priority. The first driver that matches a node claims it. Nodes
+// claimed by a driver with the same group ID form one device, e.g. the pen,
+// finger and pad nodes of a tablet.
+type Driver interface {
+	// Name returns the name of the driver.
+	Name() string
+
+	// Priority of the driver. Drivers with higher priority are offered nodes
+	// before drivers with lower priority. Built-in drivers have priority
+	// DriverPriorityBuiltin, use a higher priority to replace them.
+	Priority() int
+
+	// Match checks if the driver supports an input node and returns the
+	// group ID of the device the node belongs to. InputNode.Group may be used
+	// as or in the group ID to group nodes of one physical device.
+	Match(node *InputNode) (groupID string, ok bool)
+
+	// Describe returns the name and type of the device formed by nodes. It's
+	// called when devices are listed.
+	Describe(nodes []InputNode) (name string, deviceType DeviceType)
+
+	// NewHandler creates a handler of the device formed by nodes. It's called
+	// every time the device is opened.
+	NewHandler(nodes []InputNode) (DriverHandler, error)
+}
+
+// DriverHandler translates input events of an opened device to events
+// exported by this package.
+type DriverHandler interface {
+	// Properties returns properties of the device. Identity properties such
+	// as PropertyVendorID are added by this package.
+	Properties() Properties
+
+	// Capabilities returns capabilities of the device. It's called once when
+	// the device is opened.
+	Capabilities() *Capabilities
+
+	// InputEvents translates a group of input events read from nodes[node],
+	// terminated by a SYN_REPORT event, to zero or more events. The input
+	// events are only valid during the call. Event groups terminated by
+	// SYN_DROPPED are not passed to the handler but reported as
+	// ErrBufferOverrun.
+	InputEvents(node int, inputEvents []InputEvent) []Event
+}
+
+// DriverPriorityBuiltin is the priority of drivers built into this package.
+const DriverPriorityBuiltin = 0
+
+// InputNode is information about an input device node.
+type InputNode struct {
+	Path     string         // Path of device node, e.g. /dev/input/event3.
+	Name     string         // Device name.
+	Phys     string         // Physical location of device.
+	Identity DeviceIdentity // Bus, vendor, product and unique ID of device.
+	Group    string         // Shared by all nodes of one physical device.
+}
+
+// InputEvent is a raw input event read from an input device node. Type, code
+// and value are defined by the Linux input subsystem.
+type InputEvent struct {
+	Time  time.Time // Time when event was generated.
+	Type  uint16    // Event type, e.g. EV_KEY.
+	Code  uint16    // Event code, e.g. BTN_LEFT.
+	Value int32     // Event value.
+}
+
+func (e *InputEvent) String() string {
+	return fmt.Sprintf("InputEvent: {Time: %s, Type: %d, Code: %d, Value: %d}", e.Time, e.Type, e.Code, e.Value)
+}
+
+var drivers struct {
+	sync.Mutex
+	list []Driver
+}
+
+// RegisterDriver registers a driver that is used by ListDevices. Drivers with
+// the same priority are consulted in order of registration, after built-in
+// drivers.
+func RegisterDriver(driver Driver) {
+	drivers.Lock()
+	drivers.list = append(drivers.list, driver)
+	drivers.Unlock()
+}
+
+// registeredDrivers returns registered drivers in order of registration.
+func registeredDrivers() []Driver {
+	drivers.Lock()
+	defer drivers.Unlock()
+	return append([]Driver(nil), drivers.list...)
+}
@@ -0,0 +1,168 @@
+package chimp
+
+import (
+	evdev "github.com/johan-bolmsjo/golang-evdev"
+)
+
+// driverMatcher matches devices with a registered driver.
+type driverMatcher struct {
+	driver Driver
+}
+
+func (matcher *driverMatcher) name() string {
+	return matcher.driver.Name()
+}
+
+func (matcher *driverMatcher) priority() int {
+	return matcher.driver.Priority()
+}
+
+func (matcher *driverMatcher) match(devInfo linuxDeviceInfo) (match bool, logicalID string) {
+	node := devInfo.inputNode()
+	groupID, match := matcher.driver.Match(&node)
+	if match {
+		// Avoid clashes with group IDs of other drivers.
+		logicalID = matcher.driver.Name() + " " + groupID
+	}
+	return
+}
+
+func (matcher *driverMatcher) newLogicalDevice() logicalDevice {
+	return &driverLogicalDevice{driver: matcher.driver}
+}
+
+// inputNode converts Linux device information to the representation used by
+// drivers.
+func (info *linuxDeviceInfo) inputNode() InputNode {
+	return InputNode{
+		Path:     info.dev,
+		Name:     info.name,
+		Phys:     info.phys,
+		Identity: info.identity,
+		Group:    info.group,
+	}
+}
+
+type driverLogicalDevice struct {
+	driver       Driver
+	linuxDevices []linuxDeviceInfo
+}
+
+func (logicalDevice *driverLogicalDevice) addLinuxDevice(devInfo linuxDeviceInfo) {
+	logicalDevice.linuxDevices = append(logicalDevice.linuxDevices, devInfo)
+}
+
+func (logicalDevice *driverLogicalDevice) nodes() []InputNode {
+	var nodes []InputNode
+	for _, v := range logicalDevice.linuxDevices {
+		nodes = append(nodes, v.inputNode())
+	}
+	return nodes
+}
+
+func (logicalDevice *driverLogicalDevice) deviceInfo() DeviceInfo {
+	name, deviceType := logicalDevice.driver.Describe(logicalDevice.nodes())
+	identity := logicalDevice.linuxDevices[0].identity
+	return DeviceInfo{
+		Name:     name,
+		Type:     deviceType,
+		Identity: identity,
+		ID:       identity.StableID(),
+		Open:     logicalDevice.Open,
+		OpenWith: logicalDevice.OpenWith,
+	}
+}
+
+func (logicalDevice *driverLogicalDevice) Open() (Device, error) {
+	return logicalDevice.OpenWith(OpenOptions{})
+}
+
+func (logicalDevice *driverLogicalDevice) OpenWith(opts OpenOptions) (Device, error) {
+	var err error
+	inputDevices := make([]*evdev.InputDevice, len(logicalDevice.linuxDevices))
+
+	closeInputDevices := func() {
+		for _, v := range inputDevices {
+			if v != nil {
+				v.File.Close()
+			}
+		}
+	}
+
+	for i, v := range logicalDevice.linuxDevices {
+		if inputDevices[i], err = v.openDevice(opts); err != nil {
+			closeInputDevices()
+			return nil, err
+		}
+	}
+
+	handler, err := logicalDevice.driver.NewHandler(logicalDevice.nodes())
+	if err != nil {
+		closeInputDevices()
+		return nil, err
+	}
+
+	identity := &logicalDevice.linuxDevices[0].identity
+	var capabilities Capabilities
+	if v := handler.Capabilities(); v != nil {
+		capabilities = *v
+	}
+	dev := &driverDevice{
+		eventMux:   newEventMux(opts.Clock),
+		handler:    handler,
+		properties: identity.properties(handler.Properties()),
+	}
+	dev.capabilities = libwacomDescribe(identity, dev.properties, capabilities)
+	for i, v := range inputDevices {
+		node := i
+		dev.addEventSource(v, func(inputEvents []evdev.InputEvent) []Event {
+			return dev.inputEvents(node, inputEvents)
+		})
+	}
+	if err := dev.start(); err != nil {
+		closeInputDevices()
+		return nil, err
+	}
+	dev.loadConfig(dev, identity.StableID(), opts)
+	return dev, nil
+}
+
+// driverDevice is an opened device of a registered driver.
+type driverDevice struct {
+	eventMux
+	handler      DriverHandler
+	properties   Properties
+	capabilities Capabilities
+
+	// Converted input events, reused between calls.
+	buf []InputEvent
+}
+
+func (dev *driverDevice) Properties() Properties {
+	return dev.properties
+}
+
+func (dev *driverDevice) Capabilities() *Capabilities {
+	return &dev.capabilities
+}
+
+// inputEvents converts a group of Linux input events and passes them to the
+// driver handler.
+func (dev *driverDevice) inputEvents(node int, inputEvents []evdev.InputEvent) []Event {
+	if last := &inputEvents[len(inputEvents)-1]; last.Code == evdev.SYN_DROPPED {
+		return []Event{newEventError(ErrBufferOverrun)}
+	}
+
+	buf := dev.buf[:0]
+	for i := range inputEvents {
+		v := &inputEvents[i]
+		buf = append(buf, InputEvent{
+			Time:  inputEventTime(v),
+			Type:  v.Type,
+			Code:  v.Code,
+			Value: v.Value,
+		})
+	}
+	dev.buf = buf
+	return dev.handler.InputEvents(node, buf)
+}
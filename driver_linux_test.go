@@ -0,0 +1,117 @@
+package chimp
+
+import (
+	"errors"
+	"testing"
+)
+
+// testDriver is a driver that claims all nodes with a name in names.
+type testDriver struct {
+	driverName string
+	priority   int
+	names      []string
+}
+
+func (driver *testDriver) Name() string  { return driver.driverName }
+func (driver *testDriver) Priority() int { return driver.priority }
+
+func (driver *testDriver) Match(node *InputNode) (string, bool) {
+	for _, name := range driver.names {
+		if node.Name == name {
+			return node.Group, true
+		}
+	}
+	return "", false
+}
+
+func (driver *testDriver) Describe(nodes []InputNode) (string, DeviceType) {
+	return driver.driverName, DeviceTypeTablet
+}
+
+func (driver *testDriver) NewHandler(nodes []InputNode) (DriverHandler, error) {
+	return nil, errors.New("not supported")
+}
+
+// useDrivers replaces the registered drivers until the test ends.
+func useDrivers(t *testing.T, list ...Driver) {
+	drivers.Lock()
+	saved := drivers.list
+	drivers.list = nil
+	drivers.Unlock()
+	for _, driver := range list {
+		RegisterDriver(driver)
+	}
+	t.Cleanup(func() {
+		drivers.Lock()
+		drivers.list = saved
+		drivers.Unlock()
+	})
+}
+
+func TestDriverPriority(t *testing.T) {
+	const bamboo = "Wacom Bamboo 16FG 6x8"
+	bambooPen := linuxDeviceInfo{
+		dev:      "/dev/input/event5",
+		name:     bamboo + " Pen",
+		identity: DeviceIdentity{BusType: BusTypeUSB, Vendor: 0x056a, Product: 0x00df},
+		group:    "usb-1",
+	}
+	otherPen := linuxDeviceInfo{dev: "/dev/input/event9", name: "Other Pen", group: "usb-2"}
+	names := []string{bambooPen.name, otherPen.name}
+
+	for _, test := range []struct {
+		name    string
+		drivers []Driver
+		dev     linuxDeviceInfo
+		want    string
+	}{
+		{"built-in", nil, bambooPen, bamboo},
+		{"unknown device", nil, otherPen, ""},
+		{
+			"equal priority after built-in",
+			[]Driver{&testDriver{"equal", DriverPriorityBuiltin, names}},
+			bambooPen, bamboo,
+		},
+		{
+			"higher priority replaces built-in",
+			[]Driver{&testDriver{"equal", DriverPriorityBuiltin, names}, &testDriver{"higher", DriverPriorityBuiltin + 1, names}},
+			bambooPen, "higher",
+		},
+		{
+			"lower priority after built-in",
+			[]Driver{&testDriver{"lower", DriverPriorityBuiltin - 1, names}},
+			bambooPen, bamboo,
+		},
+		{
+			"lower priority claims unknown device",
+			[]Driver{&testDriver{"lower", DriverPriorityBuiltin - 1, names}},
+			otherPen, "lower",
+		},
+		{
+			"equal priority in order of registration",
+			[]Driver{&testDriver{"first", 1, names}, &testDriver{"second", 1, names}},
+			bambooPen, "first",
+		},
+	} {
+		useDrivers(t, test.drivers...)
+		got := ""
+		if matcher, _ := matchLinuxDevice(deviceMatchers(), test.dev); matcher != nil {
+			got = matcher.name()
+		}
+		if got != test.want {
+			t.Errorf("%s: matched by %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDriverLogicalID(t *testing.T) {
+	// Group IDs of drivers don't clash with each other.
+	a := &driverMatcher{driver: &testDriver{"a", 0, []string{"Pen"}}}
+	b := &driverMatcher{driver: &testDriver{"b", 0, []string{"Pen"}}}
+	dev := linuxDeviceInfo{name: "Pen", group: "usb-1"}
+	_, idA := a.match(dev)
+	_, idB := b.match(dev)
+	if idA == idB {
+		t.Errorf("drivers share logical ID %q", idA)
+	}
+}
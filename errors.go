@@ -1,5 +1,78 @@
 package chimp
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
-var eventBufferOverrunError = errors.New("event buffer overrun")
+// Errors reported when opening and reading devices. Returned errors may wrap
+// these and should be tested with errors.Is.
+var (
+	// ErrDisconnected is reported when a device is unplugged or otherwise
+	// removed. The device may be listed and opened again when it's back.
+	ErrDisconnected = errors.New("device disconnected")
+
+	// ErrPermission is reported when a device node can't be opened due to
+	// missing permissions. This is usually fixed by adding the user to the
+	// group owning the device node or with a udev rule.
+	ErrPermission = errors.New("permission denied")
+
+	// ErrIdentityChanged is reported when a device node no longer identifies
+	// the device that was listed, e.g. because devices were renumbered when
+	// replugged. Listing devices again resolves it.
+	ErrIdentityChanged = errors.New("device identity changed")
+
+	// ErrBufferOverrun is reported when input events were lost because they
+	// were not read fast enough. The device is closed. It's wrapped in a
+	// DeviceError with the path of the device node that lost events.
+	ErrBufferOverrun = errors.New("event buffer overrun")
+
+	// ErrClosed is reported when reading from a closed device.
+	ErrClosed = errors.New("device closed")
+
+	// ErrGrabConflict is reported when a device can't be opened for exclusive
+	// access because another program has grabbed it.
+	ErrGrabConflict = errors.New("device grabbed by another program")
+)
+
+// DeviceError records an error and the operation and device node that caused
+// it. It matches one of the package errors with errors.Is when the cause is
+// known, and the underlying error is available with errors.Unwrap.
+type DeviceError struct {
+	Op   string // Operation that failed, e.g. "open", "grab" or "read".
+	Path string // Path of device node.
+	Err  error  // Underlying error.
+
+	kind error // Package error matched by Is.
+}
+
+func (e *DeviceError) Error() string {
+	return e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *DeviceError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DeviceError) Is(target error) bool {
+	return e.kind != nil && target == e.kind
+}
+
+// IdentityChangedError is reported when an opened device node does not match
+// the name and physical location recorded when devices were listed. It
+// matches ErrIdentityChanged with errors.Is.
+type IdentityChangedError struct {
+	Path       string // Path of device node.
+	Name, Phys string // Name and physical location when listed.
+	OpenedName string // Name of opened device.
+	OpenedPhys string // Physical location of opened device.
+}
+
+func (e *IdentityChangedError) Error() string {
+	return fmt.Sprintf("opened input device %s {%s, %s} does not match saved parameters {%s, %s}",
+		e.Path, e.OpenedName, e.OpenedPhys, e.Name, e.Phys)
+}
+
+func (e *IdentityChangedError) Is(target error) bool {
+	return target == ErrIdentityChanged
+}
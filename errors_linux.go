@@ -0,0 +1,22 @@
+package chimp
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// newDeviceError creates a device error, classifying the underlying error as
+// one of the package errors if possible.
+func newDeviceError(op, path string, err error) *DeviceError {
+	e := &DeviceError{Op: op, Path: path, Err: err}
+	switch {
+	case errors.Is(err, os.ErrPermission):
+		e.kind = ErrPermission
+	case errors.Is(err, syscall.ENODEV) || errors.Is(err, syscall.ENXIO) || errors.Is(err, syscall.ENOENT):
+		e.kind = ErrDisconnected
+	case op == "grab" && errors.Is(err, syscall.EBUSY):
+		e.kind = ErrGrabConflict
+	}
+	return e
+}
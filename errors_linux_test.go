@@ -0,0 +1,84 @@
+package chimp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+
+	evdev "github.com/johan-bolmsjo/golang-evdev"
+)
+
+func TestDeviceErrors(t *testing.T) {
+	const path = "/dev/input/event5"
+	for _, test := range []struct {
+		name string
+		op   string
+		err  error
+		kind error // Nil if no package error should match.
+	}{
+		{"permission", "open", &os.PathError{Op: "open", Path: path, Err: syscall.EACCES}, ErrPermission},
+		{"unplugged", "read", syscall.ENODEV, ErrDisconnected},
+		{"removed node", "open", &os.PathError{Op: "open", Path: path, Err: syscall.ENOENT}, ErrDisconnected},
+		{"no device", "open", syscall.ENXIO, ErrDisconnected},
+		{"grabbed", "grab", syscall.EBUSY, ErrGrabConflict},
+		{"busy", "read", syscall.EBUSY, nil},
+		{"buffer overrun", "read", ErrBufferOverrun, ErrBufferOverrun},
+		{"other", "read", syscall.EIO, nil},
+	} {
+		// Callers may wrap device errors further.
+		err := fmt.Errorf("wrapped: %w", newDeviceError(test.op, path, test.err))
+
+		for _, kind := range []error{ErrDisconnected, ErrPermission, ErrIdentityChanged, ErrBufferOverrun, ErrClosed, ErrGrabConflict} {
+			if got, want := errors.Is(err, kind), kind == test.kind; got != want {
+				t.Errorf("%s: errors.Is(%v) = %t, want %t", test.name, kind, got, want)
+			}
+		}
+		var deviceErr *DeviceError
+		if !errors.As(err, &deviceErr) {
+			t.Errorf("%s: not a DeviceError", test.name)
+			continue
+		}
+		if deviceErr.Op != test.op || deviceErr.Path != path || deviceErr.Err != test.err {
+			t.Errorf("%s: got %+v", test.name, deviceErr)
+		}
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: underlying error not reached", test.name)
+		}
+	}
+}
+
+func TestIdentityChangedError(t *testing.T) {
+	err := fmt.Errorf("open: %w", &IdentityChangedError{Path: "/dev/input/event5", Name: "Pen", OpenedName: "Mouse"})
+	if !errors.Is(err, ErrIdentityChanged) || errors.Is(err, ErrDisconnected) {
+		t.Error("identity change not matched")
+	}
+	var identityErr *IdentityChangedError
+	if !errors.As(err, &identityErr) || identityErr.OpenedName != "Mouse" {
+		t.Errorf("got %v", identityErr)
+	}
+}
+
+func TestBufferOverrunPath(t *testing.T) {
+	source, write := newTestInputSource(t, "/dev/input/event5")
+	source.inputEventFunc = func(inputEvents []evdev.InputEvent) []Event {
+		if last := &inputEvents[len(inputEvents)-1]; last.Code == evdev.SYN_DROPPED {
+			return []Event{newEventError(ErrBufferOverrun)}
+		}
+		return nil
+	}
+	mux := newEventMux(ClockRealtime)
+	mux.sources = []*inputSource{source}
+	if err := mux.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer mux.Close()
+
+	write(inputEvent(evdev.EV_ABS, evdev.ABS_X, 1), inputEvent(evdev.EV_SYN, evdev.SYN_DROPPED, 0))
+	_, err := mux.Read()
+	var deviceErr *DeviceError
+	if !errors.Is(err, ErrBufferOverrun) || !errors.As(err, &deviceErr) || deviceErr.Path != "/dev/input/event5" {
+		t.Errorf("got %v, want buffer overrun of /dev/input/event5", err)
+	}
+}
@@ -2,6 +2,7 @@ package chimp
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -14,11 +15,142 @@ type Event interface {
 	String() string
 }
 
+// EventType is an enumeration of the event types exported by this package.
+type EventType uint32
+
+//go:generate stringer -type=EventType -trimprefix=EventType
+
+const (
+	EventTypePositionPen EventType = iota
+	EventTypePositionFinger
+	EventTypeButton
+	EventTypeMotionPen
+	EventTypeGesture
+	EventTypeAction
+	EventTypePadRing
+	EventTypePadStrip
+	EventTypePadMode
+	EventTypeButtonGesture
+	EventTypeProximityPen
+	EventTypeOther // Event types not known by this package.
+)
+
+// EventTypeOf returns the type of event.
+func EventTypeOf(event Event) EventType {
+	switch event.(type) {
+	case *EventPositionPen:
+		return EventTypePositionPen
+	case *EventPositionFinger:
+		return EventTypePositionFinger
+	case *EventButton:
+		return EventTypeButton
+	case *EventMotionPen:
+		return EventTypeMotionPen
+	case *EventGesture:
+		return EventTypeGesture
+	case *EventAction:
+		return EventTypeAction
+	case *EventPadRing:
+		return EventTypePadRing
+	case *EventPadStrip:
+		return EventTypePadStrip
+	case *EventPadMode:
+		return EventTypePadMode
+	case *EventButtonGesture:
+		return EventTypeButtonGesture
+	case *EventProximityPen:
+		return EventTypeProximityPen
+	}
+	return EventTypeOther
+}
+
+// CopyEvent returns a copy of an event of a type known by this package,
+// including the slices it refers to, that may be changed without affecting the
+// original. Events of other types are returned as is.
+func CopyEvent(event Event) Event {
+	switch e := event.(type) {
+	case *EventPositionPen:
+		c := *e
+		c.History = append([]EventPositionPen(nil), e.History...)
+		return &c
+	case *EventPositionFinger:
+		c := *e
+		c.Contacts = copyContacts(e.Contacts)
+		if e.History != nil {
+			c.History = make([]EventPositionFinger, len(e.History))
+			for i := range e.History {
+				c.History[i] = e.History[i]
+				c.History[i].Contacts = copyContacts(e.History[i].Contacts)
+			}
+		}
+		return &c
+	case *EventButton:
+		c := *e
+		return &c
+	case *EventMotionPen:
+		c := *e
+		return &c
+	case *EventGesture:
+		c := *e
+		return &c
+	case *EventAction:
+		c := *e
+		return &c
+	case *EventPadRing:
+		c := *e
+		return &c
+	case *EventPadStrip:
+		c := *e
+		return &c
+	case *EventPadMode:
+		c := *e
+		return &c
+	case *EventButtonGesture:
+		c := *e
+		c.Buttons = append([]Button(nil), e.Buttons...)
+		return &c
+	case *EventProximityPen:
+		c := *e
+		return &c
+	}
+	return event
+}
+
+// copyContacts copies contacts, keeping nil and empty slices apart as nil
+// contacts mark single touch devices.
+func copyContacts(contacts []Contact) []Contact {
+	if contacts == nil {
+		return nil
+	}
+	return append([]Contact{}, contacts...)
+}
+
 // EventPositionPen is generated for movement of pen on a typical 2D tablet.
 type EventPositionPen struct {
 	Timestamp time.Time // Time when event was generated.
-	Coord     Coord2D   // Pen position on tablet, axis are in range [0, 1], origo in upper left corner.
-	Distance  float32   // Distance of for example pen to tablet in range [0, 1], 0 is on tablet.
+	Delivered time.Time // Time when event was read from device, same clock as Timestamp.
+
+	// Time of the device clock when event was generated, reported by some
+	// devices with MSC_TIMESTAMP. It has an unspecified epoch and is only set
+	// if HasDeviceTimestamp is true. Device timestamps may be zero.
+	DeviceTimestamp    time.Duration
+	HasDeviceTimestamp bool
+
+	Coord    Coord2D // Pen position on tablet, axis are in range [0, 1], origo in upper left corner.
+	Distance float32 // Distance of for example pen to tablet in range [0, 1], 0 is on tablet.
+
+	// End of the pen in proximity, ButtonPenTip or ButtonPenEraser, and ID
+	// of the tool as reported by ABS_MISC, zero if not reported. See
+	// LibwacomDatabase.ToolName.
+	Tool   Button
+	ToolID uint32
+
+	Kinematics Kinematics // Set by KinematicsFilter.
+
+	// Events merged into this one by motion coalescing, oldest first.
+	// Coalesced is the number of merged events, History may be truncated.
+	Coalesced int
+	History   []EventPositionPen
 }
 
 func (e *EventPositionPen) Time() time.Time {
@@ -26,13 +158,53 @@ func (e *EventPositionPen) Time() time.Time {
 }
 
 func (e *EventPositionPen) String() string {
-	return fmt.Sprintf(fmtEventPositionPen, e.Timestamp, e.Coord.X, e.Coord.Y, e.Distance)
+	return fmt.Sprintf(fmtEventPositionPen, e.Timestamp, e.Coord.X, e.Coord.Y, e.Distance, kinematicsString(&e.Kinematics))
+}
+
+// EventProximityPen is generated when a pen tool enters or leaves the
+// proximity of the tablet. It's generated before the position events of a
+// tool entering and after the events of a tool leaving.
+type EventProximityPen struct {
+	Timestamp time.Time // Time when event was generated.
+	Delivered time.Time // Time when event was read from device, same clock as Timestamp.
+
+	// Time of the device clock when event was generated, see EventButton.
+	DeviceTimestamp    time.Duration
+	HasDeviceTimestamp bool
+
+	InProximity bool   // True when the tool entered proximity, false when it left.
+	Tool        Button // ButtonPenTip or ButtonPenEraser, see EventPositionPen.
+	ToolID      uint32 // ID of the tool, see EventPositionPen.
+}
+
+func (e *EventProximityPen) Time() time.Time {
+	return e.Timestamp
+}
+
+func (e *EventProximityPen) String() string {
+	return fmt.Sprintf(fmtEventProximityPen, e.Timestamp, e.InProximity, e.Tool, e.ToolID)
 }
 
 // EventPositionFinger is generated for movement of finger on tablet or similar.
 type EventPositionFinger struct {
 	Timestamp time.Time // Time when event was generated.
-	Coord     Coord2D   // Finger position, axis are in range [0, 1], origo in upper left corner
+	Delivered time.Time // Time when event was read from device, same clock as Timestamp.
+
+	// Time of the device clock when event was generated, reported by some
+	// devices with MSC_TIMESTAMP. It has an unspecified epoch and is only set
+	// if HasDeviceTimestamp is true. Device timestamps may be zero.
+	DeviceTimestamp    time.Duration
+	HasDeviceTimestamp bool
+
+	Coord    Coord2D   // Finger position, axis are in range [0, 1], origo in upper left corner
+	Contacts []Contact // All fingers on multi touch devices, nil if multi touch is not supported.
+
+	Kinematics Kinematics // Set by KinematicsFilter.
+
+	// Events merged into this one by motion coalescing, oldest first.
+	// Coalesced is the number of merged events, History may be truncated.
+	Coalesced int
+	History   []EventPositionFinger
 }
 
 func (e *EventPositionFinger) Time() time.Time {
@@ -40,7 +212,13 @@ func (e *EventPositionFinger) Time() time.Time {
 }
 
 func (e *EventPositionFinger) String() string {
-	return fmt.Sprintf(fmtEventPositionFinger, e.Timestamp, e.Coord.X, e.Coord.Y)
+	return fmt.Sprintf(fmtEventPositionFinger, e.Timestamp, e.Coord.X, e.Coord.Y, contactsString(e.Contacts), kinematicsString(&e.Kinematics))
+}
+
+// Contact is a finger touching a multi touch device.
+type Contact struct {
+	ID    int32   // Contact ID, unique for as long as the finger touches the pad.
+	Coord Coord2D // Finger position, axis are in range [0, 1], origo in upper left corner
 }
 
 // PositionDevice is an enumeration of different position device types.
@@ -57,8 +235,16 @@ const (
 // analogue button.
 type EventButton struct {
 	Timestamp time.Time // Time when event was generated.
-	Button    Button    // Button code.
-	Pressure  float32   // Pressure on button in range [0, 1], 0 or 1 for digital buttons.
+	Delivered time.Time // Time when event was read from device, same clock as Timestamp.
+
+	// Time of the device clock when event was generated, reported by some
+	// devices with MSC_TIMESTAMP. It has an unspecified epoch and is only set
+	// if HasDeviceTimestamp is true. Device timestamps may be zero.
+	DeviceTimestamp    time.Duration
+	HasDeviceTimestamp bool
+
+	Button   Button  // Button code.
+	Pressure float32 // Pressure on button in range [0, 1], 0 or 1 for digital buttons.
 }
 
 func (e *EventButton) Time() time.Time {
@@ -91,15 +277,47 @@ const fmtEventPositionPen = `EventPositionPen: {
     Time:     %s
     X:        %f
     Y:        %f
-    Distance: %f
+    Distance: %f%s
+}`
+
+const fmtEventProximityPen = `EventProximityPen: {
+    Time:     %s
+    In:       %t
+    Tool:     %s
+    ToolID:   %#x
 }`
 
 const fmtEventPositionFinger = `EventPositionFinger: {
     Time:     %s
     X:        %f
-    Y:        %f
+    Y:        %f%s%s
 }`
 
+// Contacts are only included in event strings for multi touch devices.
+func contactsString(contacts []Contact) string {
+	if contacts == nil {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n    Contacts: [")
+	for i, v := range contacts {
+		if i > 0 {
+			sb.WriteString(" ")
+		}
+		fmt.Fprintf(&sb, "%d:%s", v.ID, &v.Coord)
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
+
+// Kinematics is only included in event strings when it has been computed.
+func kinematicsString(k *Kinematics) string {
+	if !k.Valid {
+		return ""
+	}
+	return "\n    Motion:   " + k.String()
+}
+
 const fmtEventButton = `EventButton: {
     Time:     %s
     Name:     %s
@@ -0,0 +1,107 @@
+package chimp
+
+import "context"
+
+// EventFilter processes events read from a device. Filters can be used to
+// annotate, transform, drop or synthesize events.
+type EventFilter interface {
+	// Filter processes an event and returns zero or more events.
+	Filter(event Event) []Event
+}
+
+// EventFilterFunc is an adapter to use ordinary functions as event filters.
+type EventFilterFunc func(event Event) []Event
+
+func (f EventFilterFunc) Filter(event Event) []Event {
+	return f(event)
+}
+
+// NewFilteredDevice returns a device that passes all events read from dev
+// through the filters in order. The output of one filter is the input of the
+// next one.
+//
+// The returned device only implements the methods of Device. Optional
+// interfaces of dev, such as PalmRejecter, MotionCoalescer, PadModeDevice and
+// ConfiguredDevice, are reached through its Unwrap method:
+//
+//	if v, ok := filtered.(interface{ Unwrap() Device }); ok {
+//		if p, ok := v.Unwrap().(PalmRejecter); ok {
+//			p.SetPalmRejection(policy)
+//		}
+//	}
+func NewFilteredDevice(dev Device, filters ...EventFilter) Device {
+	return &filteredDevice{
+		Device:  dev,
+		filters: filters,
+	}
+}
+
+type filteredDevice struct {
+	Device
+	filters []EventFilter
+	pending []Event // Filtered events not yet read.
+	batch   []Event // Unfiltered events read in batch.
+}
+
+// Unwrap returns the device that events are read from.
+func (dev *filteredDevice) Unwrap() Device {
+	return dev.Device
+}
+
+func (dev *filteredDevice) Read() (Event, error) {
+	return dev.ReadContext(context.Background())
+}
+
+func (dev *filteredDevice) ReadContext(ctx context.Context) (Event, error) {
+	for len(dev.pending) == 0 {
+		event, err := dev.Device.ReadContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		dev.pending = filterEvents(dev.filters, []Event{event})
+	}
+
+	event := dev.pending[0]
+	dev.pending[0] = nil
+	dev.pending = dev.pending[1:]
+	return event, nil
+}
+
+func (dev *filteredDevice) ReadBatch(ctx context.Context, buf []Event) (n int, err error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	for len(dev.pending) == 0 {
+		if cap(dev.batch) < len(buf) {
+			dev.batch = make([]Event, len(buf))
+		}
+		var m int
+		m, err = dev.Device.ReadBatch(ctx, dev.batch[:len(buf)])
+		dev.pending = append(dev.pending[:0], filterEvents(dev.filters, dev.batch[:m])...)
+		for i := range dev.batch[:m] {
+			dev.batch[i] = nil
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	n = copy(buf, dev.pending)
+	for i := range dev.pending[:n] {
+		dev.pending[i] = nil
+	}
+	dev.pending = dev.pending[n:]
+	return n, err
+}
+
+// filterEvents passes events through all filters.
+func filterEvents(filters []EventFilter, events []Event) []Event {
+	for _, filter := range filters {
+		var out []Event
+		for _, event := range events {
+			out = append(out, filter.Filter(event)...)
+		}
+		events = out
+	}
+	return events
+}
@@ -0,0 +1,43 @@
+package chimp
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFilteredDevice(t *testing.T) {
+	dev := newTestDevice()
+	double := EventFilterFunc(func(event Event) []Event {
+		return []Event{event, CopyEvent(event)}
+	})
+	dropRelease := EventFilterFunc(func(event Event) []Event {
+		if e, ok := event.(*EventButton); ok && e.Pressure == 0 {
+			return nil
+		}
+		return []Event{event}
+	})
+	filtered := NewFilteredDevice(dev, double, dropRelease)
+
+	go func() {
+		dev.events <- &EventButton{Button: ButtonPen1}
+		dev.events <- &EventButton{Button: ButtonPen1, Pressure: 1}
+		close(dev.events)
+	}()
+	for i := 0; i < 2; i++ {
+		event, err := filtered.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e := event.(*EventButton); e.Pressure != 1 {
+			t.Fatalf("read %v", e)
+		}
+	}
+	if _, err := filtered.ReadContext(context.Background()); err != ErrClosed {
+		t.Fatalf("read after close gave %v", err)
+	}
+
+	v, ok := filtered.(interface{ Unwrap() Device })
+	if !ok || v.Unwrap() != dev {
+		t.Fatal("filtered device does not unwrap to its device")
+	}
+}
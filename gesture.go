@@ -0,0 +1,365 @@
+package chimp
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// EventGesture is generated by GestureRecognizer for recognized finger gestures.
+type EventGesture struct {
+	Timestamp time.Time    // Time when event was generated.
+	Gesture   Gesture      // Recognized gesture.
+	Phase     GesturePhase // Phase of gesture.
+	Fingers   int          // Number of fingers performing the gesture.
+	Center    Coord2D      // Center of fingers, axis are in range [0, 1], origo in upper left corner.
+	Scale     float32      // Pinch: finger distance relative to when the fingers were placed.
+	Angle     float32      // Rotate: radians rotated since the fingers were placed, clockwise is positive.
+	Delta     Coord2D      // Pan: movement of center in millimeters since previous pan event.
+}
+
+func (e *EventGesture) Time() time.Time {
+	return e.Timestamp
+}
+
+func (e *EventGesture) String() string {
+	return fmt.Sprintf(fmtEventGesture, e.Timestamp, e.Gesture, e.Phase, e.Fingers, &e.Center, e.Scale, e.Angle, &e.Delta)
+}
+
+const fmtEventGesture = `EventGesture: {
+    Time:     %s
+    Gesture:  %s
+    Phase:    %s
+    Fingers:  %d
+    Center:   %s
+    Scale:    %f
+    Angle:    %f
+    Delta:    %s
+}`
+
+// Gesture is an enumeration of finger gestures.
+type Gesture uint32
+
+//go:generate stringer -type=Gesture -trimprefix=Gesture
+
+const (
+	GesturePinch     Gesture = iota // Two fingers moving apart or together
+	GestureRotate                   // Two fingers rotating around their center
+	GesturePan                      // Fingers moving together
+	GestureTap                      // Fingers briefly touching the pad
+	GestureLongPress                // One finger resting on the pad
+)
+
+// GesturePhase is an enumeration of gesture phases. Continuous gestures are
+// reported as began, changed zero or more times and ended. Taps are only
+// reported as ended.
+type GesturePhase uint32
+
+//go:generate stringer -type=GesturePhase -trimprefix=GesturePhase
+
+const (
+	GesturePhaseBegan GesturePhase = iota
+	GesturePhaseChanged
+	GesturePhaseEnded
+)
+
+// GestureConfig holds thresholds used to recognize gestures.
+type GestureConfig struct {
+	TapMaxDuration    time.Duration // Longest touch recognized as a tap.
+	TapMaxMillimeters float32       // Maximum finger movement of taps and long-presses.
+	LongPressDuration time.Duration // Shortest touch recognized as a long-press.
+	PanFingers        int           // Number of fingers used to pan.
+	PanMinMillimeters float32       // Movement of the finger center needed to begin pan.
+	PinchMinScale     float32       // Relative change of finger distance needed to begin pinch.
+	RotateMinAngle    float32       // Rotation in radians needed to begin rotate.
+}
+
+// DefaultGestureConfig is a reasonable gesture configuration.
+var DefaultGestureConfig = GestureConfig{
+	TapMaxDuration:    200 * time.Millisecond,
+	TapMaxMillimeters: 3,
+	LongPressDuration: 500 * time.Millisecond,
+	PanFingers:        2,
+	PanMinMillimeters: 5,
+	PinchMinScale:     0.1,
+	RotateMinAngle:    0.2,
+}
+
+// GestureRecognizer is an event filter that recognizes gestures from finger
+// events. Recognized gestures are emitted as EventGesture after the finger
+// event that completed them. Finger events are passed through unchanged.
+//
+// Pinch and rotate are recognized for two fingers and may be active at the
+// same time as pan. Continuous gestures end when the number of fingers
+// changes. Long-presses are recognized when events arrive, call Tick
+// periodically to recognize them while the finger rests without generating
+// events.
+type GestureRecognizer struct {
+	config GestureConfig
+	scale  Coord2D // Millimeters per normalized unit along each axis.
+
+	// State of one touch session, from the first finger touching the pad to
+	// the last one leaving it.
+	active      bool
+	startTime   time.Time
+	starts      []Contact // Initial position of every contact of the session.
+	contacts    []Contact // Current contacts sorted by ID.
+	maxFingers  int
+	maxMovement float32 // Largest movement of any contact in millimeters.
+	recognized  bool    // Some gesture was recognized, no tap.
+
+	// Baseline for continuous gestures, reset when the number of fingers changes.
+	baseFingers int
+	baseCenter  Coord2D
+	baseDist    float32
+	lastAngle   float32 // Angle between the fingers of the previous event.
+	rotation    float32 // Rotation accumulated since the baseline.
+	panLast     Coord2D
+
+	pinch, rotate, pan, longPress bool // Active gestures.
+}
+
+// NewGestureRecognizer creates a gesture recognizer for a device with the given
+// properties. The pad dimensions are read from PropertyPadWidthMillimeters and
+// PropertyPadHeightMillimeters. An error is returned if they are missing.
+func NewGestureRecognizer(props Properties, config GestureConfig) (*GestureRecognizer, error) {
+	scale, ok := padMillimeters(props)
+	if !ok {
+		return nil, fmt.Errorf("gesture recognition requires properties %s and %s", PropertyPadWidthMillimeters, PropertyPadHeightMillimeters)
+	}
+	return &GestureRecognizer{config: config, scale: scale}, nil
+}
+
+func (r *GestureRecognizer) Filter(event Event) []Event {
+	events := []Event{event}
+	switch e := event.(type) {
+	case *EventPositionFinger:
+		contacts := e.Contacts
+		if contacts == nil {
+			// Single touch device.
+			contacts = []Contact{{Coord: e.Coord}}
+		}
+		events = append(events, r.update(e.Timestamp, contacts)...)
+	case *EventButton:
+		if e.Button == ButtonTouch && e.Pressure == 0 {
+			events = append(events, r.update(e.Timestamp, nil)...)
+		}
+	}
+	return events
+}
+
+// Tick recognizes time based gestures. It should be called periodically with
+// a time comparable to event timestamps.
+func (r *GestureRecognizer) Tick(now time.Time) []Event {
+	return r.checkLongPress(now, nil)
+}
+
+func (r *GestureRecognizer) update(timestamp time.Time, contacts []Contact) (events []Event) {
+	events = r.checkLongPress(timestamp, events)
+
+	if len(contacts) == 0 {
+		if r.active {
+			events = r.endSession(timestamp, events)
+		}
+		return
+	}
+
+	r.contacts = append(r.contacts[:0], contacts...)
+	sort.Slice(r.contacts, func(i, j int) bool { return r.contacts[i].ID < r.contacts[j].ID })
+	n := len(r.contacts)
+
+	if !r.active {
+		r.active = true
+		r.startTime = timestamp
+		r.starts = r.starts[:0]
+		r.maxFingers = 0
+		r.maxMovement = 0
+		r.recognized = false
+		r.baseFingers = 0
+	}
+	if n > r.maxFingers {
+		r.maxFingers = n
+	}
+	for _, c := range r.contacts {
+		if start, ok := r.startOf(c.ID); ok {
+			if d := r.distance(start, c.Coord); d > r.maxMovement {
+				r.maxMovement = d
+			}
+		} else {
+			r.starts = append(r.starts, c)
+		}
+	}
+
+	center := r.center()
+	if n != r.baseFingers {
+		events = r.endContinuous(timestamp, events)
+		r.baseFingers = n
+		r.baseCenter = center
+		r.panLast = center
+		if n == 2 {
+			r.baseDist, r.lastAngle = r.twoFingers()
+			r.rotation = 0
+		}
+	}
+
+	if n == 2 && r.baseDist > 0 {
+		dist, angle := r.twoFingers()
+		scale := dist / r.baseDist
+		// The rotation between events is accumulated so that rotations
+		// beyond half a turn keep their direction.
+		r.rotation += normalizeAngle(angle - r.lastAngle)
+		r.lastAngle = angle
+		rotation := r.rotation
+		if r.pinch || float32(math.Abs(float64(scale-1))) >= r.config.PinchMinScale {
+			events = append(events, r.newEvent(timestamp, GesturePinch, &r.pinch, func(e *EventGesture) {
+				e.Scale = scale
+			}))
+		}
+		if r.rotate || float32(math.Abs(float64(rotation))) >= r.config.RotateMinAngle {
+			events = append(events, r.newEvent(timestamp, GestureRotate, &r.rotate, func(e *EventGesture) {
+				e.Angle = rotation
+			}))
+		}
+	}
+
+	if n == r.config.PanFingers {
+		if r.pan || r.distance(r.baseCenter, center) >= r.config.PanMinMillimeters {
+			delta := Coord2D{X: (center.X - r.panLast.X) * r.scale.X, Y: (center.Y - r.panLast.Y) * r.scale.Y}
+			events = append(events, r.newEvent(timestamp, GesturePan, &r.pan, func(e *EventGesture) {
+				e.Delta = delta
+			}))
+			r.panLast = center
+		}
+	}
+
+	if r.longPress {
+		events = append(events, r.newEvent(timestamp, GestureLongPress, &r.longPress, nil))
+	}
+	return
+}
+
+// newEvent creates a gesture event in the began or changed phase depending on
+// if the gesture is active. The gesture is marked as active.
+func (r *GestureRecognizer) newEvent(timestamp time.Time, gesture Gesture, active *bool, set func(e *EventGesture)) *EventGesture {
+	e := &EventGesture{
+		Timestamp: timestamp,
+		Gesture:   gesture,
+		Phase:     GesturePhaseChanged,
+		Fingers:   len(r.contacts),
+		Center:    r.center(),
+		Scale:     1,
+	}
+	if !*active {
+		e.Phase = GesturePhaseBegan
+		*active = true
+		r.recognized = true
+	}
+	if set != nil {
+		set(e)
+	}
+	return e
+}
+
+func (r *GestureRecognizer) checkLongPress(now time.Time, events []Event) []Event {
+	if r.active && !r.recognized && r.maxFingers == 1 && len(r.contacts) == 1 &&
+		r.maxMovement <= r.config.TapMaxMillimeters && now.Sub(r.startTime) >= r.config.LongPressDuration {
+
+		events = append(events, r.newEvent(now, GestureLongPress, &r.longPress, nil))
+	}
+	return events
+}
+
+// endContinuous ends all active gestures that depend on the number of fingers.
+func (r *GestureRecognizer) endContinuous(timestamp time.Time, events []Event) []Event {
+	for _, g := range []struct {
+		gesture Gesture
+		active  *bool
+	}{
+		{GesturePinch, &r.pinch},
+		{GestureRotate, &r.rotate},
+		{GesturePan, &r.pan},
+		{GestureLongPress, &r.longPress},
+	} {
+		if *g.active {
+			*g.active = false
+			events = append(events, &EventGesture{
+				Timestamp: timestamp,
+				Gesture:   g.gesture,
+				Phase:     GesturePhaseEnded,
+				Fingers:   r.baseFingers,
+				Center:    r.center(),
+				Scale:     1,
+			})
+		}
+	}
+	return events
+}
+
+func (r *GestureRecognizer) endSession(timestamp time.Time, events []Event) []Event {
+	events = r.endContinuous(timestamp, events)
+
+	if !r.recognized && timestamp.Sub(r.startTime) <= r.config.TapMaxDuration &&
+		r.maxMovement <= r.config.TapMaxMillimeters {
+
+		var center Coord2D
+		for _, c := range r.starts {
+			center.X += c.Coord.X / float32(len(r.starts))
+			center.Y += c.Coord.Y / float32(len(r.starts))
+		}
+		events = append(events, &EventGesture{
+			Timestamp: timestamp,
+			Gesture:   GestureTap,
+			Phase:     GesturePhaseEnded,
+			Fingers:   r.maxFingers,
+			Center:    center,
+			Scale:     1,
+		})
+	}
+
+	r.active = false
+	r.contacts = r.contacts[:0]
+	return events
+}
+
+func (r *GestureRecognizer) startOf(id int32) (Coord2D, bool) {
+	for _, c := range r.starts {
+		if c.ID == id {
+			return c.Coord, true
+		}
+	}
+	return Coord2D{}, false
+}
+
+// center returns the center of the current contacts.
+func (r *GestureRecognizer) center() (center Coord2D) {
+	for _, c := range r.contacts {
+		center.X += c.Coord.X / float32(len(r.contacts))
+		center.Y += c.Coord.Y / float32(len(r.contacts))
+	}
+	return
+}
+
+// twoFingers returns the distance in millimeters and the angle between the
+// first two contacts.
+func (r *GestureRecognizer) twoFingers() (dist, angle float32) {
+	a, b := r.contacts[0].Coord, r.contacts[1].Coord
+	dx, dy := float64((b.X-a.X)*r.scale.X), float64((b.Y-a.Y)*r.scale.Y)
+	return float32(math.Hypot(dx, dy)), float32(math.Atan2(dy, dx))
+}
+
+// distance returns the distance between two normalized coordinates in millimeters.
+func (r *GestureRecognizer) distance(a, b Coord2D) float32 {
+	return float32(math.Hypot(float64((b.X-a.X)*r.scale.X), float64((b.Y-a.Y)*r.scale.Y)))
+}
+
+// normalizeAngle normalizes angle to the range [-π, π].
+func normalizeAngle(angle float32) float32 {
+	for angle > math.Pi {
+		angle -= 2 * math.Pi
+	}
+	for angle < -math.Pi {
+		angle += 2 * math.Pi
+	}
+	return angle
+}
@@ -0,0 +1,179 @@
+package chimp
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// contactsAt creates a finger event with contacts at the given X and Y
+// coordinate pairs. Contact IDs start at 1.
+func contactsAt(ms int, coords ...float32) *EventPositionFinger {
+	e := &EventPositionFinger{Timestamp: testTime(ms)}
+	for i := 0; i+1 < len(coords); i += 2 {
+		e.Contacts = append(e.Contacts, Contact{ID: int32(i/2 + 1), Coord: Coord2D{X: coords[i], Y: coords[i+1]}})
+	}
+	e.Coord = e.Contacts[0].Coord
+	return e
+}
+
+// gestureSummary describes the gesture events of events.
+func gestureSummary(events []Event) []string {
+	var summary []string
+	for _, event := range events {
+		e, ok := event.(*EventGesture)
+		if !ok {
+			continue
+		}
+		s := fmt.Sprintf("%s %s %s %d center %.2f %.2f", e.Timestamp.Sub(testStart), e.Gesture, e.Phase, e.Fingers, e.Center.X, e.Center.Y)
+		switch e.Gesture {
+		case GesturePinch:
+			s += fmt.Sprintf(" scale %.2f", e.Scale)
+		case GestureRotate:
+			s += fmt.Sprintf(" angle %.2f", e.Angle)
+		case GesturePan:
+			s += fmt.Sprintf(" delta %.2f %.2f", e.Delta.X, e.Delta.Y)
+		}
+		summary = append(summary, s)
+	}
+	return summary
+}
+
+func newTestGestureRecognizer(t *testing.T) *GestureRecognizer {
+	// One normalized unit is 200 mm along X and 100 mm along Y.
+	r, err := NewGestureRecognizer(Properties{
+		PropertyPadWidthMillimeters:  PropertyValueNumber(200),
+		PropertyPadHeightMillimeters: PropertyValueNumber(100),
+	}, DefaultGestureConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestGestureRecognizer(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		events []Event
+		want   []string
+	}{
+		{
+			"tap",
+			[]Event{touchAt(0, 1), contactsAt(0, 0.5, 0.5), contactsAt(50, 0.505, 0.5), touchAt(100, 0)},
+			[]string{"100ms Tap Ended 1 center 0.50 0.50"},
+		},
+		{
+			"two finger tap",
+			[]Event{contactsAt(0, 0.4, 0.5), contactsAt(20, 0.4, 0.5, 0.6, 0.5), touchAt(100, 0)},
+			[]string{"100ms Tap Ended 2 center 0.50 0.50"},
+		},
+		{
+			"touch too long for tap",
+			[]Event{contactsAt(0, 0.5, 0.5), touchAt(300, 0)},
+			nil,
+		},
+		{
+			"finger moved too far for tap",
+			[]Event{contactsAt(0, 0.5, 0.5), contactsAt(50, 0.52, 0.5), touchAt(100, 0)},
+			nil,
+		},
+		{
+			"long-press",
+			[]Event{contactsAt(0, 0.5, 0.5), contactsAt(600, 0.5, 0.5), touchAt(700, 0)},
+			[]string{
+				"600ms LongPress Began 1 center 0.50 0.50",
+				"600ms LongPress Changed 1 center 0.50 0.50",
+				"700ms LongPress Ended 1 center 0.50 0.50",
+			},
+		},
+		{
+			"pinch",
+			[]Event{contactsAt(0, 0.4, 0.5, 0.6, 0.5), contactsAt(50, 0.35, 0.5, 0.65, 0.5), contactsAt(100, 0.3, 0.5, 0.7, 0.5), touchAt(150, 0)},
+			[]string{
+				"50ms Pinch Began 2 center 0.50 0.50 scale 1.50",
+				"100ms Pinch Changed 2 center 0.50 0.50 scale 2.00",
+				"150ms Pinch Ended 2 center 0.50 0.50 scale 1.00",
+			},
+		},
+		{
+			// The fingers are 40 mm apart and rotated 0.5 radians clockwise.
+			"rotate",
+			[]Event{contactsAt(0, 0.4, 0.5, 0.6, 0.5), contactsAt(50, 0.41224, 0.40411, 0.58776, 0.59589), touchAt(100, 0)},
+			[]string{
+				"50ms Rotate Began 2 center 0.50 0.50 angle 0.50",
+				"100ms Rotate Ended 2 center 0.50 0.50 angle 0.00",
+			},
+		},
+		{
+			"pan ended by third finger",
+			[]Event{
+				contactsAt(0, 0.4, 0.5, 0.6, 0.5),
+				contactsAt(50, 0.45, 0.5, 0.65, 0.5),
+				contactsAt(100, 0.5, 0.5, 0.7, 0.5),
+				contactsAt(150, 0.5, 0.5, 0.7, 0.5, 0.6, 0.6),
+				touchAt(200, 0),
+			},
+			[]string{
+				"50ms Pan Began 2 center 0.55 0.50 delta 10.00 0.00",
+				"100ms Pan Changed 2 center 0.60 0.50 delta 10.00 0.00",
+				"150ms Pan Ended 2 center 0.60 0.53 delta 0.00 0.00",
+			},
+		},
+	} {
+		r := newTestGestureRecognizer(t)
+		if got := gestureSummary(filterSequence(r, test.events)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got\n%q\nwant\n%q", test.name, got, test.want)
+		}
+	}
+
+	if _, err := NewGestureRecognizer(Properties{}, DefaultGestureConfig); err == nil {
+		t.Error("no error without pad dimensions")
+	}
+}
+
+// twistAt creates a finger event with two fingers 40 mm apart around the pad
+// center, rotated angle radians clockwise from the X-axis.
+func twistAt(ms int, angle float64) *EventPositionFinger {
+	dx, dy := float32(20*math.Cos(angle)/200), float32(20*math.Sin(angle)/100)
+	return contactsAt(ms, 0.5-dx, 0.5-dy, 0.5+dx, 0.5+dy)
+}
+
+func TestGestureRecognizerRotation(t *testing.T) {
+	// Twist a full turn in steps of a quarter turn and back half a turn.
+	var events []Event
+	for i := 0; i <= 4; i++ {
+		events = append(events, twistAt(i*10, float64(i)*math.Pi/2))
+	}
+	events = append(events, twistAt(50, 3*math.Pi/2), twistAt(60, math.Pi), touchAt(70, 0))
+
+	r := newTestGestureRecognizer(t)
+	want := []string{
+		"10ms Rotate Began 2 center 0.50 0.50 angle 1.57",
+		"20ms Rotate Changed 2 center 0.50 0.50 angle 3.14",
+		"30ms Rotate Changed 2 center 0.50 0.50 angle 4.71",
+		"40ms Rotate Changed 2 center 0.50 0.50 angle 6.28",
+		"50ms Rotate Changed 2 center 0.50 0.50 angle 4.71",
+		"60ms Rotate Changed 2 center 0.50 0.50 angle 3.14",
+		"70ms Rotate Ended 2 center 0.50 0.50 angle 0.00",
+	}
+	if got := gestureSummary(filterSequence(r, events)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestGestureRecognizerTick(t *testing.T) {
+	r := newTestGestureRecognizer(t)
+	filterSequence(r, []Event{contactsAt(0, 0.5, 0.5)})
+	if got := gestureSummary(r.Tick(testTime(400))); got != nil {
+		t.Errorf("long-press before LongPressDuration: %q", got)
+	}
+	want := []string{"500ms LongPress Began 1 center 0.50 0.50"}
+	if got := gestureSummary(r.Tick(testTime(500))); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	want = []string{"600ms LongPress Ended 1 center 0.50 0.50"}
+	if got := gestureSummary(filterSequence(r, []Event{touchAt(600, 0)})); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
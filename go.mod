@@ -1,3 +1,8 @@
 module github.com/johan-bolmsjo/chimp
 
-require github.com/johan-bolmsjo/golang-evdev v1.0.0
+go 1.27.1
+
+require (
+	github.com/johan-bolmsjo/golang-evdev v1.0.0
+	github.com/npat-efault/poller v2.0.0+incompatible
+)
@@ -0,0 +1,89 @@
+package chimp
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// linuxDeviceGroup returns an ID shared by all Linux input devices of one
+// physical device, e.g. the pen, finger and pad devices of a tablet. Devices
+// are grouped by bus, vendor and product IDs together with the first available
+// of:
+//
+//  1. The sysfs path of the physical device.
+//  2. The unique ID of the device, e.g. the Bluetooth address.
+//  3. The physical location without the input number.
+func linuxDeviceGroup(info *linuxDeviceInfo) string {
+	id := &info.identity
+	group := fmt.Sprintf("%04x:%04x:%04x ", uint16(id.BusType), id.Vendor, id.Product)
+	if path := sysfsPhysicalDevice(info.dev); path != "" {
+		return group + "sysfs:" + path
+	}
+	if id.Uniq != "" {
+		return group + "uniq:" + id.Uniq
+	}
+	return group + "phys:" + physGroup(info.phys)
+}
+
+var (
+	reSysfsHIDDevice    = regexp.MustCompile(`^[0-9A-F]{4}:[0-9A-F]{4}:[0-9A-F]{4}\.[0-9A-F]{4}$`)
+	reSysfsUSBInterface = regexp.MustCompile(`^\d+-[\d.]+:\d+\.\d+$`)
+	rePhysInputNumber   = regexp.MustCompile(`/input\d+$`)
+)
+
+// Root of all devices in sysfs.
+var sysfsDevicesRoot = "/sys/devices"
+
+// sysfsPhysicalDevice returns the sysfs path, relative to the devices root, of
+// the physical device that an input device node such as /dev/input/event3
+// belongs to. Devices that are not backed by hardware and devices not found in
+// sysfs return an empty string.
+//
+// Input devices of one physical device are usually created by separate HID
+// devices or USB interfaces, which are skipped:
+//
+//	USB:       pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0/0003:056A:00DF.0001/input/input5 -> pci0000:00/0000:00:14.0/usb1/1-2
+//	Bluetooth: pci0000:00/0000:00:14.0/usb1/1-10/1-10:1.0/bluetooth/hci0/hci0:256/0005:056A:0377.0004/input/input20 -> .../hci0/hci0:256
+//	I2C:       pci0000:00/0000:00:15.1/i2c_designware.1/i2c-2/i2c-WACF2200:00/0018:056A:5146.0001/input/input12 -> .../i2c-2/i2c-WACF2200:00
+func sysfsPhysicalDevice(devnode string) string {
+	path, err := filepath.EvalSymlinks(filepath.Join(sysfsInputRoot, filepath.Base(devnode), "device"))
+	if err != nil {
+		return ""
+	}
+
+	dir := filepath.Dir(path)
+	if filepath.Base(dir) == "input" {
+		dir = filepath.Dir(dir)
+	}
+	if reSysfsHIDDevice.MatchString(filepath.Base(dir)) {
+		dir = filepath.Dir(dir)
+	}
+	if reSysfsUSBInterface.MatchString(filepath.Base(dir)) {
+		dir = filepath.Dir(dir)
+	}
+
+	rel, err := filepath.Rel(sysfsDevicesRoot, dir)
+	if err != nil || strings.HasPrefix(rel, "..") || rel == "virtual" || strings.HasPrefix(rel, "virtual/") {
+		return ""
+	}
+	return rel
+}
+
+// physGroup returns the part of a physical location that is shared by all
+// input devices of one physical device. Physical locations seen in the wild:
+//
+//	USB:         usb-0000:00:14.0-2/input0            -> usb-0000:00:14.0-2
+//	USB hub:     usb-0000:00:14.0-3.4.1/input1        -> usb-0000:00:14.0-3.4.1
+//	Bluetooth:   e4:a4:71:12:34:56                    -> e4:a4:71:12:34:56 (address of adapter, not device)
+//	I2C:         i2c-WACF2200:00                      -> i2c-WACF2200:00
+//	Serial:      ttyS0/serio0/input0                  -> ttyS0/serio0
+//	Virtual:     (empty)                              -> (empty)
+//
+// Bluetooth devices report the address of the adapter, so devices of the same
+// model connected through the same adapter can only be told apart by their
+// unique ID.
+func physGroup(phys string) string {
+	return rePhysInputNumber.ReplaceAllString(phys, "")
+}
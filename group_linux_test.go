@@ -0,0 +1,138 @@
+package chimp
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useSysfsFixture builds the sysfs tree described by testdata/sysfs-input.txt
+// and points the sysfs roots at it until the test ends.
+func useSysfsFixture(t *testing.T) {
+	f, err := os.Open("testdata/sysfs-input.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	root := t.TempDir()
+	devicesRoot := filepath.Join(root, "devices")
+	inputRoot := filepath.Join(root, "class", "input")
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			t.Fatalf("malformed fixture line %q", scanner.Text())
+		}
+		device := filepath.Join(devicesRoot, fields[1])
+		node := filepath.Join(inputRoot, fields[0])
+		for _, dir := range []string{device, node} {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Symlink(device, filepath.Join(node, "device")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The roots are compared with resolved paths.
+	if devicesRoot, err = filepath.EvalSymlinks(devicesRoot); err != nil {
+		t.Fatal(err)
+	}
+	savedDevicesRoot, savedInputRoot := sysfsDevicesRoot, sysfsInputRoot
+	sysfsDevicesRoot, sysfsInputRoot = devicesRoot, inputRoot
+	t.Cleanup(func() {
+		sysfsDevicesRoot, sysfsInputRoot = savedDevicesRoot, savedInputRoot
+	})
+}
+
+func TestPhysGroup(t *testing.T) {
+	for _, test := range []struct {
+		name, phys, group string
+	}{
+		{"USB", "usb-0000:00:14.0-2/input0", "usb-0000:00:14.0-2"},
+		{"USB other interface", "usb-0000:00:14.0-2/input1", "usb-0000:00:14.0-2"},
+		{"USB hub", "usb-0000:00:14.0-3.4.1/input1", "usb-0000:00:14.0-3.4.1"},
+		{"Bluetooth", "e4:a4:71:12:34:56", "e4:a4:71:12:34:56"},
+		{"I2C", "i2c-WACF2200:00", "i2c-WACF2200:00"},
+		{"Serial", "ttyS0/serio0/input0", "ttyS0/serio0"},
+		{"Virtual", "", ""},
+	} {
+		if group := physGroup(test.phys); group != test.group {
+			t.Errorf("%s: physGroup(%q) = %q, want %q", test.name, test.phys, group, test.group)
+		}
+	}
+}
+
+func TestSysfsPhysicalDevice(t *testing.T) {
+	useSysfsFixture(t)
+	for _, test := range []struct {
+		name, devnode, path string
+	}{
+		{"USB pen", "/dev/input/event5", "pci0000:00/0000:00:14.0/usb1/1-2"},
+		{"USB pad", "/dev/input/event6", "pci0000:00/0000:00:14.0/usb1/1-2"},
+		{"USB finger", "/dev/input/event7", "pci0000:00/0000:00:14.0/usb1/1-2"},
+		{"USB hub", "/dev/input/event10", "pci0000:00/0000:00:14.0/usb1/1-3/1-3.4/1-3.4.1"},
+		{"Bluetooth", "/dev/input/event20", "pci0000:00/0000:00:14.0/usb1/1-10/1-10:1.0/bluetooth/hci0/hci0:256"},
+		{"I2C", "/dev/input/event12", "pci0000:00/0000:00:15.1/i2c_designware.1/i2c-2/i2c-WACF2200:00"},
+		{"Virtual", "/dev/input/event30", ""},
+		{"Missing", "/dev/input/event99", ""},
+	} {
+		if path := sysfsPhysicalDevice(test.devnode); path != test.path {
+			t.Errorf("%s: sysfsPhysicalDevice(%q) = %q, want %q", test.name, test.devnode, path, test.path)
+		}
+	}
+}
+
+func TestLinuxDeviceGroup(t *testing.T) {
+	useSysfsFixture(t)
+	bamboo := DeviceIdentity{BusType: BusTypeUSB, Vendor: 0x056a, Product: 0x00df}
+	for _, test := range []struct {
+		name  string
+		info  linuxDeviceInfo
+		group string
+	}{
+		{
+			"sysfs before uniq",
+			linuxDeviceInfo{dev: "/dev/input/event7", phys: "usb-0000:00:14.0-2/input1", identity: DeviceIdentity{BusType: BusTypeUSB, Vendor: 0x056a, Product: 0x00df, Uniq: "1234"}},
+			"0003:056a:00df sysfs:pci0000:00/0000:00:14.0/usb1/1-2",
+		},
+		{
+			"uniq before phys",
+			linuxDeviceInfo{dev: "/dev/input/event99", phys: "e4:a4:71:12:34:56", identity: DeviceIdentity{BusType: BusTypeBluetooth, Vendor: 0x056a, Product: 0x0377, Uniq: "00:11:22:33:44:55"}},
+			"0005:056a:0377 uniq:00:11:22:33:44:55",
+		},
+		{
+			"phys",
+			linuxDeviceInfo{dev: "/dev/input/event99", phys: "usb-0000:00:14.0-2/input1", identity: bamboo},
+			"0003:056a:00df phys:usb-0000:00:14.0-2",
+		},
+		{
+			"virtual uses phys",
+			linuxDeviceInfo{dev: "/dev/input/event30", phys: "", identity: bamboo},
+			"0003:056a:00df phys:",
+		},
+	} {
+		if group := linuxDeviceGroup(&test.info); group != test.group {
+			t.Errorf("%s: linuxDeviceGroup = %q, want %q", test.name, group, test.group)
+		}
+	}
+
+	// All input devices of the Bamboo share one group.
+	var groups []string
+	for _, devnode := range []string{"/dev/input/event5", "/dev/input/event6", "/dev/input/event7"} {
+		groups = append(groups, linuxDeviceGroup(&linuxDeviceInfo{dev: devnode, identity: bamboo}))
+	}
+	if groups[0] != groups[1] || groups[0] != groups[2] {
+		t.Errorf("Bamboo input devices in different groups: %q", groups)
+	}
+}
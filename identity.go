@@ -0,0 +1,89 @@
+package chimp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DeviceIdentity identifies a device model and, if the device reports a unique
+// ID, the individual device.
+type DeviceIdentity struct {
+	BusType BusType // Bus the device is connected through.
+	Vendor  uint16  // Vendor ID.
+	Product uint16  // Product ID.
+	Version uint16  // Product version.
+	Uniq    string  // Unique ID such as a serial number, empty if not reported by device.
+}
+
+// StableID returns an identifier that remains the same when the device is
+// replugged or moved to another port. It's formed from the bus type, vendor
+// ID, product ID and unique ID. Devices of the same model without a unique ID
+// share the same identifier. The identifier is safe to use as a file name.
+func (id *DeviceIdentity) StableID() string {
+	s := fmt.Sprintf("%04x:%04x:%04x", uint16(id.BusType), id.Vendor, id.Product)
+	if id.Uniq != "" {
+		s += "-" + strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == ':' || r == '-' {
+				return r
+			}
+			return '_'
+		}, id.Uniq)
+	}
+	return s
+}
+
+func (id *DeviceIdentity) String() string {
+	return fmt.Sprintf("{Bus: %s, Vendor: %04x, Product: %04x, Version: %04x, Uniq: %q}",
+		id.BusType, id.Vendor, id.Product, id.Version, id.Uniq)
+}
+
+// properties returns a copy of props with the identity added.
+func (id *DeviceIdentity) properties(props Properties) Properties {
+	p := Properties{}
+	for k, v := range props {
+		p[k] = v
+	}
+	p[PropertyBusType] = PropertyValueString(id.BusType.String())
+	p[PropertyVendorID] = PropertyValueNumber(id.Vendor)
+	p[PropertyProductID] = PropertyValueNumber(id.Product)
+	p[PropertyVersion] = PropertyValueNumber(id.Version)
+	if id.Uniq != "" {
+		p[PropertyUniq] = PropertyValueString(id.Uniq)
+	}
+	p[PropertyStableID] = PropertyValueString(id.StableID())
+	return p
+}
+
+// BusType is an enumeration of buses that devices are connected through. The
+// values are the Linux BUS_* constants.
+type BusType uint16
+
+const (
+	BusTypePCI       BusType = 0x01
+	BusTypeUSB       BusType = 0x03
+	BusTypeBluetooth BusType = 0x05
+	BusTypeVirtual   BusType = 0x06
+	BusTypeI2C       BusType = 0x18
+	BusTypeHost      BusType = 0x19
+	BusTypeSPI       BusType = 0x1c
+)
+
+func (bus BusType) String() string {
+	switch bus {
+	case BusTypePCI:
+		return "PCI"
+	case BusTypeUSB:
+		return "USB"
+	case BusTypeBluetooth:
+		return "Bluetooth"
+	case BusTypeVirtual:
+		return "Virtual"
+	case BusTypeI2C:
+		return "I2C"
+	case BusTypeHost:
+		return "Host"
+	case BusTypeSPI:
+		return "SPI"
+	}
+	return fmt.Sprintf("BusType(0x%02x)", uint16(bus))
+}
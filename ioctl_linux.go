@@ -0,0 +1,52 @@
+package chimp
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// Linux ioctl request encoding from asm-generic/ioctl.h. Requests not exposed
+// by the evdev package are issued directly.
+const (
+	iocWrite = 1
+	iocRead  = 2
+
+	iocNRShift   = 0
+	iocTypeShift = 8
+	iocSizeShift = 16
+	iocDirShift  = 30
+)
+
+func ioc(dir, typ, nr, size uintptr) uintptr {
+	return dir<<iocDirShift | typ<<iocTypeShift | nr<<iocNRShift | size<<iocSizeShift
+}
+
+// Set clock used for event timestamps.
+var eviocSClockID = ioc(iocWrite, 'E', 0xa0, 4)
+
+// Get unique ID of device into buffer of size bytes.
+func eviocGUniq(size int) uintptr {
+	return ioc(iocRead, 'E', 0x08, uintptr(size))
+}
+
+func ioctl(fd int, request uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Get absolute axis information of axis abs, struct input_absinfo.
+func eviocGAbs(abs uint16) uintptr {
+	return ioc(iocRead, 'E', 0x40+uintptr(abs), unsafe.Sizeof(inputAbsinfo{}))
+}
+
+// Get device properties (INPUT_PROP_*) into bit buffer of size bytes.
+func eviocGProp(size int) uintptr {
+	return ioc(iocRead, 'E', 0x09, uintptr(size))
+}
+
+// Mirror of struct input_absinfo.
+type inputAbsinfo struct {
+	value, minimum, maximum, fuzz, flat, resolution int32
+}
@@ -0,0 +1,97 @@
+package chimp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// keyFile is a parsed file of the INI like format used by device descriptors
+// and libwacom. Lines are either blank, comments starting with '#', section
+// headers such as "[Device]" or "Key=Value" entries. Lists are separated by
+// ';'.
+type keyFile struct {
+	sections []*keyFileSection
+}
+
+type keyFileSection struct {
+	name    string
+	line    int
+	entries []keyFileEntry
+}
+
+type keyFileEntry struct {
+	key, value string
+	line       int
+}
+
+func parseKeyFile(r io.Reader) (*keyFile, error) {
+	kf := &keyFile{}
+	var section *keyFileSection
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		s := strings.TrimSpace(scanner.Text())
+		switch {
+		case s == "" || s[0] == '#':
+		case s[0] == '[':
+			if s[len(s)-1] != ']' {
+				return nil, fmt.Errorf("line %d: malformed section header %q", line, s)
+			}
+			section = &keyFileSection{name: strings.TrimSpace(s[1 : len(s)-1]), line: line}
+			kf.sections = append(kf.sections, section)
+		default:
+			i := strings.IndexByte(s, '=')
+			if i < 0 {
+				return nil, fmt.Errorf("line %d: expected key=value, got %q", line, s)
+			}
+			if section == nil {
+				return nil, fmt.Errorf("line %d: entry outside of section", line)
+			}
+			section.entries = append(section.entries, keyFileEntry{
+				key:   strings.TrimSpace(s[:i]),
+				value: strings.TrimSpace(s[i+1:]),
+				line:  line,
+			})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return kf, nil
+}
+
+// section returns the first section with name or nil if there is none.
+func (kf *keyFile) section(name string) *keyFileSection {
+	for _, v := range kf.sections {
+		if v.name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// value returns the value of the last entry with key.
+func (section *keyFileSection) value(key string) (string, bool) {
+	if section == nil {
+		return "", false
+	}
+	for i := len(section.entries) - 1; i >= 0; i-- {
+		if section.entries[i].key == key {
+			return section.entries[i].value, true
+		}
+	}
+	return "", false
+}
+
+// keyFileList splits a list value. Empty items are removed.
+func keyFileList(value string) []string {
+	var list []string
+	for _, v := range strings.Split(value, ";") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,131 @@
+package chimp
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Kinematics of a position event. The values are only set when events are
+// passed through a KinematicsFilter.
+type Kinematics struct {
+	Valid        bool    // Kinematics has been computed for the event.
+	Velocity     Coord2D // Velocity in millimeters per second.
+	Speed        float32 // Magnitude of velocity in millimeters per second.
+	Acceleration Coord2D // Acceleration in millimeters per second squared.
+	Heading      float32 // Direction of movement in radians, 0 along the X-axis and π/2 along the Y-axis.
+}
+
+func (k *Kinematics) String() string {
+	return fmt.Sprintf("{V: %s, Speed: %f, A: %s, Heading: %f}", &k.Velocity, k.Speed, &k.Acceleration, k.Heading)
+}
+
+// DefaultKinematicsMaxGap is the default time between position events after
+// which movement is considered to have been interrupted.
+const DefaultKinematicsMaxGap = 100 * time.Millisecond
+
+// KinematicsFilter is an event filter that annotates pen and finger position
+// events with velocity, acceleration and heading in physical units.
+//
+// Kinematics is computed from the timestamps of consecutive position events of
+// the same position device. Device timestamps are used when available,
+// movement is restarted when they become available or unavailable.
+// Position events with the same timestamp as the previous one reuse its
+// kinematics. Movement is restarted from rest when the pen enters or leaves
+// proximity, when the finger leaves the pad and after a gap longer than
+// MaxGap. Acceleration is zero until two velocities have been measured since
+// the device was at rest.
+//
+// Annotated events are copies of the events passed to Filter, which are not
+// modified. The copies share history and contacts with the original events.
+type KinematicsFilter struct {
+	MaxGap time.Duration // Maximum time between position events of one movement.
+
+	// Millimeters per normalized unit along each axis.
+	scale Coord2D
+
+	pen, finger kinematicsState
+}
+
+type kinematicsState struct {
+	valid      bool
+	timestamp  time.Time
+	deviceTime bool    // Timestamp is device time.
+	coord      Coord2D // Last position in millimeters.
+	kinematics Kinematics
+	moving     bool // Velocity measured since the device was at rest.
+}
+
+// NewKinematicsFilter creates a kinematics filter for a device with the given
+// properties. The pad dimensions are read from PropertyPadWidthMillimeters and
+// PropertyPadHeightMillimeters. An error is returned if they are missing.
+func NewKinematicsFilter(props Properties) (*KinematicsFilter, error) {
+	scale, ok := padMillimeters(props)
+	if !ok {
+		return nil, fmt.Errorf("kinematics requires properties %s and %s", PropertyPadWidthMillimeters, PropertyPadHeightMillimeters)
+	}
+	return &KinematicsFilter{
+		MaxGap: DefaultKinematicsMaxGap,
+		scale:  scale,
+	}, nil
+}
+
+func (f *KinematicsFilter) Filter(event Event) []Event {
+	switch e := event.(type) {
+	case *EventPositionPen:
+		c := *e
+		timestamp, deviceTime := motionTime(e.Timestamp, e.DeviceTimestamp, e.HasDeviceTimestamp)
+		c.Kinematics = f.pen.update(timestamp, deviceTime, e.Coord, f.scale, f.MaxGap)
+		return []Event{&c}
+	case *EventPositionFinger:
+		c := *e
+		timestamp, deviceTime := motionTime(e.Timestamp, e.DeviceTimestamp, e.HasDeviceTimestamp)
+		c.Kinematics = f.finger.update(timestamp, deviceTime, e.Coord, f.scale, f.MaxGap)
+		return []Event{&c}
+	case *EventProximityPen:
+		f.pen.valid = false
+	case *EventButton:
+		if e.Button == ButtonTouch && e.Pressure == 0 {
+			f.finger.valid = false
+		}
+	}
+	return []Event{event}
+}
+
+func (state *kinematicsState) update(timestamp time.Time, deviceTime bool, coord, scale Coord2D, maxGap time.Duration) Kinematics {
+	coord = Coord2D{X: coord.X * scale.X, Y: coord.Y * scale.Y}
+
+	dt := timestamp.Sub(state.timestamp)
+	switch {
+	case !state.valid || deviceTime != state.deviceTime || dt > maxGap || dt < 0:
+		// Start of movement, at rest until the next event.
+		state.kinematics = Kinematics{Valid: true}
+		state.moving = false
+	case dt == 0:
+		// Duplicate timestamp, keep kinematics but track latest position.
+	default:
+		sec := float32(dt.Seconds())
+		prev := state.kinematics
+		k := Kinematics{Valid: true}
+		k.Velocity = Coord2D{X: (coord.X - state.coord.X) / sec, Y: (coord.Y - state.coord.Y) / sec}
+		k.Speed = float32(math.Hypot(float64(k.Velocity.X), float64(k.Velocity.Y)))
+		if state.moving {
+			// Starting from rest the acceleration is unknown, comparing with
+			// the rest velocity would make it depend on the event rate.
+			k.Acceleration = Coord2D{X: (k.Velocity.X - prev.Velocity.X) / sec, Y: (k.Velocity.Y - prev.Velocity.Y) / sec}
+		}
+		state.moving = k.Speed > 0
+		if k.Speed > 0 {
+			k.Heading = float32(math.Atan2(float64(k.Velocity.Y), float64(k.Velocity.X)))
+		} else {
+			k.Heading = prev.Heading
+		}
+		state.kinematics = k
+	}
+
+	state.valid = true
+	state.timestamp = timestamp
+	state.deviceTime = deviceTime
+	state.coord = coord
+	return state.kinematics
+}
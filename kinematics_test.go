@@ -0,0 +1,147 @@
+package chimp
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func fingerAt(ms int, x, y float32) *EventPositionFinger {
+	return &EventPositionFinger{Timestamp: testTime(ms), Coord: Coord2D{X: x, Y: y}}
+}
+
+func touchAt(ms int, pressure float32) *EventButton {
+	return &EventButton{Timestamp: testTime(ms), Button: ButtonTouch, Pressure: pressure}
+}
+
+// kinematicsSummary describes the kinematics of position events.
+func kinematicsSummary(events []Event) []string {
+	var summary []string
+	for _, event := range events {
+		var k *Kinematics
+		var device string
+		switch e := event.(type) {
+		case *EventPositionPen:
+			k, device = &e.Kinematics, "pen"
+		case *EventPositionFinger:
+			k, device = &e.Kinematics, "finger"
+		default:
+			continue
+		}
+		if !k.Valid {
+			summary = append(summary, fmt.Sprintf("%s %s invalid", event.Time().Sub(testStart), device))
+			continue
+		}
+		summary = append(summary, fmt.Sprintf("%s %s v %.0f %.0f speed %.0f a %.0f %.0f heading %.2f",
+			event.Time().Sub(testStart), device, k.Velocity.X, k.Velocity.Y, k.Speed, k.Acceleration.X, k.Acceleration.Y, k.Heading))
+	}
+	return summary
+}
+
+func TestKinematicsFilter(t *testing.T) {
+	// One normalized unit is 200 mm along X and 100 mm along Y.
+	props := Properties{
+		PropertyPadWidthMillimeters:  PropertyValueNumber(200),
+		PropertyPadHeightMillimeters: PropertyValueNumber(100),
+	}
+
+	for _, test := range []struct {
+		name   string
+		events []Event
+		want   []string
+	}{
+		{
+			"pen movement",
+			[]Event{
+				penAt(0, 0.5, 0.5), penAt(10, 0.55, 0.5), penAt(10, 0.56, 0.5), penAt(20, 0.56, 0.55), penAt(30, 0.56, 0.55),
+				penAt(40, 0.56, 0.55), penAt(50, 0.57, 0.55), penAt(60, 0.59, 0.55),
+			},
+			[]string{
+				"0s pen v 0 0 speed 0 a 0 0 heading 0.00",
+				"10ms pen v 1000 0 speed 1000 a 0 0 heading 0.00",
+				"10ms pen v 1000 0 speed 1000 a 0 0 heading 0.00",
+				"20ms pen v 0 500 speed 500 a -100000 50000 heading 1.57",
+				"30ms pen v 0 0 speed 0 a 0 -50000 heading 1.57",
+				"40ms pen v 0 0 speed 0 a 0 0 heading 1.57",
+				"50ms pen v 200 0 speed 200 a 0 0 heading 0.00",
+				"60ms pen v 400 0 speed 400 a 20000 0 heading 0.00",
+			},
+		},
+		{
+			"pen gap",
+			[]Event{penAt(0, 0.5, 0.5), penAt(10, 0.55, 0.5), penAt(200, 0.6, 0.5), penAt(210, 0.65, 0.5)},
+			[]string{
+				"0s pen v 0 0 speed 0 a 0 0 heading 0.00",
+				"10ms pen v 1000 0 speed 1000 a 0 0 heading 0.00",
+				"200ms pen v 0 0 speed 0 a 0 0 heading 0.00",
+				"210ms pen v 1000 0 speed 1000 a 0 0 heading 0.00",
+			},
+		},
+		{
+			"pen proximity",
+			[]Event{penAt(0, 0.5, 0.5), penAt(10, 0.55, 0.5), proximityAt(20, false), proximityAt(30, true), penAt(30, 0.1, 0.1)},
+			[]string{
+				"0s pen v 0 0 speed 0 a 0 0 heading 0.00",
+				"10ms pen v 1000 0 speed 1000 a 0 0 heading 0.00",
+				"30ms pen v 0 0 speed 0 a 0 0 heading 0.00",
+			},
+		},
+		{
+			"finger lifted",
+			[]Event{
+				touchAt(0, 1), fingerAt(0, 0.5, 0.5), fingerAt(10, 0.5, 0.45),
+				touchAt(20, 0), touchAt(40, 1), fingerAt(40, 0.1, 0.1), penAt(50, 0.5, 0.5),
+			},
+			[]string{
+				"0s finger v 0 0 speed 0 a 0 0 heading 0.00",
+				"10ms finger v 0 -500 speed 500 a 0 0 heading -1.57",
+				"40ms finger v 0 0 speed 0 a 0 0 heading 0.00",
+				"50ms pen v 0 0 speed 0 a 0 0 heading 0.00",
+			},
+		},
+	} {
+		f, err := NewKinematicsFilter(props)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := kinematicsSummary(filterSequence(f, test.events)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got\n%q\nwant\n%q", test.name, got, test.want)
+		}
+	}
+
+	if _, err := NewKinematicsFilter(Properties{}); err == nil {
+		t.Error("no error without pad dimensions")
+	}
+}
+
+func TestKinematicsFilterDeviceTimestamp(t *testing.T) {
+	f, err := NewKinematicsFilter(Properties{
+		PropertyPadWidthMillimeters:  PropertyValueNumber(200),
+		PropertyPadHeightMillimeters: PropertyValueNumber(100),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first events were delivered in one batch, the device timestamps
+	// give the actual interval. A device timestamp of zero is a reported time.
+	// Movement is restarted when device timestamps are no longer reported,
+	// device time and event time are not comparable.
+	a, b := penAt(0, 0.5, 0.5), penAt(0, 0.55, 0.5)
+	a.HasDeviceTimestamp = true
+	b.DeviceTimestamp, b.HasDeviceTimestamp = 20*time.Millisecond, true
+	events := []Event{a, b, penAt(30, 0.6, 0.5), penAt(40, 0.65, 0.5)}
+	want := []string{
+		"0s pen v 0 0 speed 0 a 0 0 heading 0.00",
+		"0s pen v 500 0 speed 500 a 0 0 heading 0.00",
+		"30ms pen v 0 0 speed 0 a 0 0 heading 0.00",
+		"40ms pen v 1000 0 speed 1000 a 0 0 heading 0.00",
+	}
+	if got := kinematicsSummary(filterSequence(f, events)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+	if a.Kinematics.Valid || b.Kinematics.Valid {
+		t.Error("events passed to filter modified")
+	}
+}
@@ -0,0 +1,182 @@
+package chimp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EventLatency returns the time from when event was generated until it was
+// read from the device. False is returned for events without a delivery time.
+func EventLatency(event Event) (time.Duration, bool) {
+	delivered := eventDeliveryTime(event)
+	if delivered.IsZero() {
+		return 0, false
+	}
+	return delivered.Sub(event.Time()), true
+}
+
+// eventDeliveryTime returns the time when event was read from the device, or
+// zero for events without a delivery time.
+func eventDeliveryTime(event Event) (delivered time.Time) {
+	switch e := event.(type) {
+	case *EventPositionPen:
+		delivered = e.Delivered
+	case *EventPositionFinger:
+		delivered = e.Delivered
+	case *EventButton:
+		delivered = e.Delivered
+	case *EventMotionPen:
+		delivered = e.Delivered
+	case *EventAction:
+		delivered = e.Delivered
+	case *EventPadRing:
+		delivered = e.Delivered
+	case *EventPadStrip:
+		delivered = e.Delivered
+	case *EventPadMode:
+		delivered = e.Delivered
+	case *EventButtonGesture:
+		delivered = e.Delivered
+	case *EventProximityPen:
+		delivered = e.Delivered
+	}
+	return
+}
+
+// setEventDeliveryTime records the time when event was read from the device.
+func setEventDeliveryTime(event Event, now time.Time) {
+	switch e := event.(type) {
+	case *EventPositionPen:
+		e.Delivered = now
+	case *EventPositionFinger:
+		e.Delivered = now
+	case *EventButton:
+		e.Delivered = now
+	case *EventMotionPen:
+		e.Delivered = now
+	case *EventAction:
+		e.Delivered = now
+	case *EventPadRing:
+		e.Delivered = now
+	case *EventPadStrip:
+		e.Delivered = now
+	case *EventPadMode:
+		e.Delivered = now
+	case *EventButtonGesture:
+		e.Delivered = now
+	case *EventProximityPen:
+		e.Delivered = now
+	}
+}
+
+// LatencyHistogram counts latencies in buckets of exponentially increasing
+// size. Latencies measured by the application, such as input to photon, can be
+// added by subtracting the event timestamp from the current time of the device
+// clock.
+//
+//	hist.Add(dev.Clock().Now().Sub(event.Time()))
+type LatencyHistogram struct {
+	counts [latencyBuckets]uint64
+	stats  LatencyStats
+}
+
+// LatencyBucket is a bucket of a latency histogram.
+type LatencyBucket struct {
+	Max   time.Duration // Largest latency in bucket, the last bucket also holds all larger latencies.
+	Count uint64        // Number of latencies in bucket.
+}
+
+// The first bucket holds latencies up to latencyBucketBase, every following
+// bucket twice the range of the previous one.
+const (
+	latencyBuckets    = 16
+	latencyBucketBase = 50 * time.Microsecond
+)
+
+func latencyBucketMax(i int) time.Duration {
+	return latencyBucketBase << uint(i)
+}
+
+// Add adds a latency to the histogram. Negative latencies are counted as zero.
+func (hist *LatencyHistogram) Add(latency time.Duration) {
+	if latency < 0 {
+		latency = 0
+	}
+	i := 0
+	for i < latencyBuckets-1 && latency > latencyBucketMax(i) {
+		i++
+	}
+	hist.counts[i]++
+	hist.stats.add(latency)
+}
+
+// AddEvent adds the latency of event from generation until it was read from the
+// device. False is returned if the event has no delivery time.
+func (hist *LatencyHistogram) AddEvent(event Event) bool {
+	latency, ok := EventLatency(event)
+	if ok {
+		hist.Add(latency)
+	}
+	return ok
+}
+
+// Stats returns statistics of all added latencies.
+func (hist *LatencyHistogram) Stats() LatencyStats {
+	return hist.stats
+}
+
+// Buckets returns the buckets of the histogram in order of increasing latency.
+func (hist *LatencyHistogram) Buckets() []LatencyBucket {
+	buckets := make([]LatencyBucket, latencyBuckets)
+	for i := range buckets {
+		buckets[i] = LatencyBucket{Max: latencyBucketMax(i), Count: hist.counts[i]}
+	}
+	return buckets
+}
+
+// Percentile returns an estimate of the latency that p percent of all added
+// latencies are below. The estimate is interpolated within the bucket holding
+// the percentile.
+func (hist *LatencyHistogram) Percentile(p float64) time.Duration {
+	if hist.stats.Count == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(hist.stats.Count)
+	var seen float64
+	var lo time.Duration
+	for i, count := range hist.counts {
+		hi := latencyBucketMax(i)
+		if hi > hist.stats.Max || i == latencyBuckets-1 {
+			hi = hist.stats.Max
+		}
+		if count > 0 && seen+float64(count) >= rank {
+			f := (rank - seen) / float64(count)
+			if f < 0 {
+				f = 0
+			}
+			return lo + time.Duration(f*float64(hi-lo))
+		}
+		seen += float64(count)
+		lo = hi
+	}
+	return hist.stats.Max
+}
+
+// Reset removes all added latencies.
+func (hist *LatencyHistogram) Reset() {
+	*hist = LatencyHistogram{}
+}
+
+func (hist *LatencyHistogram) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "LatencyHistogram: {\n    Count: %d\n    Mean:  %s\n    P50:   %s\n    P99:   %s\n    Max:   %s\n",
+		hist.stats.Count, hist.stats.Mean(), hist.Percentile(50), hist.Percentile(99), hist.stats.Max)
+	for _, b := range hist.Buckets() {
+		if b.Count > 0 {
+			fmt.Fprintf(&sb, "    <= %-10s %d\n", b.Max, b.Count)
+		}
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
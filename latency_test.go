@@ -0,0 +1,90 @@
+package chimp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogramBuckets(t *testing.T) {
+	const us = time.Microsecond
+	for _, test := range []struct {
+		latency time.Duration
+		bucket  int
+	}{
+		{-us, 0},
+		{0, 0},
+		{50 * us, 0},
+		{50*us + 1, 1},
+		{100 * us, 1},
+		{100*us + 1, 2},
+		{200 * us, 2},
+		{latencyBucketBase << 14, 14},
+		{latencyBucketBase<<14 + 1, 15},
+		{latencyBucketBase << 15, 15},
+		{time.Hour, 15},
+	} {
+		var hist LatencyHistogram
+		hist.Add(test.latency)
+		for i, b := range hist.Buckets() {
+			want := uint64(0)
+			if i == test.bucket {
+				want = 1
+			}
+			if b.Count != want {
+				t.Errorf("latency %s: bucket %d <= %s has count %d, want %d", test.latency, i, b.Max, b.Count, want)
+			}
+		}
+	}
+}
+
+func TestLatencyHistogramStats(t *testing.T) {
+	var hist LatencyHistogram
+	if p := hist.Percentile(50); p != 0 {
+		t.Errorf("percentile of empty histogram %s", p)
+	}
+
+	// 100 latencies of 1 ms to 100 ms.
+	for i := 1; i <= 100; i++ {
+		hist.Add(time.Duration(i) * time.Millisecond)
+	}
+	stats := hist.Stats()
+	if stats.Count != 100 || stats.Max != 100*time.Millisecond || stats.Last != 100*time.Millisecond || stats.Mean() != 50500*time.Microsecond {
+		t.Errorf("stats %+v, mean %s", stats, stats.Mean())
+	}
+
+	// Percentiles are interpolated within their bucket, the last bucket
+	// holding a latency ends at the largest latency.
+	for _, test := range []struct {
+		p      float64
+		lo, hi time.Duration
+	}{
+		{0, 0, 1600 * time.Microsecond},
+		{50, 25600 * time.Microsecond, 51200 * time.Microsecond},
+		{99, 51200 * time.Microsecond, 100 * time.Millisecond},
+		{100, 100 * time.Millisecond, 100 * time.Millisecond},
+	} {
+		if got := hist.Percentile(test.p); got < test.lo || got > test.hi {
+			t.Errorf("P%g = %s, want in [%s, %s]", test.p, got, test.lo, test.hi)
+		}
+	}
+
+	hist.Reset()
+	if stats := hist.Stats(); stats.Count != 0 {
+		t.Errorf("stats %+v after reset", stats)
+	}
+}
+
+func TestEventLatency(t *testing.T) {
+	event := &EventButton{Timestamp: testTime(0)}
+	var hist LatencyHistogram
+	if _, ok := EventLatency(event); ok || hist.AddEvent(event) {
+		t.Error("latency of event without delivery time")
+	}
+	setEventDeliveryTime(event, testTime(3))
+	if latency, ok := EventLatency(event); !ok || latency != 3*time.Millisecond {
+		t.Errorf("latency %s, %t", latency, ok)
+	}
+	if !hist.AddEvent(event) || hist.Stats().Count != 1 {
+		t.Error("event latency not added")
+	}
+}
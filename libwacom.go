@@ -0,0 +1,490 @@
+package chimp
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// LibwacomDatabase holds tablets and styli read from libwacom data files. The
+// data adds properties and capabilities of the physical device, such as its
+// size and pad buttons, to supported devices and names the tools reported in
+// EventPositionPen.ToolID. It's not used to add support for devices.
+type LibwacomDatabase struct {
+	Tablets []*LibwacomTablet
+	Styli   []*LibwacomStylus
+}
+
+// LibwacomTablet is a tablet described by a libwacom .tablet file.
+type LibwacomTablet struct {
+	Name              string
+	ModelName         string        // Model name such as "PTH-660", may be empty.
+	Match             []DeviceMatch // Identities of the tablet, matches on buses not known by this package are skipped.
+	WidthMillimeters  float64       // Width of the active area, zero if unknown.
+	HeightMillimeters float64       // Height of the active area, zero if unknown.
+	IntegratedIn      []string      // "Display" or "System" if tablet is built into a display or computer.
+	Styli             []string      // Stylus IDs and stylus groups prefixed by '@' used with the tablet.
+	Stylus            bool          // Has a stylus.
+	Touch             bool          // Has touch.
+	Reversible        bool          // Can be used rotated 180 degrees.
+	Rings             int           // Number of touch rings.
+	Strips            int           // Number of touch strips.
+	PadButtons        int           // Number of pad buttons.
+	PadButtonCodes    []string      // Linux key codes of pad buttons, e.g. "BTN_0", may be empty.
+}
+
+// LibwacomStylus is a stylus or other tool described by a libwacom .stylus
+// file.
+type LibwacomStylus struct {
+	Vendor     uint16   // Vendor ID, Wacom if not given by file.
+	ID         uint32   // Tool ID as reported by ABS_MISC.
+	Name       string   // Name such as "Grip Pen".
+	Group      string   // Group that tablets refer to as "@group", may be empty.
+	Type       string   // Type of tool such as "General", "Inking", "Airbrush" or "Puck".
+	EraserType string   // "None", "Invert" for erasers at the back end or "Button" for erasers selected by a button.
+	Buttons    int      // Number of side buttons.
+	Axes       []string // Axes such as "Tilt", "Pressure" and "Distance".
+}
+
+// USB vendor ID of Wacom, the default vendor of libwacom styli.
+const libwacomVendorWacom = 0x056a
+
+// LoadLibwacomDatabase reads all libwacom tablet (*.tablet) and stylus
+// (*.stylus) files in directory dir, e.g. /usr/share/libwacom. Tablets with
+// no identity on a bus known by this package, such as generic tablets, are
+// skipped as no device can match them.
+func LoadLibwacomDatabase(dir string) (*LibwacomDatabase, error) {
+	db := &LibwacomDatabase{}
+	for _, v := range []struct {
+		pattern string
+		load    func(r io.Reader) error
+	}{
+		{"*.tablet", func(r io.Reader) error {
+			tablet, err := ParseLibwacomTablet(r)
+			if err == nil && len(tablet.Match) > 0 {
+				db.Tablets = append(db.Tablets, tablet)
+			}
+			return err
+		}},
+		{"*.stylus", func(r io.Reader) error {
+			styli, err := ParseLibwacomStyli(r)
+			if err == nil {
+				db.Styli = append(db.Styli, styli...)
+			}
+			return err
+		}},
+	} {
+		paths, err := filepath.Glob(filepath.Join(dir, v.pattern))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			if err := loadLibwacomFile(path, v.load); err != nil {
+				return nil, fmt.Errorf("%s: %s", path, err)
+			}
+		}
+	}
+	return db, nil
+}
+
+func loadLibwacomFile(path string, load func(r io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return load(f)
+}
+
+// ParseLibwacomTablet parses a libwacom .tablet file. Keys not used by this
+// package are ignored.
+func ParseLibwacomTablet(r io.Reader) (*LibwacomTablet, error) {
+	kf, err := parseKeyFile(r)
+	if err != nil {
+		return nil, err
+	}
+
+	tablet := &LibwacomTablet{}
+	device := kf.section("Device")
+	if device == nil {
+		return nil, fmt.Errorf("section Device missing")
+	}
+	tablet.Name, _ = device.value("Name")
+	if tablet.Name == "" {
+		return nil, fmt.Errorf("device name missing")
+	}
+	tablet.ModelName, _ = device.value("ModelName")
+	if v, ok := device.value("DeviceMatch"); ok {
+		for _, s := range keyFileList(v) {
+			if match, ok := parseLibwacomMatch(s); ok {
+				tablet.Match = append(tablet.Match, match)
+			}
+		}
+	}
+	// Sizes are given in inches.
+	if tablet.WidthMillimeters, err = libwacomFloat(device, "Width"); err != nil {
+		return nil, err
+	}
+	tablet.WidthMillimeters *= 25.4
+	if tablet.HeightMillimeters, err = libwacomFloat(device, "Height"); err != nil {
+		return nil, err
+	}
+	tablet.HeightMillimeters *= 25.4
+	if v, ok := device.value("IntegratedIn"); ok {
+		tablet.IntegratedIn = keyFileList(v)
+	}
+	if v, ok := device.value("Styli"); ok {
+		tablet.Styli = keyFileList(v)
+	}
+
+	features := kf.section("Features")
+	for _, v := range []struct {
+		key string
+		dst *bool
+	}{
+		{"Stylus", &tablet.Stylus},
+		{"Touch", &tablet.Touch},
+		{"Reversible", &tablet.Reversible},
+	} {
+		if *v.dst, err = libwacomBool(features, v.key); err != nil {
+			return nil, err
+		}
+	}
+	for _, key := range []string{"Ring", "Ring2"} {
+		ring, err := libwacomBool(features, key)
+		if err != nil {
+			return nil, err
+		}
+		if ring {
+			tablet.Rings++
+		}
+	}
+	if _, ok := features.value("NumRings"); ok {
+		if tablet.Rings, err = libwacomInt(features, "NumRings"); err != nil {
+			return nil, err
+		}
+	}
+	if tablet.Strips, err = libwacomInt(features, "NumStrips"); err != nil {
+		return nil, err
+	}
+
+	// Older files give the number of buttons as a feature, newer files list
+	// the buttons by their position on the pad.
+	if tablet.PadButtons, err = libwacomInt(features, "Buttons"); err != nil {
+		return nil, err
+	}
+	buttons := kf.section("Buttons")
+	if n := libwacomButtonCount(buttons); n > 0 {
+		tablet.PadButtons = n
+	}
+	if v, ok := buttons.value("EvdevCodes"); ok {
+		tablet.PadButtonCodes = keyFileList(v)
+	}
+	return tablet, nil
+}
+
+// ParseLibwacomStyli parses a libwacom .stylus file. Every section describes
+// one stylus with an ID such as "0x802" or, in newer files, a vendor and ID
+// such as "0x56a:0x802". Keys not used by this package are ignored.
+func ParseLibwacomStyli(r io.Reader) ([]*LibwacomStylus, error) {
+	kf, err := parseKeyFile(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var styli []*LibwacomStylus
+	for _, section := range kf.sections {
+		stylus := &LibwacomStylus{Vendor: libwacomVendorWacom}
+		vendor, id := "", section.name
+		if i := strings.IndexByte(id, ':'); i >= 0 {
+			vendor, id = id[:i], id[i+1:]
+		}
+		if vendor != "" {
+			v, err := strconv.ParseUint(vendor, 0, 16)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: malformed stylus vendor %q", section.line, vendor)
+			}
+			stylus.Vendor = uint16(v)
+		}
+		v, err := strconv.ParseUint(id, 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: malformed stylus ID %q", section.line, id)
+		}
+		stylus.ID = uint32(v)
+
+		stylus.Name, _ = section.value("Name")
+		stylus.Group, _ = section.value("Group")
+		stylus.Type, _ = section.value("Type")
+		stylus.EraserType, _ = section.value("EraserType")
+		if stylus.Buttons, err = libwacomInt(section, "Buttons"); err != nil {
+			return nil, err
+		}
+		if v, ok := section.value("Axes"); ok {
+			stylus.Axes = keyFileList(v)
+		}
+		styli = append(styli, stylus)
+	}
+	return styli, nil
+}
+
+// parseLibwacomMatch parses a libwacom device match such as "usb|056a|0357",
+// optionally followed by the device name. False is returned for matches not
+// known by this package such as "generic" or matches on unknown buses.
+func parseLibwacomMatch(s string) (match DeviceMatch, ok bool) {
+	fields := strings.Split(s, "|")
+	if len(fields) < 3 {
+		return match, false
+	}
+	bus, err := parseBusType(fields[0])
+	if err != nil {
+		return match, false
+	}
+	vendor, err := strconv.ParseUint(fields[1], 16, 16)
+	if err != nil {
+		return match, false
+	}
+	product, err := strconv.ParseUint(fields[2], 16, 16)
+	if err != nil {
+		return match, false
+	}
+	return DeviceMatch{BusType: bus, Vendor: uint16(vendor), Product: uint16(product)}, true
+}
+
+// libwacomButtonCount returns the number of buttons listed by position in the
+// Buttons section.
+func libwacomButtonCount(buttons *keyFileSection) int {
+	n := 0
+	for _, key := range []string{"Left", "Right", "Top", "Bottom"} {
+		if v, ok := buttons.value(key); ok {
+			n += len(keyFileList(v))
+		}
+	}
+	return n
+}
+
+// Pad buttons that are reported as buttons of this package. Pads with more
+// buttons report BTN_0 and up, which have no Button, see
+// LibwacomTablet.UnmappedPadButtonCodes.
+var libwacomPadButtons = map[string]Button{
+	"BTN_LEFT":    ButtonLeft,
+	"BTN_RIGHT":   ButtonRight,
+	"BTN_FORWARD": ButtonForward,
+	"BTN_BACK":    ButtonBack,
+}
+
+func libwacomBool(section *keyFileSection, key string) (bool, error) {
+	v, ok := section.value(key)
+	if !ok {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s: %s", key, err)
+	}
+	return b, nil
+}
+
+func libwacomInt(section *keyFileSection, key string) (int, error) {
+	v, ok := section.value(key)
+	if !ok {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %s", key, err)
+	}
+	return n, nil
+}
+
+func libwacomFloat(section *keyFileSection, key string) (float64, error) {
+	v, ok := section.value(key)
+	if !ok {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %s", key, err)
+	}
+	return f, nil
+}
+
+// Tablet returns the tablet with identity or nil if it's not in the database.
+func (db *LibwacomDatabase) Tablet(id *DeviceIdentity) *LibwacomTablet {
+	for _, tablet := range db.Tablets {
+		for _, v := range tablet.Match {
+			if v.BusType == id.BusType && v.Vendor == id.Vendor && v.Product == id.Product {
+				return tablet
+			}
+		}
+	}
+	return nil
+}
+
+// Stylus returns the stylus with tool ID of vendor or nil if it's not in the
+// database. The tool ID is reported in EventPositionPen.ToolID.
+func (db *LibwacomDatabase) Stylus(vendor uint16, toolID uint32) *LibwacomStylus {
+	for _, stylus := range db.Styli {
+		if stylus.Vendor == vendor && stylus.ID == toolID {
+			return stylus
+		}
+	}
+	return nil
+}
+
+// ToolName returns the name of the tool with ID of vendor or an empty string if
+// it's not in the database.
+func (db *LibwacomDatabase) ToolName(vendor uint16, toolID uint32) string {
+	if stylus := db.Stylus(vendor, toolID); stylus != nil {
+		return stylus.Name
+	}
+	return ""
+}
+
+// styli returns the styli used with tablet.
+func (db *LibwacomDatabase) styli(tablet *LibwacomTablet, vendor uint16) []*LibwacomStylus {
+	var styli []*LibwacomStylus
+	for _, s := range tablet.Styli {
+		if strings.HasPrefix(s, "@") {
+			for _, stylus := range db.Styli {
+				if stylus.Group == s[1:] {
+					styli = append(styli, stylus)
+				}
+			}
+		} else if id, err := strconv.ParseUint(s, 0, 32); err == nil {
+			if stylus := db.Stylus(vendor, uint32(id)); stylus != nil {
+				styli = append(styli, stylus)
+			}
+		}
+	}
+	return styli
+}
+
+// Properties returns properties of the tablet.
+func (tablet *LibwacomTablet) Properties() Properties {
+	props := Properties{
+		PropertyDeviceName: PropertyValueString(tablet.Name),
+		PropertyDeviceType: PropertyValueString(DeviceTypeTablet.String()),
+		PropertyPadButtons: PropertyValueNumber(tablet.PadButtons),
+		PropertyRings:      PropertyValueNumber(tablet.Rings),
+		PropertyStrips:     PropertyValueNumber(tablet.Strips),
+	}
+	if tablet.ModelName != "" {
+		props[PropertyModelName] = PropertyValueString(tablet.ModelName)
+	}
+	if len(tablet.IntegratedIn) > 0 {
+		props[PropertyIntegratedIn] = PropertyValueString(strings.Join(tablet.IntegratedIn, ";"))
+	}
+	if tablet.WidthMillimeters > 0 && tablet.HeightMillimeters > 0 {
+		props[PropertyPadWidthMillimeters] = PropertyValueNumber(tablet.WidthMillimeters)
+		props[PropertyPadHeightMillimeters] = PropertyValueNumber(tablet.HeightMillimeters)
+		props[PropertyPadWidthHeightRatio] = PropertyValueNumber(tablet.WidthMillimeters / tablet.HeightMillimeters)
+	}
+	return props
+}
+
+// Capabilities returns capabilities of the tablet used with styli. Pad buttons
+// that have no corresponding Button are left out, see UnmappedPadButtonCodes.
+func (tablet *LibwacomTablet) Capabilities(styli []*LibwacomStylus) Capabilities {
+	var caps Capabilities
+	addButton := func(button Button) {
+		if !caps.HasButton(button) {
+			caps.Buttons = append(caps.Buttons, button)
+		}
+	}
+
+	if tablet.Stylus {
+		caps.PositionDevices = append(caps.PositionDevices, PositionDevicePen)
+		addButton(ButtonPenTip)
+		for _, stylus := range styli {
+			if stylus.EraserType != "" && stylus.EraserType != "None" {
+				addButton(ButtonPenEraser)
+			}
+			for i, button := range []Button{ButtonPen1, ButtonPen2, ButtonPen3} {
+				if i < stylus.Buttons {
+					addButton(button)
+				}
+			}
+		}
+	}
+	for _, code := range tablet.PadButtonCodes {
+		if button, ok := libwacomPadButtons[code]; ok {
+			addButton(button)
+		}
+	}
+	if tablet.Touch {
+		caps.PositionDevices = append(caps.PositionDevices, PositionDeviceFinger)
+		addButton(ButtonTouch)
+	}
+	return caps
+}
+
+// UnmappedPadButtonCodes returns the codes of pad buttons that have no
+// corresponding Button and are not reported by devices of this package.
+func (tablet *LibwacomTablet) UnmappedPadButtonCodes() []string {
+	var codes []string
+	for _, code := range tablet.PadButtonCodes {
+		if _, ok := libwacomPadButtons[code]; !ok {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+var libwacomDatabase struct {
+	sync.Mutex
+	db *LibwacomDatabase
+}
+
+// UseLibwacomDatabase sets the database used when devices are opened.
+// Properties and capabilities of a device found in the database are added to
+// those known by this package, which take precedence. Use nil to stop using a
+// database.
+func UseLibwacomDatabase(db *LibwacomDatabase) {
+	libwacomDatabase.Lock()
+	libwacomDatabase.db = db
+	libwacomDatabase.Unlock()
+}
+
+// libwacomDescribe adds properties and capabilities found in the libwacom
+// database in use to those of a device with identity. The properties are
+// modified in place.
+func libwacomDescribe(id *DeviceIdentity, props Properties, caps Capabilities) Capabilities {
+	libwacomDatabase.Lock()
+	db := libwacomDatabase.db
+	libwacomDatabase.Unlock()
+	if db == nil {
+		return caps
+	}
+	tablet := db.Tablet(id)
+	if tablet == nil {
+		return caps
+	}
+
+	for k, v := range tablet.Properties() {
+		if _, ok := props[k]; !ok {
+			props[k] = v
+		}
+	}
+	extra := tablet.Capabilities(db.styli(tablet, id.Vendor))
+	merged := Capabilities{
+		PositionDevices: append([]PositionDevice(nil), caps.PositionDevices...),
+		Buttons:         append([]Button(nil), caps.Buttons...),
+	}
+	for _, v := range extra.PositionDevices {
+		if !merged.HasPositionDevice(v) {
+			merged.PositionDevices = append(merged.PositionDevices, v)
+		}
+	}
+	for _, v := range extra.Buttons {
+		if !merged.HasButton(v) {
+			merged.Buttons = append(merged.Buttons, v)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,87 @@
+package chimp
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testLibwacomIntuos = `[Device]
+Name=Wacom Intuos Pro M
+ModelName=PTH-660
+DeviceMatch=usb|056a|0357;bluetooth|056a|0360;
+Width=9
+Height=6
+
+[Features]
+Stylus=true
+Touch=true
+Ring=true
+
+[Buttons]
+Left=A;B;C;D;E;F;G;H;I
+EvdevCodes=BTN_0;BTN_1;BTN_2;BTN_3;BTN_4;BTN_5;BTN_6;BTN_7;BTN_8
+`
+
+const testLibwacomGeneric = `[Device]
+Name=Generic Pen Tablet
+DeviceMatch=generic
+
+[Features]
+Stylus=true
+`
+
+const testLibwacomSmall = `[Device]
+Name=Wacom Intuos S
+DeviceMatch=usb|056a|0374
+Width=6
+Height=4
+
+[Buttons]
+Top=A;B;C;D
+EvdevCodes=BTN_LEFT;BTN_RIGHT;BTN_MIDDLE;BTN_FORWARD
+`
+
+func TestLoadLibwacomDatabase(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"intuos-pro-m.tablet": testLibwacomIntuos,
+		"generic.tablet":      testLibwacomGeneric,
+		"intuos-s.tablet":     testLibwacomSmall,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db, err := LoadLibwacomDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tablets are loaded in file name order, the generic tablet is skipped.
+	var names []string
+	for _, tablet := range db.Tablets {
+		names = append(names, tablet.Name)
+	}
+	if want := []string{"Wacom Intuos Pro M", "Wacom Intuos S"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("loaded tablets %q, want %q", names, want)
+	}
+
+	pro, small := db.Tablets[0], db.Tablets[1]
+	if len(pro.Match) != 2 || pro.Match[1].BusType != BusTypeBluetooth {
+		t.Errorf("matches %v", pro.Match)
+	}
+	if pro.PadButtons != 9 || pro.Rings != 1 {
+		t.Errorf("got %d pad buttons and %d rings", pro.PadButtons, pro.Rings)
+	}
+	if codes := pro.UnmappedPadButtonCodes(); len(codes) != 9 || codes[0] != "BTN_0" {
+		t.Errorf("unmapped pad button codes %q", codes)
+	}
+	if codes := small.UnmappedPadButtonCodes(); !reflect.DeepEqual(codes, []string{"BTN_MIDDLE"}) {
+		t.Errorf("unmapped pad button codes %q", codes)
+	}
+	if caps := small.Capabilities(nil); !reflect.DeepEqual(caps.Buttons, []Button{ButtonLeft, ButtonRight, ButtonForward}) {
+		t.Errorf("pad buttons %v", caps.Buttons)
+	}
+}
@@ -0,0 +1,243 @@
+package chimp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EventPadRing is generated when a finger moves on a touch ring of a pad.
+type EventPadRing struct {
+	Timestamp time.Time // Time when event was generated.
+	Delivered time.Time // Time when event was read from device, same clock as Timestamp.
+
+	// Time of the device clock when event was generated, see EventButton.
+	DeviceTimestamp    time.Duration
+	HasDeviceTimestamp bool
+
+	Ring     int     // Index of ring.
+	Position float32 // Position in range [0, 1) clockwise around the ring, negative when the finger is lifted.
+	Mode     int     // Mode of the mode group of the ring, zero if the ring is not in a group.
+}
+
+func (e *EventPadRing) Time() time.Time {
+	return e.Timestamp
+}
+
+func (e *EventPadRing) String() string {
+	return fmt.Sprintf(fmtEventPadRing, e.Timestamp, e.Ring, e.Position, e.Mode)
+}
+
+// EventPadStrip is generated when a finger moves on a touch strip of a pad.
+type EventPadStrip struct {
+	Timestamp time.Time // Time when event was generated.
+	Delivered time.Time // Time when event was read from device, same clock as Timestamp.
+
+	// Time of the device clock when event was generated, see EventButton.
+	DeviceTimestamp    time.Duration
+	HasDeviceTimestamp bool
+
+	Strip    int     // Index of strip.
+	Position float32 // Position in range [0, 1] from the top or left end, negative when the finger is lifted.
+	Mode     int     // Mode of the mode group of the strip, zero if the strip is not in a group.
+}
+
+func (e *EventPadStrip) Time() time.Time {
+	return e.Timestamp
+}
+
+func (e *EventPadStrip) String() string {
+	return fmt.Sprintf(fmtEventPadStrip, e.Timestamp, e.Strip, e.Position, e.Mode)
+}
+
+// EventPadMode is generated when a toggle button changes the mode of a pad
+// mode group. It replaces the EventButton of the toggle button press, the
+// release is not reported.
+type EventPadMode struct {
+	Timestamp time.Time // Time when event was generated.
+	Delivered time.Time // Time when event was read from device, same clock as Timestamp.
+
+	// Time of the device clock when event was generated, see EventButton.
+	DeviceTimestamp    time.Duration
+	HasDeviceTimestamp bool
+
+	Group  int    // Index of mode group.
+	Mode   int    // New mode of the group.
+	Button Button // Toggle button that changed the mode.
+}
+
+func (e *EventPadMode) Time() time.Time {
+	return e.Timestamp
+}
+
+func (e *EventPadMode) String() string {
+	return fmt.Sprintf(fmtEventPadMode, e.Timestamp, e.Group, e.Mode, e.Button)
+}
+
+const fmtEventPadRing = `EventPadRing: {
+    Time:     %s
+    Ring:     %d
+    Position: %f
+    Mode:     %d
+}`
+
+const fmtEventPadStrip = `EventPadStrip: {
+    Time:     %s
+    Strip:    %d
+    Position: %f
+    Mode:     %d
+}`
+
+const fmtEventPadMode = `EventPadMode: {
+    Time:     %s
+    Group:    %d
+    Mode:     %d
+    Button:   %s
+}`
+
+// PadModeGroup is a group of pad controls that share a mode, as in the
+// tablet pad API of libinput. Pads typically have one group with a button
+// that cycles through the modes of a ring or strip, often indicated by LEDs.
+// The controls of a group are meant to be interpreted by the application
+// according to the mode of the group. Buttons may be bound per mode with a
+// ButtonMapper.
+type PadModeGroup struct {
+	Modes   int      // Number of modes, at least one.
+	Toggle  []Button // Buttons that cycle to the next mode when pressed.
+	Buttons []Button // Other buttons in the group.
+	Rings   []int    // Indices of rings in the group.
+	Strips  []int    // Indices of strips in the group.
+}
+
+func (g *PadModeGroup) hasToggle(button Button) bool {
+	for _, v := range g.Toggle {
+		if v == button {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *PadModeGroup) hasButton(button Button) bool {
+	for _, v := range g.Buttons {
+		if v == button {
+			return true
+		}
+	}
+	return g.hasToggle(button)
+}
+
+// PadModeDevice is implemented by devices with pad mode groups.
+type PadModeDevice interface {
+	// PadModes returns the mode groups of the pad and their modes. Nil is
+	// returned if the pad has no mode groups.
+	PadModes() *PadModes
+}
+
+// PadModes tracks the modes of pad mode groups. It's an event filter that
+// replaces presses of toggle buttons with EventPadMode and sets the mode of
+// ring and strip events. Devices with mode groups apply it to their events,
+// see PadModeDevice. All groups start in mode zero.
+//
+// The modes may be read at any time from any goroutine.
+type PadModes struct {
+	mu      sync.Mutex
+	groups  []PadModeGroup
+	modes   []int
+	pressed map[Button]bool // Toggle buttons that are pressed.
+}
+
+// NewPadModes creates a tracker of the modes of groups.
+func NewPadModes(groups []PadModeGroup) *PadModes {
+	return &PadModes{
+		groups:  append([]PadModeGroup(nil), groups...),
+		modes:   make([]int, len(groups)),
+		pressed: map[Button]bool{},
+	}
+}
+
+// Groups returns the mode groups.
+func (p *PadModes) Groups() []PadModeGroup {
+	return append([]PadModeGroup(nil), p.groups...)
+}
+
+// Mode returns the mode of group.
+func (p *PadModes) Mode(group int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.modes[group]
+}
+
+// GroupOf returns the index of the mode group of button, or false if the
+// button is not in a group.
+func (p *PadModes) GroupOf(button Button) (int, bool) {
+	for i := range p.groups {
+		if p.groups[i].hasButton(button) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (p *PadModes) Filter(event Event) []Event {
+	if event = p.mapEvent(event); event == nil {
+		return nil
+	}
+	return []Event{event}
+}
+
+// mapEvent maps one event. Nil is returned if the event is dropped.
+func (p *PadModes) mapEvent(event Event) Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch e := event.(type) {
+	case *EventButton:
+		for i := range p.groups {
+			g := &p.groups[i]
+			if !g.hasToggle(e.Button) {
+				continue
+			}
+			pressed := e.Pressure > 0
+			wasPressed := p.pressed[e.Button]
+			p.pressed[e.Button] = pressed
+			if !pressed || wasPressed {
+				return nil
+			}
+			if g.Modes > 0 {
+				p.modes[i] = (p.modes[i] + 1) % g.Modes
+			}
+			return &EventPadMode{
+				Timestamp:          e.Timestamp,
+				Delivered:          e.Delivered,
+				DeviceTimestamp:    e.DeviceTimestamp,
+				HasDeviceTimestamp: e.HasDeviceTimestamp,
+				Group:              i,
+				Mode:               p.modes[i],
+				Button:             e.Button,
+			}
+		}
+	case *EventPadRing:
+		for i := range p.groups {
+			if containsInt(p.groups[i].Rings, e.Ring) {
+				e.Mode = p.modes[i]
+			}
+		}
+	case *EventPadStrip:
+		for i := range p.groups {
+			if containsInt(p.groups[i].Strips, e.Strip) {
+				e.Mode = p.modes[i]
+			}
+		}
+	}
+	return event
+}
+
+func containsInt(s []int, v int) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package chimp
+
+import "time"
+
+// PalmRejection is a policy for suppressing unintended touch input, e.g. from
+// the hand resting on the pad while drawing with the pen.
+type PalmRejection struct {
+	Enabled bool // Enable palm rejection.
+
+	// Touch is suppressed while a pen tool is in proximity and for this long
+	// after it left proximity.
+	GracePeriod time.Duration
+
+	// Touch is rejected while any contact has a major axis larger than
+	// this. All contacts are rejected, not only the large one, as the touch
+	// position and button are shared by the contacts. Zero disables the size
+	// check. Only applicable to devices reporting contact size.
+	MaxContactMillimeters float32
+}
+
+// DefaultPalmRejection is a reasonable palm rejection policy.
+var DefaultPalmRejection = PalmRejection{
+	Enabled:               true,
+	GracePeriod:           250 * time.Millisecond,
+	MaxContactMillimeters: 25,
+}
+
+// PalmRejecter is implemented by devices supporting palm rejection.
+// Rejection applies to all touch input of the device at once. Once rejected,
+// touch input is suppressed until all contacts have left the pad. A touch
+// release event is generated if touch was already reported when rejection
+// started.
+type PalmRejecter interface {
+	// SetPalmRejection sets the palm rejection policy. Palm rejection is
+	// disabled by default.
+	SetPalmRejection(policy PalmRejection)
+}
@@ -0,0 +1,101 @@
+package chimp
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// touchSummary describes the touch events of events, one string per event
+// with time since the start of the recording.
+func touchSummary(events []Event) []string {
+	var summary []string
+	for _, event := range events {
+		at := event.Time().Sub(recordingStart).Round(time.Millisecond)
+		switch e := event.(type) {
+		case *EventPositionFinger:
+			summary = append(summary, fmt.Sprintf("%s contacts %d", at, len(e.Contacts)))
+		case *EventButton:
+			if e.Button == ButtonTouch {
+				summary = append(summary, fmt.Sprintf("%s touch %g", at, e.Pressure))
+			}
+		}
+	}
+	return summary
+}
+
+func TestPalmRejection(t *testing.T) {
+	noSize := DefaultPalmRejection
+	noSize.MaxContactMillimeters = 0
+	shortGrace := DefaultPalmRejection
+	shortGrace.GracePeriod = 50 * time.Millisecond
+
+	for _, test := range []struct {
+		name      string
+		recording string
+		policy    PalmRejection
+		want      []string
+	}{
+		{
+			"disabled", "palm-grace.txt", PalmRejection{},
+			[]string{
+				"200ms contacts 1", "200ms touch 1", "400ms contacts 1", "450ms contacts 0", "450ms touch 0",
+				"500ms contacts 1", "500ms touch 1", "550ms contacts 0", "550ms touch 0",
+			},
+		},
+		{
+			"touch in grace period", "palm-grace.txt", DefaultPalmRejection,
+			[]string{"500ms contacts 1", "500ms touch 1", "550ms contacts 0", "550ms touch 0"},
+		},
+		{
+			"touch after grace period", "palm-grace.txt", shortGrace,
+			[]string{
+				"200ms contacts 1", "200ms touch 1", "400ms contacts 1", "450ms contacts 0", "450ms touch 0",
+				"500ms contacts 1", "500ms touch 1", "550ms contacts 0", "550ms touch 0",
+			},
+		},
+		{
+			"pen enters while touching", "palm-pen.txt", DefaultPalmRejection,
+			[]string{"0s contacts 1", "0s touch 1", "60ms touch 0", "600ms contacts 1", "600ms touch 1"},
+		},
+		{
+			"large contact", "palm-size.txt", DefaultPalmRejection,
+			[]string{"0s contacts 1", "0s touch 1", "50ms touch 0", "200ms contacts 1", "200ms touch 1"},
+		},
+		{
+			"size check disabled", "palm-size.txt", noSize,
+			[]string{
+				"0s contacts 1", "0s touch 1", "50ms contacts 2", "100ms contacts 1", "150ms contacts 0", "150ms touch 0",
+				"200ms contacts 1", "200ms touch 1",
+			},
+		},
+	} {
+		dev := newTestWacomDevice(t)
+		dev.SetPalmRejection(test.policy)
+		if got := touchSummary(replayRecording(t, dev, test.recording)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestPalmRejectionContactSize(t *testing.T) {
+	// The Bamboo finger axis is 216 mm over 4095 units, the threshold is
+	// crossed between 473 and 474 units.
+	for _, test := range []struct {
+		major  int32
+		reject bool
+	}{
+		{150, false},
+		{473, false},
+		{474, true},
+		{700, true},
+	} {
+		dev := newTestWacomDevice(t)
+		dev.SetPalmRejection(PalmRejection{Enabled: true, MaxContactMillimeters: 25})
+		dev.state.fingerSlots[0] = wacomFingerSlot{trackingID: 1, touchMajor: test.major}
+		if reject := dev.rejectTouch(recordingStart); reject != test.reject {
+			t.Errorf("contact of %d units: rejected %t, want %t", test.major, reject, test.reject)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package chimp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InputNodeProbe is what an input device node reports about itself. It's used
+// to find out how an unsupported device can be supported.
+type InputNodeProbe struct {
+	InputNode
+	EventTypes []ProbeEventType // Supported event types.
+	Props      []string         // Device properties such as "INPUT_PROP_DIRECT".
+}
+
+// ProbeEventType is an event type supported by an input node.
+type ProbeEventType struct {
+	Type  uint16 // Event type, e.g. EV_ABS.
+	Name  string // Name of event type, e.g. "EV_ABS".
+	Codes []ProbeCode
+}
+
+// ProbeCode is an event code supported by an input node.
+type ProbeCode struct {
+	Code uint16   // Event code, e.g. ABS_X.
+	Name string   // Name of event code, e.g. "ABS_X".
+	Abs  *AbsInfo // Axis information of absolute axes, nil for other event types.
+}
+
+// AbsInfo is information about an absolute axis.
+type AbsInfo struct {
+	Value      int32 // Current value.
+	Min, Max   int32 // Range of values.
+	Fuzz       int32 // Noise filtered by the kernel.
+	Flat       int32 // Values within this distance from the center are reported as the center.
+	Resolution int32 // Units per millimeter, or units per radian for rotational axes. Zero if unknown.
+}
+
+// InputNodeActivity is input read from an input node while recording.
+type InputNodeActivity struct {
+	Path   string
+	Events int                  // Number of events read, excluding synchronization events.
+	Keys   []uint16             // Codes of keys and buttons pressed, in order of first press.
+	Axes   map[uint16]AxisRange // Range of values reported by absolute axes.
+
+	// Positive pressure and distance were reported in the same event group,
+	// see DeviceQuirks.ClearDistanceOnPressure.
+	PressureWithDistance bool
+}
+
+// ProbeInputNode reads what the input device node at path, e.g.
+// /dev/input/event3, reports about itself.
+func ProbeInputNode(path string) (*InputNodeProbe, error) {
+	return probeInputNode(path)
+}
+
+// RecordInputNodes reads events from input device nodes until stop is closed
+// and returns what was observed on each node. Reading ends early if a node
+// fails, e.g. when the device is unplugged, and the activity recorded until
+// then is returned with the error. The function onEvent, which may be nil, is
+// called from another goroutine for every event read, one event at a time, in
+// timestamp order of the event groups of all nodes.
+func RecordInputNodes(paths []string, stop <-chan struct{}, onEvent func(node int, event *InputEvent)) ([]InputNodeActivity, error) {
+	return recordInputNodes(paths, stop, onEvent)
+}
+
+// DraftDeviceDescriptor drafts a descriptor of a device from the probes of its
+// input nodes and, if not nil, their activity recorded while all tools and
+// buttons of the device were exercised. Recorded activity narrows down buttons
+// to the ones actually present, as drivers often report more than there are.
+//
+// The draft should be reviewed before it's used. Notes about what could not be
+// described, or needs attention, are returned along with the draft. Devices that
+// can't be described need a Driver.
+func DraftDeviceDescriptor(probes []*InputNodeProbe, activity []InputNodeActivity) (desc *DeviceDescriptor, notes []string) {
+	return draftDeviceDescriptor(probes, activity)
+}
+
+func (p *InputNodeProbe) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %q\n    Phys:     %q\n    Identity: %s\n", p.Path, p.Name, p.Phys, &p.Identity)
+	if len(p.Props) > 0 {
+		fmt.Fprintf(&sb, "    Props:    %s\n", strings.Join(p.Props, " "))
+	}
+	for _, t := range p.EventTypes {
+		fmt.Fprintf(&sb, "    %05d %s\n", t.Type, t.Name)
+		for _, c := range t.Codes {
+			if c.Abs != nil {
+				fmt.Fprintf(&sb, "        %05d %-24s %d .. %d, resolution %d, fuzz %d, flat %d\n",
+					c.Code, c.Name, c.Abs.Min, c.Abs.Max, c.Abs.Resolution, c.Abs.Fuzz, c.Abs.Flat)
+			} else {
+				fmt.Fprintf(&sb, "        %05d %s\n", c.Code, c.Name)
+			}
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// hasCode checks if the node supports event code of event type.
+func (p *InputNodeProbe) hasCode(typ, code uint16) bool {
+	return p.code(typ, code) != nil
+}
+
+func (p *InputNodeProbe) code(typ, code uint16) *ProbeCode {
+	for i := range p.EventTypes {
+		if t := &p.EventTypes[i]; t.Type == typ {
+			for j := range t.Codes {
+				if t.Codes[j].Code == code {
+					return &t.Codes[j]
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,423 @@
+package chimp
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unsafe"
+
+	evdev "github.com/johan-bolmsjo/golang-evdev"
+)
+
+// Names of INPUT_PROP_* bits, not provided by the evdev package.
+var inputPropNames = []string{
+	"INPUT_PROP_POINTER",
+	"INPUT_PROP_DIRECT",
+	"INPUT_PROP_BUTTONPAD",
+	"INPUT_PROP_SEMI_MT",
+	"INPUT_PROP_TOPBUTTONPAD",
+	"INPUT_PROP_POINTING_STICK",
+	"INPUT_PROP_ACCELEROMETER",
+}
+
+func probeInputNode(path string) (*InputNodeProbe, error) {
+	dev, err := evdev.Open(path)
+	if err != nil {
+		return nil, newDeviceError("open", path, err)
+	}
+	defer dev.File.Close()
+
+	devInfo := linuxDeviceInfo{dev: path, name: dev.Name, phys: dev.Phys, identity: inputDeviceIdentity(dev)}
+	devInfo.group = linuxDeviceGroup(&devInfo)
+	p := &InputNodeProbe{InputNode: devInfo.inputNode()}
+
+	for capType, capCodes := range dev.Capabilities {
+		t := ProbeEventType{Type: uint16(capType.Type), Name: capType.Name}
+		for _, v := range capCodes {
+			c := ProbeCode{Code: uint16(v.Code), Name: v.Name}
+			if c.Name == "" {
+				c.Name = fmt.Sprintf("0x%03x", v.Code)
+			}
+			if t.Type == evdev.EV_ABS {
+				var info inputAbsinfo
+				if err := ioctl(dev.File.Sysfd(), eviocGAbs(c.Code), unsafe.Pointer(&info)); err != nil {
+					return nil, newDeviceError("get axis info", path, err)
+				}
+				c.Abs = &AbsInfo{
+					Value:      info.value,
+					Min:        info.minimum,
+					Max:        info.maximum,
+					Fuzz:       info.fuzz,
+					Flat:       info.flat,
+					Resolution: info.resolution,
+				}
+			}
+			t.Codes = append(t.Codes, c)
+		}
+		sort.Slice(t.Codes, func(i, j int) bool { return t.Codes[i].Code < t.Codes[j].Code })
+		p.EventTypes = append(p.EventTypes, t)
+	}
+	sort.Slice(p.EventTypes, func(i, j int) bool { return p.EventTypes[i].Type < p.EventTypes[j].Type })
+
+	// Properties are not supported by very old kernels, leave them out then.
+	var props [4]byte
+	if err := ioctl(dev.File.Sysfd(), eviocGProp(len(props)), unsafe.Pointer(&props[0])); err == nil {
+		for bit := 0; bit < len(props)*8; bit++ {
+			if props[bit/8]&(1<<uint(bit%8)) != 0 {
+				if bit < len(inputPropNames) {
+					p.Props = append(p.Props, inputPropNames[bit])
+				} else {
+					p.Props = append(p.Props, fmt.Sprintf("INPUT_PROP_0x%02x", bit))
+				}
+			}
+		}
+	}
+	return p, nil
+}
+
+func recordInputNodes(paths []string, stop <-chan struct{}, onEvent func(node int, event *InputEvent)) ([]InputNodeActivity, error) {
+	sources := make([]*inputSource, 0, len(paths))
+	defer func() {
+		for _, v := range sources {
+			v.inputDevice.File.Close()
+		}
+	}()
+	for _, path := range paths {
+		dev, err := evdev.Open(path)
+		if err != nil {
+			return nil, newDeviceError("open", path, err)
+		}
+		sources = append(sources, &inputSource{inputDevice: dev})
+	}
+
+	// The nodes are read with the same reader as open devices, so that event
+	// groups of different nodes are recorded in timestamp order and reading
+	// is stopped without closing files that are being read.
+	reader, err := newInputReader(sources)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.close()
+
+	activity := make([]InputNodeActivity, len(paths))
+	for i := range activity {
+		activity[i] = InputNodeActivity{Path: paths[i], Axes: map[uint16]AxisRange{}}
+	}
+	done := make(chan error, 1)
+	go func() {
+		for {
+			groups, shutdown, err := reader.wait(-1)
+			if err != nil || shutdown {
+				done <- err
+				return
+			}
+			for _, group := range groups {
+				node := group.source.index
+				recordInputEventGroup(&activity[node], group.events)
+				if onEvent != nil {
+					for _, v := range group.events {
+						onEvent(node, &InputEvent{Time: inputEventTime(&v), Type: v.Type, Code: v.Code, Value: v.Value})
+					}
+				}
+			}
+		}
+	}()
+
+	select {
+	case <-stop:
+		reader.wakeup()
+		err = <-done
+	case err = <-done:
+	}
+	return activity, err
+}
+
+// recordInputEventGroup records the activity seen in one group of input
+// events terminated by SYN_REPORT or SYN_DROPPED.
+func recordInputEventGroup(a *InputNodeActivity, inputEvents []evdev.InputEvent) {
+	var pressure, distance bool // Positive values in the group.
+	for _, v := range inputEvents {
+		switch v.Type {
+		case evdev.EV_SYN:
+			if v.Code == evdev.SYN_REPORT && pressure && distance {
+				a.PressureWithDistance = true
+			}
+		case evdev.EV_KEY:
+			if v.Value == 1 && !containsCode(a.Keys, v.Code) {
+				a.Keys = append(a.Keys, v.Code)
+			}
+		case evdev.EV_ABS:
+			r, ok := a.Axes[v.Code]
+			if !ok || v.Value < r.Min {
+				r.Min = v.Value
+			}
+			if !ok || v.Value > r.Max {
+				r.Max = v.Value
+			}
+			a.Axes[v.Code] = r
+			switch v.Code {
+			case evdev.ABS_PRESSURE:
+				pressure = v.Value > 0
+			case evdev.ABS_DISTANCE:
+				distance = v.Value > 0
+			}
+		}
+		if v.Type != evdev.EV_SYN {
+			a.Events++
+		}
+	}
+}
+
+func containsCode(codes []uint16, code uint16) bool {
+	for _, v := range codes {
+		if v == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Key codes that are handled by descriptor based devices without being
+// reported as buttons.
+var draftImplicitKeys = map[uint16]bool{
+	evdev.BTN_TOOL_PEN:       true,
+	evdev.BTN_TOOL_RUBBER:    true,
+	evdev.BTN_TOUCH:          true,
+	evdev.BTN_TOOL_FINGER:    true,
+	evdev.BTN_TOOL_DOUBLETAP: true,
+	evdev.BTN_TOOL_TRIPLETAP: true,
+	evdev.BTN_TOOL_QUADTAP:   true,
+	evdev.BTN_TOOL_QUINTTAP:  true,
+}
+
+// Absolute axes used by descriptor based devices.
+var draftUsedAxes = map[uint16]bool{
+	evdev.ABS_X:              true,
+	evdev.ABS_Y:              true,
+	evdev.ABS_PRESSURE:       true,
+	evdev.ABS_DISTANCE:       true,
+	evdev.ABS_MISC:           true,
+	evdev.ABS_MT_SLOT:        true,
+	evdev.ABS_MT_TOUCH_MAJOR: true,
+	evdev.ABS_MT_TOUCH_MINOR: true,
+	evdev.ABS_MT_POSITION_X:  true,
+	evdev.ABS_MT_POSITION_Y:  true,
+	evdev.ABS_MT_TRACKING_ID: true,
+}
+
+// Axes of rings and strips in order of index, used by pads only.
+var (
+	draftRingAxes  = []uint16{evdev.ABS_WHEEL, evdev.ABS_THROTTLE}
+	draftStripAxes = []uint16{evdev.ABS_RX, evdev.ABS_RY}
+)
+
+func draftDeviceDescriptor(probes []*InputNodeProbe, activity []InputNodeActivity) (*DeviceDescriptor, []string) {
+	desc := &DeviceDescriptor{Type: DeviceTypeTablet}
+	var notes []string
+	notef := func(format string, a ...interface{}) {
+		notes = append(notes, fmt.Sprintf(format, a...))
+	}
+
+	var names []string
+	for _, p := range probes {
+		names = append(names, p.Name)
+
+		var role string
+		var dst **SubDeviceDescriptor
+		switch {
+		case p.hasCode(evdev.EV_KEY, evdev.BTN_TOOL_PEN):
+			role, dst = "Pen", &desc.Pen
+		case p.hasCode(evdev.EV_KEY, evdev.BTN_TOOL_FINGER) || p.hasCode(evdev.EV_ABS, evdev.ABS_MT_POSITION_X):
+			role, dst = "Finger", &desc.Finger
+		case p.hasCode(evdev.EV_KEY, evdev.BTN_LEFT) || p.hasCode(evdev.EV_KEY, evdev.BTN_0):
+			role, dst = "Pad", &desc.Pad
+		default:
+			notef("%s %q: no pen, touch or pad buttons, not described", p.Path, p.Name)
+			continue
+		}
+		if *dst != nil {
+			notef("%s %q: more than one %s node, not described", p.Path, p.Name, strings.ToLower(role))
+			continue
+		}
+
+		sub := &SubDeviceDescriptor{NamePattern: regexp.MustCompile("^" + regexp.QuoteMeta(p.Name) + "$")}
+		*dst = sub
+
+		var act *InputNodeActivity
+		for i := range activity {
+			if activity[i].Path == p.Path && activity[i].Events > 0 {
+				act = &activity[i]
+			}
+		}
+		if activity != nil && act == nil {
+			notef("%s %q: no input recorded, buttons are taken from reported capabilities", p.Path, p.Name)
+		}
+
+		if role != "Pad" {
+			sub.X, sub.Y = draftAxisRange(p, evdev.ABS_X), draftAxisRange(p, evdev.ABS_Y)
+			if sub.X.empty() || sub.Y.empty() {
+				notef("%s %q: position ranges missing", p.Path, p.Name)
+			}
+		}
+		if role == "Pad" {
+			sub.Rings = draftAxisRanges(p, draftRingAxes)
+			sub.Strips = draftAxisRanges(p, draftStripAxes)
+			if len(sub.Rings) > 0 || len(sub.Strips) > 0 {
+				notef("%s %q: rings or strips found, add ModeGroup sections if the pad has mode toggle buttons", p.Path, p.Name)
+			}
+		}
+		if role == "Pen" {
+			sub.Pressure = draftAxisRange(p, evdev.ABS_PRESSURE)
+			sub.Distance = draftAxisRange(p, evdev.ABS_DISTANCE)
+			if act != nil {
+				if r, ok := act.Axes[evdev.ABS_DISTANCE]; ok && r.Max < sub.Distance.Max {
+					notef("%s %q: distance reported up to %d of %d, consider Distance=%d:%d",
+						p.Path, p.Name, r.Max, sub.Distance.Max, sub.Distance.Min, r.Max)
+				}
+				if act.PressureWithDistance {
+					desc.Quirks.ClearDistanceOnPressure = true
+				}
+			}
+		}
+
+		// Pressed keys, or all reported keys if nothing was recorded.
+		var keys []uint16
+		if act != nil {
+			keys = act.Keys
+		} else {
+			for _, t := range p.EventTypes {
+				if t.Type == evdev.EV_KEY {
+					for _, c := range t.Codes {
+						keys = append(keys, c.Code)
+					}
+				}
+			}
+		}
+		switch role {
+		case "Pen":
+			sub.Buttons = append(sub.Buttons, ButtonPenTip)
+			if containsCode(keys, evdev.BTN_TOOL_RUBBER) {
+				sub.Buttons = append(sub.Buttons, ButtonPenEraser)
+			}
+		case "Finger":
+			sub.Buttons = append(sub.Buttons, ButtonTouch)
+		}
+		var unsupported []string
+		for _, code := range keys {
+			if button, ok := buttonCodeTrans[code]; ok {
+				sub.Buttons = append(sub.Buttons, button)
+			} else if !draftImplicitKeys[code] {
+				unsupported = append(unsupported, p.code(evdev.EV_KEY, code).nameOr(evdev.KEY[int(code)]))
+			}
+		}
+		if len(unsupported) > 0 {
+			notef("%s %q: buttons %s are not supported by descriptors, a Driver is needed to use them",
+				p.Path, p.Name, strings.Join(unsupported, ", "))
+		}
+
+		var unused []string
+		for _, t := range p.EventTypes {
+			for _, c := range t.Codes {
+				padAxis := role == "Pad" && (containsCode(draftRingAxes, c.Code) || containsCode(draftStripAxes, c.Code))
+				if t.Type == evdev.EV_ABS && !draftUsedAxes[c.Code] && !padAxis || t.Type == evdev.EV_REL {
+					unused = append(unused, c.Name)
+				}
+			}
+		}
+		if len(unused) > 0 {
+			notef("%s %q: axes %s are not used by descriptors, a Driver is needed to use them",
+				p.Path, p.Name, strings.Join(unused, ", "))
+		}
+
+		if desc.WidthMillimeters == 0 && role != "Pad" {
+			desc.WidthMillimeters = draftMillimeters(p, evdev.ABS_X)
+			desc.HeightMillimeters = draftMillimeters(p, evdev.ABS_Y)
+		}
+	}
+
+	if desc.Pen != nil || desc.Finger != nil {
+		if desc.WidthMillimeters == 0 || desc.HeightMillimeters == 0 {
+			notef("pad size unknown as axis resolution is not reported, set WidthMillimeters and HeightMillimeters")
+		}
+	}
+	if len(probes) > 0 {
+		id := &probes[0].Identity
+		if id.Vendor != 0 || id.Product != 0 {
+			desc.Match = []DeviceMatch{{BusType: id.BusType, Vendor: id.Vendor, Product: id.Product}}
+		}
+	}
+	desc.Name = draftName(names)
+	return desc, notes
+}
+
+func (c *ProbeCode) nameOr(name string) string {
+	if c != nil {
+		return c.Name
+	}
+	return name
+}
+
+func draftAxisRange(p *InputNodeProbe, code uint16) AxisRange {
+	if c := p.code(evdev.EV_ABS, code); c != nil {
+		return AxisRange{Min: c.Abs.Min, Max: c.Abs.Max}
+	}
+	return AxisRange{}
+}
+
+// draftAxisRanges returns the ranges of axes in order until an axis is missing.
+func draftAxisRanges(p *InputNodeProbe, codes []uint16) []AxisRange {
+	var ranges []AxisRange
+	for _, code := range codes {
+		r := draftAxisRange(p, code)
+		if r.empty() {
+			break
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// draftMillimeters returns the length of an axis in millimeters, rounded to
+// tenths, or zero if its resolution is unknown.
+func draftMillimeters(p *InputNodeProbe, code uint16) float64 {
+	c := p.code(evdev.EV_ABS, code)
+	if c == nil || c.Abs.Resolution <= 0 {
+		return 0
+	}
+	return math.Round(float64(c.Abs.Max-c.Abs.Min)/float64(c.Abs.Resolution)*10) / 10
+}
+
+// draftName returns the common prefix of the names of the input nodes of a
+// device, e.g. "Wacom Bamboo 16FG 6x8" of "Wacom Bamboo 16FG 6x8 Pen" and
+// "Wacom Bamboo 16FG 6x8 Pad".
+func draftName(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	prefix := names[0]
+	for _, name := range names[1:] {
+		for !strings.HasPrefix(name, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	// Don't cut words.
+	for _, name := range names {
+		if len(name) > len(prefix) && name[len(prefix)] != ' ' {
+			if i := strings.LastIndexByte(prefix, ' '); i >= 0 {
+				prefix = prefix[:i]
+			} else {
+				prefix = ""
+			}
+			break
+		}
+	}
+	if prefix = strings.TrimSpace(prefix); prefix == "" {
+		return names[0]
+	}
+	return prefix
+}
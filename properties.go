@@ -68,8 +68,29 @@ const (
 	PropertyPadWidthMillimeters  Property = "pad-width-millimeters"  // Width of the pad along the X-axis.
 	PropertyPadHeightMillimeters Property = "pad-height-millimeters" // Width of the pad along the Y-axis.
 	PropertyPadWidthHeightRatio  Property = "pad-width-height-ratio"
+	PropertyBusType              Property = "bus-type"      // Bus the device is connected through, see BusType.
+	PropertyVendorID             Property = "vendor-id"     // Vendor ID of device.
+	PropertyProductID            Property = "product-id"    // Product ID of device.
+	PropertyVersion              Property = "version"       // Product version of device.
+	PropertyUniq                 Property = "uniq"          // Unique ID of device such as serial number, only present if reported.
+	PropertyStableID             Property = "stable-id"     // Identifier that is kept when replugging device, see DeviceIdentity.StableID.
+	PropertyModelName            Property = "model-name"    // Model name such as "PTH-660", from libwacom.
+	PropertyIntegratedIn         Property = "integrated-in" // "Display" and/or "System" if built into display or computer, from libwacom.
+	PropertyPadButtons           Property = "pad-buttons"   // Number of pad buttons, from libwacom.
+	PropertyRings                Property = "rings"         // Number of touch rings, from libwacom.
+	PropertyStrips               Property = "strips"        // Number of touch strips, from libwacom.
 )
 
+// padMillimeters returns the size of the pad in millimeters from properties.
+// False is returned if the size is not known.
+func padMillimeters(props Properties) (size Coord2D, ok bool) {
+	width, height := props[PropertyPadWidthMillimeters], props[PropertyPadHeightMillimeters]
+	if width == nil || height == nil || width.Number() <= 0 || height.Number() <= 0 {
+		return size, false
+	}
+	return Coord2D{X: float32(width.Number()), Y: float32(height.Number())}, true
+}
+
 // PropertyValue represents property values of different types.
 type PropertyValue interface {
 	// Type returns the property value type as a string, e.g. "string" or "number".
@@ -0,0 +1,200 @@
+package chimp
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/johan-bolmsjo/golang-evdev"
+)
+
+// inputReader waits on the file descriptors of all input devices of a logical
+// device with one epoll instance. Shutdown is signaled through an eventfd so
+// that no file is closed while it's being read.
+type inputReader struct {
+	epfd    int
+	eventfd int
+	sources []*inputSource
+	ready   []syscall.EpollEvent
+	groups  []inputEventGroup // Complete event groups of one wakeup.
+}
+
+// inputSource is an input device that events are read from.
+type inputSource struct {
+	inputDevice    *evdev.InputDevice
+	inputEventFunc inputEventFunc
+	index          int // Index of source in reader.
+
+	// Read events. Events of incomplete groups are kept at the front between reads.
+	events   []evdev.InputEvent
+	consumed int // Number of events in complete groups.
+}
+
+// inputEventGroup is a group of input events terminated by SYN_REPORT or
+// SYN_DROPPED.
+type inputEventGroup struct {
+	source *inputSource
+	events []evdev.InputEvent
+}
+
+const (
+	inputSourceEvents = 64
+	inputReaderWakeup = -1 // Epoll token of the eventfd.
+)
+
+func newInputReader(sources []*inputSource) (reader *inputReader, err error) {
+	reader = &inputReader{epfd: -1, eventfd: -1, sources: sources}
+	defer func() {
+		if err != nil {
+			reader.close()
+			reader = nil
+		}
+	}()
+
+	if reader.epfd, err = syscall.EpollCreate1(syscall.EPOLL_CLOEXEC); err != nil {
+		return
+	}
+	fd, _, errno := syscall.Syscall(syscall.SYS_EVENTFD2, 0, syscall.O_CLOEXEC|syscall.O_NONBLOCK, 0)
+	if errno != 0 {
+		err = errno
+		return
+	}
+	reader.eventfd = int(fd)
+
+	if err = reader.add(reader.eventfd, inputReaderWakeup); err != nil {
+		return
+	}
+	for i, source := range sources {
+		source.index = i
+		source.events = make([]evdev.InputEvent, 0, inputSourceEvents)
+		if err = reader.add(source.inputDevice.File.Sysfd(), int32(i)); err != nil {
+			return
+		}
+	}
+	reader.ready = make([]syscall.EpollEvent, len(sources)+1)
+	return
+}
+
+func (reader *inputReader) add(fd int, token int32) error {
+	event := syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: token}
+	return syscall.EpollCtl(reader.epfd, syscall.EPOLL_CTL_ADD, fd, &event)
+}
+
+// wait blocks until events are available from any source or timeout
+// milliseconds has passed and returns complete event groups ordered by
+// timestamp. A negative timeout waits indefinitely. Shutdown is true if wakeup
+// was called. The returned groups are valid until the next call.
+//
+// Only the groups read in one call are ordered. A group returned by a later
+// call may be older than groups already returned, if its source became
+// readable after the other sources were read.
+func (reader *inputReader) wait(timeout int) (groups []inputEventGroup, shutdown bool, err error) {
+	for _, source := range reader.sources {
+		source.compact()
+	}
+	reader.groups = reader.groups[:0]
+
+	n, err := syscall.EpollWait(reader.epfd, reader.ready, timeout)
+	if err != nil {
+		if err == syscall.EINTR {
+			err = nil
+		}
+		return nil, false, err
+	}
+
+	for _, v := range reader.ready[:n] {
+		if v.Fd == inputReaderWakeup {
+			return nil, true, nil
+		}
+		source := reader.sources[v.Fd]
+		if err = source.read(); err != nil {
+			return nil, false, newDeviceError("read", source.inputDevice.Fn, err)
+		}
+		reader.groups = source.appendGroups(reader.groups)
+	}
+
+	// Deliver event groups from different sources in timestamp order. Sorted
+	// by insertion as there are usually very few groups.
+	g := reader.groups
+	for i := 1; i < len(g); i++ {
+		for j := i; j > 0 && g[j].before(&g[j-1]); j-- {
+			g[j], g[j-1] = g[j-1], g[j]
+		}
+	}
+	return g, false, nil
+}
+
+// wakeup makes a blocked or future call to wait return with shutdown.
+func (reader *inputReader) wakeup() {
+	one := uint64(1)
+	syscall.Write(reader.eventfd, (*[8]byte)(unsafe.Pointer(&one))[:])
+}
+
+// close closes the epoll instance and the eventfd. Input devices are not closed.
+func (reader *inputReader) close() {
+	if reader.epfd >= 0 {
+		syscall.Close(reader.epfd)
+	}
+	if reader.eventfd >= 0 {
+		syscall.Close(reader.eventfd)
+	}
+}
+
+// read reads available events from the input device.
+func (source *inputSource) read() error {
+	if len(source.events) == cap(source.events) {
+		events := make([]evdev.InputEvent, len(source.events), 2*cap(source.events))
+		copy(events, source.events)
+		source.events = events
+	}
+
+	free := source.events[len(source.events):cap(source.events)]
+	n, err := syscall.Read(source.inputDevice.File.Sysfd(), inputEventBytes(free))
+	if err != nil {
+		if err == syscall.EAGAIN || err == syscall.EINTR {
+			return nil
+		}
+		return err
+	}
+	if n == 0 {
+		return syscall.ENODEV
+	}
+	source.events = source.events[:len(source.events)+n/inputEventSize]
+	return nil
+}
+
+// appendGroups appends complete event groups not yet consumed.
+func (source *inputSource) appendGroups(groups []inputEventGroup) []inputEventGroup {
+	start := source.consumed
+	for i := start; i < len(source.events); i++ {
+		v := &source.events[i]
+		if v.Type == evdev.EV_SYN && (v.Code == evdev.SYN_REPORT || v.Code == evdev.SYN_DROPPED) {
+			groups = append(groups, inputEventGroup{source: source, events: source.events[start : i+1]})
+			start = i + 1
+		}
+	}
+	source.consumed = start
+	return groups
+}
+
+// compact moves events of incomplete groups to the front.
+func (source *inputSource) compact() {
+	n := copy(source.events, source.events[source.consumed:])
+	source.events = source.events[:n]
+	source.consumed = 0
+}
+
+// before checks if group was generated before other group.
+func (group *inputEventGroup) before(other *inputEventGroup) bool {
+	a, b := &group.events[len(group.events)-1].Time, &other.events[len(other.events)-1].Time
+	return a.Sec < b.Sec || (a.Sec == b.Sec && a.Usec < b.Usec)
+}
+
+var inputEventSize = int(unsafe.Sizeof(evdev.InputEvent{}))
+
+// inputEventBytes returns the memory of events as a byte slice to read into.
+func inputEventBytes(events []evdev.InputEvent) []byte {
+	if len(events) == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(&events[0])), len(events)*inputEventSize)
+}
@@ -0,0 +1,161 @@
+package chimp
+
+import (
+	"reflect"
+	"syscall"
+	"testing"
+
+	evdev "github.com/johan-bolmsjo/golang-evdev"
+	"github.com/npat-efault/poller"
+)
+
+// newTestInputSource creates an input source reading from a pipe. Events
+// written with the returned function are read by the source.
+func newTestInputSource(t *testing.T, name string) (*inputSource, func(events ...evdev.InputEvent)) {
+	var fds [2]int
+	if err := syscall.Pipe2(fds[:], syscall.O_NONBLOCK|syscall.O_CLOEXEC); err != nil {
+		t.Fatal(err)
+	}
+	r, err := poller.NewFD(fds[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		r.Close()
+		syscall.Close(fds[1])
+	})
+
+	source := &inputSource{inputDevice: &evdev.InputDevice{Fn: name, File: r}}
+	write := func(events ...evdev.InputEvent) {
+		if _, err := syscall.Write(fds[1], inputEventBytes(events)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return source, write
+}
+
+func newTestInputReader(t *testing.T, sources ...*inputSource) *inputReader {
+	reader, err := newInputReader(sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(reader.close)
+	return reader
+}
+
+// waitGroups waits for event groups and returns the number of events of each.
+func waitGroups(t *testing.T, reader *inputReader) []int {
+	t.Helper()
+	groups, shutdown, err := reader.wait(1000)
+	if err != nil || shutdown {
+		t.Fatalf("wait: %t, %v", shutdown, err)
+	}
+	var sizes []int
+	for _, group := range groups {
+		sizes = append(sizes, len(group.events))
+	}
+	return sizes
+}
+
+func timedInputEvent(sec int64, typ, code uint16, value int32) evdev.InputEvent {
+	v := inputEvent(typ, code, value)
+	v.Time = syscall.Timeval{Sec: sec}
+	return v
+}
+
+func TestInputReaderGroups(t *testing.T) {
+	source, write := newTestInputSource(t, "pen")
+	reader := newTestInputReader(t, source)
+
+	// Groups end with SYN_REPORT or SYN_DROPPED, the incomplete group is kept.
+	write(
+		inputEvent(evdev.EV_ABS, evdev.ABS_X, 1),
+		inputEvent(evdev.EV_ABS, evdev.ABS_Y, 2),
+		inputEvent(evdev.EV_SYN, evdev.SYN_REPORT, 0),
+		inputEvent(evdev.EV_ABS, evdev.ABS_X, 3),
+		inputEvent(evdev.EV_SYN, evdev.SYN_DROPPED, 0),
+		inputEvent(evdev.EV_ABS, evdev.ABS_X, 4),
+	)
+	if got, want := waitGroups(t, reader), []int{3, 2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got groups %v, want %v", got, want)
+	}
+
+	// The incomplete group is moved to the front and completed.
+	write(inputEvent(evdev.EV_ABS, evdev.ABS_Y, 5), inputEvent(evdev.EV_SYN, evdev.SYN_REPORT, 0))
+	groups, _, err := reader.wait(1000)
+	if err != nil || len(groups) != 1 {
+		t.Fatalf("got %d groups, %v", len(groups), err)
+	}
+	if events := groups[0].events; len(events) != 3 || events[0].Value != 4 || &events[0] != &source.events[0] {
+		t.Errorf("incomplete group not compacted: %v", events)
+	}
+}
+
+func TestInputReaderLargeGroup(t *testing.T) {
+	source, write := newTestInputSource(t, "finger")
+	reader := newTestInputReader(t, source)
+
+	// A group larger than the buffer is read over several wakeups.
+	const n = 2*inputSourceEvents + 10
+	var events []evdev.InputEvent
+	for i := 0; i < n; i++ {
+		events = append(events, inputEvent(evdev.EV_ABS, evdev.ABS_MT_POSITION_X, int32(i)))
+	}
+	write(append(events, inputEvent(evdev.EV_SYN, evdev.SYN_REPORT, 0))...)
+
+	var sizes []int
+	for len(sizes) == 0 {
+		sizes = waitGroups(t, reader)
+	}
+	if want := []int{n + 1}; !reflect.DeepEqual(sizes, want) {
+		t.Fatalf("got groups %v, want %v", sizes, want)
+	}
+	for i, v := range source.events[:n] {
+		if v.Value != int32(i) {
+			t.Fatalf("event %d has value %d", i, v.Value)
+		}
+	}
+}
+
+func TestInputReaderOrder(t *testing.T) {
+	pen, writePen := newTestInputSource(t, "pen")
+	finger, writeFinger := newTestInputSource(t, "finger")
+	reader := newTestInputReader(t, pen, finger)
+
+	// Groups read in one wakeup are ordered by the time of their SYN_REPORT.
+	writePen(
+		timedInputEvent(3, evdev.EV_ABS, evdev.ABS_X, 1),
+		timedInputEvent(3, evdev.EV_SYN, evdev.SYN_REPORT, 0),
+		timedInputEvent(4, evdev.EV_ABS, evdev.ABS_X, 2),
+		timedInputEvent(4, evdev.EV_SYN, evdev.SYN_REPORT, 0),
+	)
+	writeFinger(
+		timedInputEvent(1, evdev.EV_ABS, evdev.ABS_X, 3),
+		timedInputEvent(2, evdev.EV_SYN, evdev.SYN_REPORT, 0),
+		timedInputEvent(3, evdev.EV_ABS, evdev.ABS_X, 4),
+		timedInputEvent(5, evdev.EV_SYN, evdev.SYN_REPORT, 0),
+	)
+	groups, _, err := reader.wait(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int32
+	for _, group := range groups {
+		got = append(got, group.events[0].Value)
+	}
+	if want := []int32{3, 1, 2, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got groups %v, want %v", got, want)
+	}
+	if groups[0].source != finger || groups[1].source != pen {
+		t.Error("groups not from their sources")
+	}
+}
+
+func TestInputReaderWakeup(t *testing.T) {
+	source, _ := newTestInputSource(t, "pen")
+	reader := newTestInputReader(t, source)
+	reader.wakeup()
+	if _, shutdown, err := reader.wait(1000); !shutdown || err != nil {
+		t.Errorf("wait after wakeup: %t, %v", shutdown, err)
+	}
+}
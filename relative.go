@@ -0,0 +1,196 @@
+package chimp
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// EventMotionPen is generated for movement of pen in relative mode, see
+// RelativeFilter. It replaces EventPositionPen when relative mode is enabled.
+type EventMotionPen struct {
+	Timestamp time.Time // Time when event was generated.
+	Delivered time.Time // Time when the source event was read from device, same clock as Timestamp.
+
+	// Time of the device clock when the source event was generated, see
+	// EventPositionPen.
+	DeviceTimestamp    time.Duration
+	HasDeviceTimestamp bool
+
+	Delta    Coord2D // Pen movement in the same scale as pen positions, with speed and acceleration applied.
+	Distance float32 // Distance of for example pen to tablet in range [0, 1], 0 is on tablet.
+}
+
+func (e *EventMotionPen) Time() time.Time {
+	return e.Timestamp
+}
+
+func (e *EventMotionPen) String() string {
+	return fmt.Sprintf(fmtEventMotionPen, e.Timestamp, e.Delta.X, e.Delta.Y, e.Distance)
+}
+
+const fmtEventMotionPen = `EventMotionPen: {
+    Time:     %s
+    DX:       %f
+    DY:       %f
+    Distance: %f
+}`
+
+// RelativeMode configures relative pen movement.
+type RelativeMode struct {
+	Enabled bool // Enable relative mode.
+
+	// Gain applied to pen movement, 1 moves the same distance as the pen.
+	Speed float32
+
+	// Additional gain per meter per second of pen speed. Zero disables
+	// acceleration.
+	Acceleration float32
+
+	// Press of any of these buttons toggles relative mode. The button events
+	// are consumed by the filter.
+	ToggleButtons []Button
+}
+
+// DefaultRelativeMode is a reasonable relative mode configuration. It's
+// disabled, it must be enabled or toggled by a button.
+var DefaultRelativeMode = RelativeMode{
+	Speed:        1,
+	Acceleration: 2,
+}
+
+// DefaultRelativeMaxGap is the default time between pen events after which
+// the pen is considered to have left proximity, for devices that don't report
+// EventProximityPen. The next pen event after a gap does not produce any
+// movement.
+const DefaultRelativeMaxGap = 50 * time.Millisecond
+
+// RelativeFilter is an event filter that converts absolute pen position events
+// to relative motion events, making the pen behave like a mouse. Lifting the
+// pen out of proximity and placing it somewhere else does not move anything.
+// The pen is known to have left proximity from EventProximityPen. Until the
+// first proximity event has been seen, a gap longer than MaxGap between pen
+// events is taken as the pen having left proximity. Once proximity is
+// reported the pen may rest for any time without losing movement. Proximity
+// events are passed through. The mode may be changed at any time from any
+// goroutine.
+type RelativeFilter struct {
+	// Maximum time between pen events while in proximity, only used until
+	// the first proximity event has been seen.
+	MaxGap time.Duration
+
+	scale Coord2D // Millimeters per normalized unit along each axis.
+
+	mu   sync.Mutex
+	mode RelativeMode
+
+	valid      bool // Previous position valid?
+	proximity  bool // Proximity reported by device, MaxGap not used.
+	timestamp  time.Time
+	deviceTime bool // Timestamp is device time.
+	coord      Coord2D
+}
+
+// NewRelativeFilter creates a relative mode filter for a device with the given
+// properties. The pad dimensions are read from PropertyPadWidthMillimeters and
+// PropertyPadHeightMillimeters to compute pen speed used for acceleration.
+// An error is returned if they are missing.
+func NewRelativeFilter(props Properties, mode RelativeMode) (*RelativeFilter, error) {
+	scale, ok := padMillimeters(props)
+	if !ok {
+		return nil, fmt.Errorf("relative mode requires properties %s and %s", PropertyPadWidthMillimeters, PropertyPadHeightMillimeters)
+	}
+	return &RelativeFilter{
+		MaxGap: DefaultRelativeMaxGap,
+		scale:  scale,
+		mode:   mode,
+	}, nil
+}
+
+// SetMode sets the relative mode configuration.
+func (f *RelativeFilter) SetMode(mode RelativeMode) {
+	f.mu.Lock()
+	f.mode = mode
+	f.mu.Unlock()
+}
+
+// Mode returns the relative mode configuration.
+func (f *RelativeFilter) Mode() RelativeMode {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.mode
+}
+
+// SetEnabled enables or disables relative mode.
+func (f *RelativeFilter) SetEnabled(enabled bool) {
+	f.mu.Lock()
+	f.mode.Enabled = enabled
+	f.mu.Unlock()
+}
+
+func (f *RelativeFilter) Filter(event Event) []Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch e := event.(type) {
+	case *EventPositionPen:
+		motion := f.motion(e)
+		if !f.mode.Enabled {
+			break
+		}
+		if motion == nil {
+			return nil
+		}
+		return []Event{motion}
+	case *EventProximityPen:
+		// Movement starts over when a tool enters, also if it left and
+		// entered again within MaxGap.
+		f.valid = false
+		f.proximity = true
+	case *EventButton:
+		for _, v := range f.mode.ToggleButtons {
+			if v == e.Button {
+				if e.Pressure > 0 {
+					f.mode.Enabled = !f.mode.Enabled
+				}
+				return nil
+			}
+		}
+	}
+	return []Event{event}
+}
+
+// motion tracks pen position and returns movement since the previous pen
+// event. Nil is returned if there is no previous position.
+func (f *RelativeFilter) motion(e *EventPositionPen) *EventMotionPen {
+	timestamp, deviceTime := motionTime(e.Timestamp, e.DeviceTimestamp, e.HasDeviceTimestamp)
+	dt := timestamp.Sub(f.timestamp)
+	valid := f.valid && deviceTime == f.deviceTime && dt >= 0 && (f.proximity || dt <= f.MaxGap)
+	prev := f.coord
+
+	f.valid = true
+	f.timestamp = timestamp
+	f.deviceTime = deviceTime
+	f.coord = e.Coord
+	if !valid {
+		return nil
+	}
+
+	delta := Coord2D{X: e.Coord.X - prev.X, Y: e.Coord.Y - prev.Y}
+	gain := f.mode.Speed
+	if f.mode.Acceleration != 0 && dt > 0 {
+		mm := math.Hypot(float64(delta.X*f.scale.X), float64(delta.Y*f.scale.Y))
+		metersPerSecond := mm / 1000 / dt.Seconds()
+		gain *= 1 + f.mode.Acceleration*float32(metersPerSecond)
+	}
+
+	return &EventMotionPen{
+		Timestamp:          e.Timestamp,
+		Delivered:          e.Delivered,
+		DeviceTimestamp:    e.DeviceTimestamp,
+		HasDeviceTimestamp: e.HasDeviceTimestamp,
+		Delta:              Coord2D{X: delta.X * gain, Y: delta.Y * gain},
+		Distance:           e.Distance,
+	}
+}
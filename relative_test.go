@@ -0,0 +1,158 @@
+package chimp
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testStart is the time that times of test event sequences are relative to.
+var testStart = time.Unix(1500000000, 0)
+
+func testTime(ms int) time.Time {
+	return testStart.Add(time.Duration(ms) * time.Millisecond)
+}
+
+// filterSequence passes events through filter in order and returns the output.
+func filterSequence(filter EventFilter, events []Event) []Event {
+	var out []Event
+	for _, event := range events {
+		out = append(out, filter.Filter(event)...)
+	}
+	return out
+}
+
+func penAt(ms int, x, y float32) *EventPositionPen {
+	return &EventPositionPen{Timestamp: testTime(ms), Coord: Coord2D{X: x, Y: y}, Tool: ButtonPenTip}
+}
+
+func proximityAt(ms int, in bool) *EventProximityPen {
+	return &EventProximityPen{Timestamp: testTime(ms), InProximity: in, Tool: ButtonPenTip}
+}
+
+// relativeSummary describes events passed through a relative filter.
+func relativeSummary(events []Event) []string {
+	var summary []string
+	for _, event := range events {
+		at := event.Time().Sub(testStart)
+		switch e := event.(type) {
+		case *EventMotionPen:
+			summary = append(summary, fmt.Sprintf("%s motion %.2f %.2f", at, e.Delta.X, e.Delta.Y))
+		case *EventPositionPen:
+			summary = append(summary, fmt.Sprintf("%s position %.2f %.2f", at, e.Coord.X, e.Coord.Y))
+		case *EventProximityPen:
+			summary = append(summary, fmt.Sprintf("%s proximity %t", at, e.InProximity))
+		case *EventButton:
+			summary = append(summary, fmt.Sprintf("%s button %s %g", at, e.Button, e.Pressure))
+		}
+	}
+	return summary
+}
+
+func TestRelativeFilter(t *testing.T) {
+	props := Properties{
+		PropertyPadWidthMillimeters:  PropertyValueNumber(200),
+		PropertyPadHeightMillimeters: PropertyValueNumber(100),
+	}
+	constant := RelativeMode{Enabled: true, Speed: 1}
+
+	for _, test := range []struct {
+		name   string
+		mode   RelativeMode
+		events []Event
+		want   []string
+	}{
+		{
+			"pen lifted and placed elsewhere",
+			constant,
+			[]Event{
+				proximityAt(0, true), penAt(0, 0.5, 0.5), penAt(10, 0.6, 0.55),
+				proximityAt(20, false), proximityAt(30, true), penAt(30, 0.1, 0.1), penAt(40, 0.2, 0.1),
+			},
+			[]string{
+				"0s proximity true", "10ms motion 0.10 0.05", "20ms proximity false",
+				"30ms proximity true", "40ms motion 0.10 0.00",
+			},
+		},
+		{
+			"pen resting in proximity",
+			constant,
+			[]Event{
+				proximityAt(0, true), penAt(0, 0.5, 0.5), penAt(10, 0.51, 0.5),
+				penAt(210, 0.52, 0.5), penAt(220, 0.53, 0.5),
+			},
+			[]string{"0s proximity true", "10ms motion 0.01 0.00", "210ms motion 0.01 0.00", "220ms motion 0.01 0.00"},
+		},
+		{
+			"gap without proximity events",
+			constant,
+			[]Event{penAt(0, 0.5, 0.5), penAt(10, 0.6, 0.5), penAt(100, 0.1, 0.1), penAt(110, 0.1, 0.2)},
+			[]string{"10ms motion 0.10 0.00", "110ms motion 0.00 0.10"},
+		},
+		{
+			"speed and acceleration",
+			// 20 mm in 10 ms is 2 m/s, gain is 0.5 * (1 + 2 * 2).
+			RelativeMode{Enabled: true, Speed: 0.5, Acceleration: 2},
+			[]Event{penAt(0, 0.5, 0.5), penAt(10, 0.6, 0.5)},
+			[]string{"10ms motion 0.25 0.00"},
+		},
+		{
+			"toggled by button",
+			RelativeMode{Speed: 1, ToggleButtons: []Button{ButtonPen2}},
+			[]Event{
+				penAt(0, 0.5, 0.5),
+				&EventButton{Timestamp: testTime(5), Button: ButtonPen2, Pressure: 1},
+				&EventButton{Timestamp: testTime(6), Button: ButtonPen2},
+				penAt(10, 0.6, 0.5),
+				&EventButton{Timestamp: testTime(15), Button: ButtonPen1, Pressure: 1},
+				&EventButton{Timestamp: testTime(20), Button: ButtonPen2, Pressure: 1},
+				penAt(30, 0.7, 0.5),
+			},
+			[]string{"0s position 0.50 0.50", "10ms motion 0.10 0.00", "15ms button Pen1 1", "30ms position 0.70 0.50"},
+		},
+	} {
+		f, err := NewRelativeFilter(props, test.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := relativeSummary(filterSequence(f, test.events)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRelativeFilterTimestamps(t *testing.T) {
+	f, err := NewRelativeFilter(Properties{
+		PropertyPadWidthMillimeters:  PropertyValueNumber(200),
+		PropertyPadHeightMillimeters: PropertyValueNumber(100),
+	}, RelativeMode{Enabled: true, Speed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Device timestamps take precedence over event timestamps when checking
+	// for gaps, and are passed on to motion events.
+	a, b := penAt(0, 0.5, 0.5), penAt(200, 0.6, 0.5)
+	a.DeviceTimestamp, b.DeviceTimestamp = time.Second, time.Second+10*time.Millisecond
+	a.HasDeviceTimestamp, b.HasDeviceTimestamp = true, true
+	b.Delivered = testTime(201)
+	out := filterSequence(f, []Event{a, b})
+	if len(out) != 1 {
+		t.Fatalf("got %v", out)
+	}
+	motion := out[0].(*EventMotionPen)
+	if motion.DeviceTimestamp != b.DeviceTimestamp || !motion.HasDeviceTimestamp || !motion.Delivered.Equal(b.Delivered) || !motion.Timestamp.Equal(b.Timestamp) {
+		t.Errorf("motion timestamps %s, %s, %s", motion.Timestamp, motion.Delivered, motion.DeviceTimestamp)
+	}
+	if latency, ok := EventLatency(motion); !ok || latency != time.Millisecond {
+		t.Errorf("motion latency %s, %t", latency, ok)
+	}
+
+	// Device time and event time are not comparable, movement starts over
+	// when device timestamps are no longer reported.
+	want := []string{"210ms motion 0.10 0.00"}
+	if got := relativeSummary(filterSequence(f, []Event{penAt(205, 0.7, 0.5), penAt(210, 0.8, 0.5)})); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
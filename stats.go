@@ -0,0 +1,181 @@
+package chimp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stats holds event statistics of a device. It can be used to detect that
+// events are lost because the application does not keep up.
+type Stats struct {
+	Sources        []SourceStats // Statistics of each source of events, e.g. Linux input device.
+	QueueCapacity  int           // Capacity of the event queue.
+	QueueHighWater int           // Largest number of queued events observed.
+	ReadLatency    LatencyStats  // Time from event generation until processed by the read loop.
+
+	// Events dropped by subscriptions with full queues, only set by
+	// EventHub.Stats.
+	SubscriptionDropped uint64
+}
+
+// SourceStats holds event statistics of one source of events.
+type SourceStats struct {
+	Name       string                   // Name of source.
+	Events     map[EventType]EventStats // Statistics per event type.
+	SynDropped uint64                   // Number of event buffer overruns reported by the source.
+}
+
+// EventStats holds counters of events of one type.
+type EventStats struct {
+	Produced  uint64 // Events generated from input.
+	Delivered uint64 // Events queued for reading.
+	Dropped   uint64 // Events dropped because the queue was full.
+	Coalesced uint64 // Events merged into later events by motion coalescing.
+}
+
+// LatencyStats holds statistics of a latency measurement.
+type LatencyStats struct {
+	Count uint64        // Number of measurements.
+	Last  time.Duration // Last measured latency.
+	Max   time.Duration // Largest measured latency.
+	Total time.Duration // Sum of all measured latencies.
+}
+
+// Mean returns the mean latency.
+func (stats *LatencyStats) Mean() time.Duration {
+	if stats.Count == 0 {
+		return 0
+	}
+	return stats.Total / time.Duration(stats.Count)
+}
+
+func (stats *LatencyStats) add(latency time.Duration) {
+	stats.Count++
+	stats.Last = latency
+	stats.Total += latency
+	if latency > stats.Max {
+		stats.Max = latency
+	}
+}
+
+func (stats *Stats) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Stats: {\n    Queue:   %d/%d\n    Latency: last %s, mean %s, max %s\n",
+		stats.QueueHighWater, stats.QueueCapacity, stats.ReadLatency.Last, stats.ReadLatency.Mean(), stats.ReadLatency.Max)
+	if stats.SubscriptionDropped > 0 {
+		fmt.Fprintf(&sb, "    Subscriptions dropped: %d\n", stats.SubscriptionDropped)
+	}
+
+	for _, source := range stats.Sources {
+		fmt.Fprintf(&sb, "    %q: {\n        SynDropped: %d\n", source.Name, source.SynDropped)
+
+		var types []EventType
+		for k := range source.Events {
+			types = append(types, k)
+		}
+		sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+		for _, k := range types {
+			v := source.Events[k]
+			fmt.Fprintf(&sb, "        %s: produced %d, delivered %d, dropped %d, coalesced %d\n",
+				k, v.Produced, v.Delivered, v.Dropped, v.Coalesced)
+		}
+		sb.WriteString("    }\n")
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+// eventStats collects event statistics. It's updated by the event producer and
+// read by the consumer.
+type eventStats struct {
+	mu    sync.Mutex
+	stats Stats
+}
+
+func newEventStats(sourceNames []string, queueCapacity int) *eventStats {
+	s := &eventStats{}
+	s.stats.QueueCapacity = queueCapacity
+	for _, name := range sourceNames {
+		s.stats.Sources = append(s.stats.Sources, SourceStats{
+			Name:   name,
+			Events: map[EventType]EventStats{},
+		})
+	}
+	return s
+}
+
+// update adds delta to the counters of event type from source. The delta is
+// passed by value as a function updating the counters would make them escape
+// to the heap on every event.
+func (s *eventStats) update(source int, event Event, delta EventStats) {
+	if source < 0 {
+		return
+	}
+	s.mu.Lock()
+	events := s.stats.Sources[source].Events
+	k := EventTypeOf(event)
+	v := events[k]
+	v.Produced += delta.Produced
+	v.Delivered += delta.Delivered
+	v.Dropped += delta.Dropped
+	v.Coalesced += delta.Coalesced
+	events[k] = v
+	s.mu.Unlock()
+}
+
+func (s *eventStats) produced(source int, events []Event) {
+	for _, event := range events {
+		if _, ok := event.(*eventError); !ok {
+			s.update(source, event, EventStats{Produced: 1})
+		}
+	}
+}
+
+func (s *eventStats) delivered(source int, event Event, queued int) {
+	s.update(source, event, EventStats{Delivered: 1})
+	s.mu.Lock()
+	if queued > s.stats.QueueHighWater {
+		s.stats.QueueHighWater = queued
+	}
+	s.mu.Unlock()
+}
+
+func (s *eventStats) dropped(source int, event Event) {
+	s.update(source, event, EventStats{Dropped: 1})
+}
+
+func (s *eventStats) coalesced(source int, event Event) {
+	s.update(source, event, EventStats{Coalesced: 1})
+}
+
+func (s *eventStats) synDropped(source int) {
+	s.mu.Lock()
+	s.stats.Sources[source].SynDropped++
+	s.mu.Unlock()
+}
+
+func (s *eventStats) readLatency(latency time.Duration) {
+	s.mu.Lock()
+	s.stats.ReadLatency.add(latency)
+	s.mu.Unlock()
+}
+
+// snapshot returns a copy of the statistics.
+func (s *eventStats) snapshot() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.stats
+	stats.Sources = make([]SourceStats, len(s.stats.Sources))
+	for i, source := range s.stats.Sources {
+		stats.Sources[i] = source
+		stats.Sources[i].Events = map[EventType]EventStats{}
+		for k, v := range source.Events {
+			stats.Sources[i].Events[k] = v
+		}
+	}
+	return stats
+}
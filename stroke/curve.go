@@ -0,0 +1,288 @@
+package stroke
+
+import (
+	"math"
+
+	"github.com/johan-bolmsjo/chimp"
+)
+
+// CubicBezier is a cubic Bézier curve segment. Pressure is interpolated along
+// the curve using the same basis as the coordinates.
+type CubicBezier struct {
+	P        [4]chimp.Coord2D // Start point, two control points and end point.
+	Pressure [4]float32       // Pressure at and between start and end point.
+}
+
+// Point evaluates the curve at t in range [0, 1].
+func (c *CubicBezier) Point(t float32) (coord chimp.Coord2D, pressure float32) {
+	u := 1 - t
+	b0, b1, b2, b3 := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+	coord.X = b0*c.P[0].X + b1*c.P[1].X + b2*c.P[2].X + b3*c.P[3].X
+	coord.Y = b0*c.P[0].Y + b1*c.P[1].Y + b2*c.P[2].Y + b3*c.P[3].Y
+	pressure = b0*c.Pressure[0] + b1*c.Pressure[1] + b2*c.Pressure[2] + b3*c.Pressure[3]
+	return
+}
+
+// CatmullRom returns a Catmull-Rom spline passing through all samples of the
+// stroke converted to cubic Bézier segments. Alpha selects the knot
+// parameterization, 0 is uniform, 0.5 centripetal and 1 chordal. Centripetal
+// is recommended as it avoids cusps and self intersections. Consecutive samples
+// with identical coordinates are merged.
+func CatmullRom(s *Stroke, alpha float32) []CubicBezier {
+	var pts []vec3
+	for i := range s.Samples {
+		v := sampleVec3(&s.Samples[i])
+		if len(pts) == 0 || !pts[len(pts)-1].xy().eq(v.xy()) {
+			pts = append(pts, v)
+		}
+	}
+	if len(pts) < 2 {
+		return nil
+	}
+
+	// Extrapolate end points so that the spline passes through all samples.
+	n := len(pts)
+	pts = append([]vec3{pts[0].scale(2).sub(pts[1])}, pts...)
+	pts = append(pts, pts[n].scale(2).sub(pts[n-1]))
+
+	var curves []CubicBezier
+	for i := 1; i < len(pts)-2; i++ {
+		p0, p1, p2, p3 := pts[i-1], pts[i], pts[i+1], pts[i+2]
+
+		// Knot intervals.
+		d01 := math.Pow(p0.xy().dist(p1.xy()), float64(alpha))
+		d12 := math.Pow(p1.xy().dist(p2.xy()), float64(alpha))
+		d23 := math.Pow(p2.xy().dist(p3.xy()), float64(alpha))
+
+		// Tangents scaled to the knot interval of the segment (Barry and Goldman).
+		m1 := p1.sub(p0).scale(1 / d01).sub(p2.sub(p0).scale(1 / (d01 + d12))).add(p2.sub(p1).scale(1 / d12)).scale(d12)
+		m2 := p2.sub(p1).scale(1 / d12).sub(p3.sub(p1).scale(1 / (d12 + d23))).add(p3.sub(p2).scale(1 / d23)).scale(d12)
+
+		curves = append(curves, newCubicBezier(p1, p1.add(m1.scale(1.0/3)), p2.sub(m2.scale(1.0/3)), p2))
+	}
+	return curves
+}
+
+// FitBezier fits cubic Bézier segments to the samples of the stroke using the
+// algorithm by Philip J. Schneider ("An Algorithm for Automatically Fitting
+// Digitized Curves", Graphics Gems, 1990). Tolerance is the maximum allowed
+// distance between a sample and the fitted curve. Pressure is linearly
+// interpolated between the end points of each segment.
+func FitBezier(s *Stroke, tolerance float32) []CubicBezier {
+	var pts []vec3
+	for i := range s.Samples {
+		v := sampleVec3(&s.Samples[i])
+		if len(pts) == 0 || !pts[len(pts)-1].xy().eq(v.xy()) {
+			pts = append(pts, v)
+		}
+	}
+	if len(pts) < 2 {
+		return nil
+	}
+
+	f := bezierFitter{pts: pts, errorSq: float64(tolerance) * float64(tolerance)}
+	n := len(pts)
+	f.fitCubic(0, n-1, pts[1].xy().sub(pts[0].xy()).unit(), pts[n-2].xy().sub(pts[n-1].xy()).unit())
+	return f.curves
+}
+
+const bezierFitMaxIterations = 4
+
+type bezierFitter struct {
+	pts     []vec3
+	errorSq float64
+	curves  []CubicBezier
+}
+
+func (f *bezierFitter) fitCubic(first, last int, tHat1, tHat2 vec2) {
+	p1, p2 := f.pts[first], f.pts[last]
+
+	if last-first == 1 {
+		d := p1.xy().dist(p2.xy()) / 3
+		f.addCurve(p1, p1.xy().add(tHat1.scale(d)), p2.xy().add(tHat2.scale(d)), p2)
+		return
+	}
+
+	u := f.chordLengthParameterize(first, last)
+	bez := f.generateBezier(first, last, u, tHat1, tHat2)
+	maxErr, split := f.computeMaxError(first, last, bez, u)
+	if maxErr < f.errorSq {
+		f.addCurve(p1, bez[1], bez[2], p2)
+		return
+	}
+
+	// Try to improve the parameterization if the error is not too large.
+	if maxErr < f.errorSq*4 {
+		for i := 0; i < bezierFitMaxIterations; i++ {
+			u = f.reparameterize(first, last, u, bez)
+			bez = f.generateBezier(first, last, u, tHat1, tHat2)
+			if maxErr, split = f.computeMaxError(first, last, bez, u); maxErr < f.errorSq {
+				f.addCurve(p1, bez[1], bez[2], p2)
+				return
+			}
+		}
+	}
+
+	// Fitting failed, split at point of maximum error and fit recursively.
+	tHatCenter := f.pts[split-1].xy().sub(f.pts[split+1].xy()).unit()
+	f.fitCubic(first, split, tHat1, tHatCenter)
+	f.fitCubic(split, last, tHatCenter.scale(-1), tHat2)
+}
+
+func (f *bezierFitter) addCurve(p0 vec3, c1, c2 vec2, p3 vec3) {
+	f.curves = append(f.curves, newCubicBezier(
+		p0,
+		vec3{c1[0], c1[1], p0[2] + (p3[2]-p0[2])/3},
+		vec3{c2[0], c2[1], p0[2] + (p3[2]-p0[2])*2/3},
+		p3,
+	))
+}
+
+// generateBezier uses least squares to find the control points of a Bézier
+// curve for the region.
+func (f *bezierFitter) generateBezier(first, last int, u []float64, tHat1, tHat2 vec2) [4]vec2 {
+	p0, p3 := f.pts[first].xy(), f.pts[last].xy()
+
+	var c [2][2]float64
+	var x [2]float64
+	for i := range u {
+		ui := u[i]
+		a1 := tHat1.scale(bernstein1(ui))
+		a2 := tHat2.scale(bernstein2(ui))
+
+		c[0][0] += a1.dot(a1)
+		c[0][1] += a1.dot(a2)
+		c[1][0] = c[0][1]
+		c[1][1] += a2.dot(a2)
+
+		tmp := f.pts[first+i].xy().sub(p0.scale(bernstein0(ui) + bernstein1(ui))).sub(p3.scale(bernstein2(ui) + bernstein3(ui)))
+		x[0] += a1.dot(tmp)
+		x[1] += a2.dot(tmp)
+	}
+
+	detC0C1 := c[0][0]*c[1][1] - c[1][0]*c[0][1]
+	detC0X := c[0][0]*x[1] - c[1][0]*x[0]
+	detXC1 := x[0]*c[1][1] - x[1]*c[0][1]
+
+	alphaL, alphaR := 0.0, 0.0
+	if detC0C1 != 0 {
+		alphaL = detXC1 / detC0C1
+		alphaR = detC0X / detC0C1
+	}
+
+	// Fall back on the Wu/Barsky heuristic if alpha is negative or too small.
+	segLength := p0.dist(p3)
+	epsilon := 1e-6 * segLength
+	if alphaL < epsilon || alphaR < epsilon {
+		d := segLength / 3
+		return [4]vec2{p0, p0.add(tHat1.scale(d)), p3.add(tHat2.scale(d)), p3}
+	}
+	return [4]vec2{p0, p0.add(tHat1.scale(alphaL)), p3.add(tHat2.scale(alphaR)), p3}
+}
+
+func (f *bezierFitter) reparameterize(first, last int, u []float64, bez [4]vec2) []float64 {
+	r := make([]float64, len(u))
+	for i := range u {
+		r[i] = newtonRaphsonRootFind(bez, f.pts[first+i].xy(), u[i])
+	}
+	return r
+}
+
+func (f *bezierFitter) computeMaxError(first, last int, bez [4]vec2, u []float64) (maxDist float64, split int) {
+	split = (last-first+1)/2 + first
+	for i := first + 1; i < last; i++ {
+		v := bezierPoint(bez[:], u[i-first]).sub(f.pts[i].xy())
+		if d := v.dot(v); d >= maxDist {
+			maxDist, split = d, i
+		}
+	}
+	return
+}
+
+func (f *bezierFitter) chordLengthParameterize(first, last int) []float64 {
+	u := make([]float64, last-first+1)
+	for i := first + 1; i <= last; i++ {
+		u[i-first] = u[i-first-1] + f.pts[i].xy().dist(f.pts[i-1].xy())
+	}
+	for i := range u {
+		u[i] /= u[len(u)-1]
+	}
+	return u
+}
+
+// newtonRaphsonRootFind improves the parameter u of point p on the curve.
+func newtonRaphsonRootFind(bez [4]vec2, p vec2, u float64) float64 {
+	var q1 [3]vec2
+	var q2 [2]vec2
+	for i := 0; i < 3; i++ {
+		q1[i] = bez[i+1].sub(bez[i]).scale(3)
+	}
+	for i := 0; i < 2; i++ {
+		q2[i] = q1[i+1].sub(q1[i]).scale(2)
+	}
+
+	qu := bezierPoint(bez[:], u)
+	q1u := bezierPoint(q1[:], u)
+	q2u := bezierPoint(q2[:], u)
+
+	d := qu.sub(p)
+	numerator := d.dot(q1u)
+	denominator := q1u.dot(q1u) + d.dot(q2u)
+	if denominator == 0 {
+		return u
+	}
+	return u - numerator/denominator
+}
+
+// bezierPoint evaluates a Bézier curve of any degree at t using de Casteljau's algorithm.
+func bezierPoint(ctrl []vec2, t float64) vec2 {
+	tmp := append([]vec2(nil), ctrl...)
+	for i := 1; i < len(tmp); i++ {
+		for j := 0; j < len(tmp)-i; j++ {
+			tmp[j] = tmp[j].scale(1 - t).add(tmp[j+1].scale(t))
+		}
+	}
+	return tmp[0]
+}
+
+func bernstein0(u float64) float64 { v := 1 - u; return v * v * v }
+func bernstein1(u float64) float64 { v := 1 - u; return 3 * u * v * v }
+func bernstein2(u float64) float64 { v := 1 - u; return 3 * u * u * v }
+func bernstein3(u float64) float64 { return u * u * u }
+
+func newCubicBezier(p0, p1, p2, p3 vec3) CubicBezier {
+	var c CubicBezier
+	for i, v := range [4]vec3{p0, p1, p2, p3} {
+		c.P[i] = chimp.Coord2D{X: float32(v[0]), Y: float32(v[1])}
+		c.Pressure[i] = float32(v[2])
+	}
+	return c
+}
+
+// Internal vector types with higher precision than the exported types.
+// vec3 holds a coordinate and pressure.
+type vec2 [2]float64
+type vec3 [3]float64
+
+func sampleVec3(s *Sample) vec3 {
+	return vec3{float64(s.Coord.X), float64(s.Coord.Y), float64(s.Pressure)}
+}
+
+func (v vec2) add(w vec2) vec2      { return vec2{v[0] + w[0], v[1] + w[1]} }
+func (v vec2) sub(w vec2) vec2      { return vec2{v[0] - w[0], v[1] - w[1]} }
+func (v vec2) scale(s float64) vec2 { return vec2{v[0] * s, v[1] * s} }
+func (v vec2) dot(w vec2) float64   { return v[0]*w[0] + v[1]*w[1] }
+func (v vec2) dist(w vec2) float64  { return math.Hypot(v[0]-w[0], v[1]-w[1]) }
+func (v vec2) eq(w vec2) bool       { return v[0] == w[0] && v[1] == w[1] }
+func (v vec3) xy() vec2             { return vec2{v[0], v[1]} }
+func (v vec3) add(w vec3) vec3      { return vec3{v[0] + w[0], v[1] + w[1], v[2] + w[2]} }
+func (v vec3) sub(w vec3) vec3      { return vec3{v[0] - w[0], v[1] - w[1], v[2] - w[2]} }
+func (v vec3) scale(s float64) vec3 { return vec3{v[0] * s, v[1] * s, v[2] * s} }
+
+func (v vec2) unit() vec2 {
+	l := math.Hypot(v[0], v[1])
+	if l == 0 {
+		return v
+	}
+	return v.scale(1 / l)
+}
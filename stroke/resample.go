@@ -0,0 +1,34 @@
+package stroke
+
+// Resample returns a copy of the stroke with samples placed at uniform arc
+// length spacing. Time and pressure are linearly interpolated between the
+// original samples. The first and last sample are always kept so the last
+// interval may be shorter than spacing.
+func Resample(s *Stroke, spacing float32) *Stroke {
+	r := &Stroke{Tool: s.Tool}
+	if len(s.Samples) == 0 || spacing <= 0 {
+		return s.Copy()
+	}
+
+	r.Samples = append(r.Samples, s.Samples[0])
+
+	// Distance left until the next resampled point.
+	next := float64(spacing)
+	for i := 1; i < len(s.Samples); i++ {
+		a, b := &s.Samples[i-1], &s.Samples[i]
+		segLen := distance(a.Coord, b.Coord)
+		pos := 0.0
+		for segLen-pos >= next {
+			pos += next
+			r.Samples = append(r.Samples, lerpSample(a, b, float32(pos/segLen)))
+			next = float64(spacing)
+		}
+		next -= segLen - pos
+	}
+
+	last := s.Samples[len(s.Samples)-1]
+	if len(s.Samples) > 1 && r.Samples[len(r.Samples)-1].Coord != last.Coord {
+		r.Samples = append(r.Samples, last)
+	}
+	return r
+}
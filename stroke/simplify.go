@@ -0,0 +1,66 @@
+package stroke
+
+import (
+	"math"
+
+	"github.com/johan-bolmsjo/chimp"
+)
+
+// Simplify returns a copy of the stroke simplified with the Ramer–Douglas–Peucker
+// algorithm. Samples deviating less than epsilon from the simplified polyline
+// are removed. The first and last sample are always kept.
+func Simplify(s *Stroke, epsilon float32) *Stroke {
+	n := len(s.Samples)
+	if n < 3 {
+		return s.Copy()
+	}
+
+	keep := make([]bool, n)
+	keep[0], keep[n-1] = true, true
+
+	// Explicit stack of index ranges instead of recursion to handle long strokes.
+	type span struct{ first, last int }
+	stack := []span{{0, n - 1}}
+	for len(stack) > 0 {
+		sp := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		maxDist, maxIndex := -1.0, -1
+		a, b := s.Samples[sp.first].Coord, s.Samples[sp.last].Coord
+		for i := sp.first + 1; i < sp.last; i++ {
+			if d := segmentDistance(s.Samples[i].Coord, a, b); d > maxDist {
+				maxDist, maxIndex = d, i
+			}
+		}
+		if maxIndex >= 0 && maxDist > float64(epsilon) {
+			keep[maxIndex] = true
+			stack = append(stack, span{sp.first, maxIndex}, span{maxIndex, sp.last})
+		}
+	}
+
+	r := &Stroke{Tool: s.Tool}
+	for i, v := range keep {
+		if v {
+			r.Samples = append(r.Samples, s.Samples[i])
+		}
+	}
+	return r
+}
+
+// segmentDistance returns the distance from p to the line segment [a, b].
+func segmentDistance(p, a, b chimp.Coord2D) float64 {
+	dx, dy := float64(b.X-a.X), float64(b.Y-a.Y)
+	px, py := float64(p.X-a.X), float64(p.Y-a.Y)
+	lenSq := dx*dx + dy*dy
+	if lenSq == 0 {
+		return math.Hypot(px, py)
+	}
+	t := (px*dx + py*dy) / lenSq
+	switch {
+	case t < 0:
+		t = 0
+	case t > 1:
+		t = 1
+	}
+	return math.Hypot(px-t*dx, py-t*dy)
+}
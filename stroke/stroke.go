@@ -0,0 +1,150 @@
+/*
+Package stroke contains utilities for processing pen strokes built from chimp
+events, e.g. resampling, simplification and curve fitting.
+
+All functions are deterministic, given the same input the same output is
+produced. Coordinates are processed in the space they are given in. Pen
+coordinates produced by chimp are normalized to [0, 1] on both axes and the
+pad is usually not square, use Stroke.Scale with the pad dimensions to get
+proportional distances.
+*/
+package stroke
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/johan-bolmsjo/chimp"
+)
+
+// Sample is one point of a stroke.
+type Sample struct {
+	Timestamp time.Time     // Time when sample was generated.
+	Coord     chimp.Coord2D // Pen position.
+	Pressure  float32       // Pen pressure in range [0, 1].
+}
+
+func (s *Sample) String() string {
+	return fmt.Sprintf("{%s %s %f}", s.Timestamp, &s.Coord, s.Pressure)
+}
+
+// Stroke is a sequence of samples from pen down to pen up.
+type Stroke struct {
+	Tool    chimp.Button // ButtonPenTip or ButtonPenEraser.
+	Samples []Sample
+}
+
+// Copy returns a deep copy of the stroke.
+func (s *Stroke) Copy() *Stroke {
+	return &Stroke{
+		Tool:    s.Tool,
+		Samples: append([]Sample(nil), s.Samples...),
+	}
+}
+
+// Scale returns a copy of the stroke with coordinates multiplied by sx and sy.
+func (s *Stroke) Scale(sx, sy float32) *Stroke {
+	r := s.Copy()
+	for i := range r.Samples {
+		r.Samples[i].Coord.X *= sx
+		r.Samples[i].Coord.Y *= sy
+	}
+	return r
+}
+
+// Length returns the arc length of the stroke.
+func (s *Stroke) Length() float32 {
+	var length float64
+	for i := 1; i < len(s.Samples); i++ {
+		length += distance(s.Samples[i-1].Coord, s.Samples[i].Coord)
+	}
+	return float32(length)
+}
+
+// Builder builds strokes from an event stream. A stroke is started when the
+// pen tip or eraser gets a positive pressure and ended when the pressure
+// returns to zero.
+type Builder struct {
+	stroke   *Stroke
+	coord    chimp.Coord2D
+	hasCoord bool
+}
+
+// Add event to builder. A completed stroke is returned when the pen is lifted
+// from the pad, nil is returned otherwise. Events not related to pen strokes
+// are ignored.
+func (b *Builder) Add(event chimp.Event) *Stroke {
+	switch e := event.(type) {
+	case *chimp.EventPositionPen:
+		b.coord = e.Coord
+		b.hasCoord = true
+		if b.stroke != nil {
+			pressure := float32(0)
+			if n := len(b.stroke.Samples); n > 0 {
+				last := &b.stroke.Samples[n-1]
+				pressure = last.Pressure
+				// Pressure and position changes from the same event group
+				// share timestamp, merge them into one sample.
+				if last.Timestamp.Equal(e.Timestamp) {
+					last.Coord = e.Coord
+					return nil
+				}
+			}
+			b.stroke.Samples = append(b.stroke.Samples, Sample{
+				Timestamp: e.Timestamp,
+				Coord:     e.Coord,
+				Pressure:  pressure,
+			})
+		}
+	case *chimp.EventButton:
+		if e.Button != chimp.ButtonPenTip && e.Button != chimp.ButtonPenEraser {
+			return nil
+		}
+		if e.Pressure == 0 {
+			return b.Flush()
+		}
+		if b.stroke == nil {
+			b.stroke = &Stroke{Tool: e.Button}
+		}
+		if b.hasCoord {
+			b.stroke.Samples = append(b.stroke.Samples, Sample{
+				Timestamp: e.Timestamp,
+				Coord:     b.coord,
+				Pressure:  e.Pressure,
+			})
+		}
+	}
+	return nil
+}
+
+// Flush ends any stroke in progress and returns it. Nil is returned if no
+// stroke was in progress.
+func (b *Builder) Flush() *Stroke {
+	s := b.stroke
+	b.stroke = nil
+	return s
+}
+
+func distance(a, b chimp.Coord2D) float64 {
+	return math.Hypot(float64(b.X-a.X), float64(b.Y-a.Y))
+}
+
+func lerp(a, b, t float32) float32 {
+	return a + (b-a)*t
+}
+
+func lerpTime(a, b time.Time, t float32) time.Time {
+	return a.Add(time.Duration(float64(b.Sub(a)) * float64(t)))
+}
+
+func lerpSample(a, b *Sample, t float32) Sample {
+	return Sample{
+		Timestamp: lerpTime(a.Timestamp, b.Timestamp, t),
+		Coord: chimp.Coord2D{
+			X: lerp(a.Coord.X, b.Coord.X, t),
+			Y: lerp(a.Coord.Y, b.Coord.Y, t),
+		},
+		Pressure: lerp(a.Pressure, b.Pressure, t),
+	}
+}
@@ -0,0 +1,194 @@
+package stroke
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johan-bolmsjo/chimp"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// readStroke reads a stroke from a file of samples, one per line with time in
+// milliseconds, coordinates and pressure.
+func readStroke(t *testing.T, name string) *Stroke {
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	start := time.Unix(1500000000, 0)
+	s := &Stroke{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var ms int
+		var sample Sample
+		if _, err := fmt.Sscan(line, &ms, &sample.Coord.X, &sample.Coord.Y, &sample.Pressure); err != nil {
+			t.Fatalf("malformed sample %q: %s", line, err)
+		}
+		sample.Timestamp = start.Add(time.Duration(ms) * time.Millisecond)
+		s.Samples = append(s.Samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// Values are formatted with fewer digits than float32 precision so that the
+// goldens don't depend on how the platform rounds intermediate results.
+
+func formatStroke(s *Stroke) string {
+	var b strings.Builder
+	start := s.Samples[0].Timestamp
+	for _, sample := range s.Samples {
+		fmt.Fprintf(&b, "%.3f %.5f %.5f %.5f\n",
+			float64(sample.Timestamp.Sub(start))/float64(time.Millisecond),
+			sample.Coord.X, sample.Coord.Y, sample.Pressure)
+	}
+	return b.String()
+}
+
+func formatCurves(curves []CubicBezier) string {
+	var b strings.Builder
+	for _, c := range curves {
+		for i := range c.P {
+			fmt.Fprintf(&b, "%.5f %.5f %.5f", c.P[i].X, c.P[i].Y, c.Pressure[i])
+			if i < len(c.P)-1 {
+				b.WriteString(" | ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// checkGolden compares output with testdata/name.golden. The golden file is
+// written instead when the test is run with -update.
+func checkGolden(t *testing.T, name, output string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(output), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != string(golden) {
+		t.Errorf("output differs from %s, got:\n%s", path, output)
+	}
+}
+
+func TestResample(t *testing.T) {
+	s := readStroke(t, "stroke.txt")
+	r := Resample(s, 0.05)
+	checkGolden(t, "resample", formatStroke(r))
+	if r.Samples[len(r.Samples)-1] != s.Samples[len(s.Samples)-1] {
+		t.Error("last sample not kept")
+	}
+	if got := formatStroke(Resample(s, 0.05)); got != formatStroke(r) {
+		t.Error("output not deterministic")
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	s := readStroke(t, "stroke.txt")
+	r := Simplify(s, 0.01)
+	checkGolden(t, "simplify", formatStroke(r))
+	if len(r.Samples) >= len(s.Samples) {
+		t.Errorf("simplified stroke has %d samples, original %d", len(r.Samples), len(s.Samples))
+	}
+}
+
+func TestCatmullRom(t *testing.T) {
+	s := readStroke(t, "stroke.txt")
+	curves := CatmullRom(s, 0.5)
+	checkGolden(t, "catmullrom", formatCurves(curves))
+
+	// The spline passes through every sample, the repeated one is merged.
+	if n := len(s.Samples) - 2; len(curves) != n {
+		t.Errorf("got %d curves, want %d", len(curves), n)
+	}
+}
+
+func TestFitBezier(t *testing.T) {
+	s := readStroke(t, "stroke.txt")
+	const tolerance = 0.005
+	curves := FitBezier(s, tolerance)
+	checkGolden(t, "fitbezier", formatCurves(curves))
+	if len(curves) == 0 || curves[0].P[0] != s.Samples[0].Coord || curves[len(curves)-1].P[3] != s.Samples[len(s.Samples)-1].Coord {
+		t.Error("curves don't start and end at the stroke end points")
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	pen := func(ms int, x, y float32) chimp.Event {
+		return &chimp.EventPositionPen{Timestamp: at(ms), Coord: chimp.Coord2D{X: x, Y: y}, Tool: chimp.ButtonPenTip}
+	}
+	button := func(ms int, b chimp.Button, pressure float32) chimp.Event {
+		return &chimp.EventButton{Timestamp: at(ms), Button: b, Pressure: pressure}
+	}
+
+	var b Builder
+	var strokes []*Stroke
+	for _, event := range []chimp.Event{
+		pen(0, 0.1, 0.1),                       // Hovering, no stroke.
+		button(10, chimp.ButtonPenTip, 0.5),    // Stroke started at the hover position.
+		pen(10, 0.2, 0.1),                      // Same event group, merged with the press.
+		pen(20, 0.3, 0.1),                      // Pressure of the previous sample.
+		button(25, chimp.ButtonPen1, 1),        // Not a stroke button.
+		button(30, chimp.ButtonPenTip, 0.8),    // Pressure change at the last position.
+		button(40, chimp.ButtonPenTip, 0),      // Stroke completed.
+		pen(50, 0.4, 0.2),                      // Hovering again.
+		button(60, chimp.ButtonPenEraser, 0.3), // Eraser stroke.
+	} {
+		if s := b.Add(event); s != nil {
+			strokes = append(strokes, s)
+		}
+	}
+	if s := b.Flush(); s != nil {
+		strokes = append(strokes, s)
+	}
+	if s := b.Flush(); s != nil {
+		t.Error("second flush returned a stroke")
+	}
+
+	want := []struct {
+		tool    chimp.Button
+		samples string
+	}{
+		{chimp.ButtonPenTip, "0.000 0.20000 0.10000 0.50000\n10.000 0.30000 0.10000 0.50000\n20.000 0.30000 0.10000 0.80000\n"},
+		{chimp.ButtonPenEraser, "0.000 0.40000 0.20000 0.30000\n"},
+	}
+	if len(strokes) != len(want) {
+		t.Fatalf("got %d strokes, want %d", len(strokes), len(want))
+	}
+	for i, s := range strokes {
+		if s.Tool != want[i].tool {
+			t.Errorf("stroke %d: tool %s, want %s", i, s.Tool, want[i].tool)
+		}
+		if got := formatStroke(s); got != want[i].samples {
+			t.Errorf("stroke %d: got samples\n%swant\n%s", i, got, want[i].samples)
+		}
+	}
+	if !strokes[0].Samples[0].Timestamp.Equal(at(10)) {
+		t.Errorf("stroke started at %s, want %s", strokes[0].Samples[0].Timestamp, at(10))
+	}
+}
@@ -0,0 +1,327 @@
+package chimp
+
+import (
+	"context"
+	"sync"
+)
+
+// EventHub reads events from a device and distributes them to any number of
+// subscribers. Every subscriber has its own queue and receives events in the
+// order they were read from the device.
+//
+// Every subscriber receives its own copy of events of types known by this
+// package, see CopyEvent, so that subscribers may change them, e.g. with
+// filters. Events of other types are shared and must not be changed.
+type EventHub struct {
+	dev    Device
+	cancel context.CancelFunc
+	done   chan struct{} // Closed when the reader goroutine has stopped.
+
+	mu      sync.Mutex
+	subs    map[*Subscription]struct{}
+	err     error  // Error that stopped the reader.
+	dropped uint64 // Events dropped by all subscriptions, including removed ones.
+}
+
+// NewEventHub creates an event hub and starts reading events from device.
+// The hub should be the only reader of the device.
+func NewEventHub(dev Device) *EventHub {
+	ctx, cancel := context.WithCancel(context.Background())
+	hub := &EventHub{
+		dev:    dev,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		subs:   map[*Subscription]struct{}{},
+	}
+	go hub.run(ctx)
+	return hub
+}
+
+func (hub *EventHub) run(ctx context.Context) {
+	defer close(hub.done)
+	for {
+		event, err := hub.dev.ReadContext(ctx)
+		if err != nil {
+			hub.mu.Lock()
+			if ctx.Err() == nil {
+				hub.err = err
+			}
+			for sub := range hub.subs {
+				sub.finish()
+			}
+			hub.subs = nil
+			hub.mu.Unlock()
+			return
+		}
+
+		hub.mu.Lock()
+		shared := true // The event read has not been given to a subscriber.
+		for sub := range hub.subs {
+			if sub.filter.match(event) {
+				e := event
+				if !shared {
+					e = CopyEvent(event)
+				}
+				shared = false
+				if !sub.push(e) {
+					hub.dropped++
+				}
+			}
+		}
+		hub.mu.Unlock()
+	}
+}
+
+// Err returns the error that stopped the hub from reading the device. Nil is
+// returned while the hub is running or if it was stopped by Close.
+func (hub *EventHub) Err() error {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	return hub.err
+}
+
+// Stats returns event statistics of the device, including events dropped by
+// subscriptions with full queues.
+func (hub *EventHub) Stats() Stats {
+	stats := hub.dev.Stats()
+	hub.mu.Lock()
+	stats.SubscriptionDropped = hub.dropped
+	hub.mu.Unlock()
+	return stats
+}
+
+// Close stops reading events from the device. The channels of all subscribers
+// are closed after any queued events have been received. The device is not
+// closed.
+func (hub *EventHub) Close() {
+	hub.cancel()
+	<-hub.done
+}
+
+// Subscribe adds a subscriber to the hub. The subscription channel is closed
+// if the hub stops reading events due to an error or Close. A closed channel
+// is returned if the hub has already stopped.
+func (hub *EventHub) Subscribe(opts SubscribeOptions) *Subscription {
+	if opts.QueueSize == 0 {
+		opts.QueueSize = DefaultSubscribeQueueSize
+	}
+	events := make(chan Event)
+	sub := &Subscription{
+		C:      events,
+		hub:    hub,
+		opts:   opts,
+		filter: opts.Filter.compile(),
+		events: events,
+		notify: make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.subs == nil {
+		close(events)
+		return sub
+	}
+	hub.subs[sub] = struct{}{}
+	go sub.run()
+	return sub
+}
+
+// SubscribeOptions holds options of a subscription.
+type SubscribeOptions struct {
+	Filter SubscribeFilter // Events to receive.
+
+	// Maximum number of queued events, DefaultSubscribeQueueSize if zero and
+	// unlimited if negative. An unlimited queue grows without bound if the
+	// subscriber does not keep up.
+	QueueSize int
+
+	DropPolicy DropPolicy // What to do when the queue is full.
+}
+
+// DefaultSubscribeQueueSize is the queue size of subscriptions that don't set
+// one. It holds about a second of events of a tablet.
+const DefaultSubscribeQueueSize = 256
+
+// SubscribeFilter selects events to receive. Empty lists match all events.
+// Position devices are only matched against position related events and
+// buttons only against button events.
+type SubscribeFilter struct {
+	EventTypes      []EventType
+	PositionDevices []PositionDevice
+	Buttons         []Button
+}
+
+type subscribeFilter struct {
+	eventTypes      map[EventType]bool
+	positionDevices map[PositionDevice]bool
+	buttons         map[Button]bool
+}
+
+func (filter *SubscribeFilter) compile() subscribeFilter {
+	var f subscribeFilter
+	if len(filter.EventTypes) > 0 {
+		f.eventTypes = map[EventType]bool{}
+		for _, v := range filter.EventTypes {
+			f.eventTypes[v] = true
+		}
+	}
+	if len(filter.PositionDevices) > 0 {
+		f.positionDevices = map[PositionDevice]bool{}
+		for _, v := range filter.PositionDevices {
+			f.positionDevices[v] = true
+		}
+	}
+	if len(filter.Buttons) > 0 {
+		f.buttons = map[Button]bool{}
+		for _, v := range filter.Buttons {
+			f.buttons[v] = true
+		}
+	}
+	return f
+}
+
+func (f *subscribeFilter) match(event Event) bool {
+	if f.eventTypes != nil && !f.eventTypes[EventTypeOf(event)] {
+		return false
+	}
+	if f.positionDevices != nil {
+		if device, ok := positionDeviceOf(event); ok && !f.positionDevices[device] {
+			return false
+		}
+	}
+	if f.buttons != nil {
+		if e, ok := event.(*EventButton); ok && !f.buttons[e.Button] {
+			return false
+		}
+	}
+	return true
+}
+
+// positionDeviceOf returns the position device that generated a position
+// related event.
+func positionDeviceOf(event Event) (PositionDevice, bool) {
+	switch event.(type) {
+	case *EventPositionPen, *EventMotionPen:
+		return PositionDevicePen, true
+	case *EventPositionFinger, *EventGesture:
+		return PositionDeviceFinger, true
+	}
+	return 0, false
+}
+
+// DropPolicy is an enumeration of what to do with events when a queue is full.
+type DropPolicy int
+
+//go:generate stringer -type=DropPolicy -trimprefix=DropPolicy
+
+const (
+	DropPolicyNewest DropPolicy = iota // Drop the event that did not fit.
+	DropPolicyOldest                   // Drop the oldest queued event.
+)
+
+// Subscription receives events from an event hub.
+type Subscription struct {
+	C <-chan Event // Events are received from this channel.
+
+	hub    *EventHub
+	opts   SubscribeOptions
+	filter subscribeFilter
+	events chan<- Event
+
+	mu       sync.Mutex
+	queue    []Event
+	dropped  uint64
+	finished bool          // No more events will be queued.
+	notify   chan struct{} // Signals that the queue or finished has changed.
+	stop     chan struct{} // Closed to stop delivery immediately.
+	stopOnce sync.Once
+}
+
+// Dropped returns the number of events dropped because the queue was full.
+func (sub *Subscription) Dropped() uint64 {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	return sub.dropped
+}
+
+// Unsubscribe stops the subscription. The subscription channel is closed
+// without delivering any queued events.
+func (sub *Subscription) Unsubscribe() {
+	sub.hub.mu.Lock()
+	if sub.hub.subs != nil {
+		delete(sub.hub.subs, sub)
+	}
+	sub.hub.mu.Unlock()
+	sub.stopOnce.Do(func() { close(sub.stop) })
+}
+
+// push queues event according to the drop policy. False is returned if an
+// event was dropped.
+func (sub *Subscription) push(event Event) (queued bool) {
+	queued = true
+	sub.mu.Lock()
+	if sub.opts.QueueSize > 0 && len(sub.queue) >= sub.opts.QueueSize {
+		sub.dropped++
+		queued = false
+		if sub.opts.DropPolicy == DropPolicyNewest {
+			sub.mu.Unlock()
+			return
+		}
+		sub.queue[0] = nil
+		sub.queue = sub.queue[1:]
+	}
+	sub.queue = append(sub.queue, event)
+	sub.mu.Unlock()
+	sub.signal()
+	return
+}
+
+// finish marks that no more events will be queued.
+func (sub *Subscription) finish() {
+	sub.mu.Lock()
+	sub.finished = true
+	sub.mu.Unlock()
+	sub.signal()
+}
+
+func (sub *Subscription) signal() {
+	select {
+	case sub.notify <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers queued events to the subscription channel.
+func (sub *Subscription) run() {
+	defer close(sub.events)
+	for {
+		sub.mu.Lock()
+		var event Event
+		if len(sub.queue) > 0 {
+			event = sub.queue[0]
+			sub.queue[0] = nil
+			sub.queue = sub.queue[1:]
+		}
+		finished := sub.finished
+		sub.mu.Unlock()
+
+		if event == nil {
+			if finished {
+				return
+			}
+			select {
+			case <-sub.notify:
+				continue
+			case <-sub.stop:
+				return
+			}
+		}
+
+		select {
+		case sub.events <- event:
+		case <-sub.stop:
+			return
+		}
+	}
+}
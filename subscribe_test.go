@@ -0,0 +1,110 @@
+package chimp
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testDevice is a device that returns events sent on its channel.
+type testDevice struct {
+	events chan Event
+}
+
+func newTestDevice() *testDevice {
+	return &testDevice{events: make(chan Event)}
+}
+
+func (dev *testDevice) Properties() Properties      { return Properties{} }
+func (dev *testDevice) Capabilities() *Capabilities { return &Capabilities{} }
+func (dev *testDevice) Stats() Stats                { return Stats{} }
+func (dev *testDevice) Clock() Clock                { return ClockRealtime }
+func (dev *testDevice) Close()                      {}
+
+func (dev *testDevice) Read() (Event, error) {
+	return dev.ReadContext(context.Background())
+}
+
+func (dev *testDevice) ReadContext(ctx context.Context) (Event, error) {
+	select {
+	case event, ok := <-dev.events:
+		if !ok {
+			return nil, ErrClosed
+		}
+		return event, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (dev *testDevice) ReadBatch(ctx context.Context, buf []Event) (int, error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	event, err := dev.ReadContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	buf[0] = event
+	return 1, nil
+}
+
+func receive(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+	select {
+	case event := <-sub.C:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+		return nil
+	}
+}
+
+func TestEventHubCopiesEvents(t *testing.T) {
+	dev := newTestDevice()
+	hub := NewEventHub(dev)
+	defer hub.Close()
+	subs := []*Subscription{hub.Subscribe(SubscribeOptions{}), hub.Subscribe(SubscribeOptions{})}
+
+	dev.events <- &EventButton{Button: ButtonPen1, Pressure: 1}
+	a := receive(t, subs[0]).(*EventButton)
+	b := receive(t, subs[1]).(*EventButton)
+	if a == b {
+		t.Fatal("subscribers share event")
+	}
+	a.Button = ButtonPen2
+	if b.Button != ButtonPen1 {
+		t.Fatal("change of event seen by other subscriber")
+	}
+}
+
+func TestEventHubDrops(t *testing.T) {
+	dev := newTestDevice()
+	hub := NewEventHub(dev)
+	defer hub.Close()
+	sub := hub.Subscribe(SubscribeOptions{QueueSize: 1, DropPolicy: DropPolicyOldest})
+	if sub.opts.QueueSize != 1 {
+		t.Fatal("queue size not kept")
+	}
+	if def := hub.Subscribe(SubscribeOptions{}); def.opts.QueueSize != DefaultSubscribeQueueSize {
+		t.Fatalf("default queue size %d", def.opts.QueueSize)
+	}
+
+	// Nothing is received while events are sent, so the queue overflows.
+	// One event may be held by the delivery goroutine in addition to the
+	// queue.
+	const sent = 10
+	for i := 0; i < sent; i++ {
+		dev.events <- &EventButton{Timestamp: time.Unix(int64(i), 0)}
+	}
+	// The hub has handled the last event when the device is read again.
+	dev.events <- &EventButton{Timestamp: time.Unix(sent, 0)}
+	hub.Close()
+
+	if dropped := sub.Dropped(); dropped < sent-2 {
+		t.Fatalf("dropped %d events, want at least %d", dropped, sent-2)
+	}
+	if stats := hub.Stats(); stats.SubscriptionDropped != sub.Dropped() {
+		t.Fatalf("hub dropped %d events, subscription %d", stats.SubscriptionDropped, sub.Dropped())
+	}
+}
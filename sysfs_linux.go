@@ -0,0 +1,59 @@
+package chimp
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sysfsInputInfo is information about an input device read from sysfs. It's
+// available without permission to open the device node.
+type sysfsInputInfo struct {
+	name, phys string
+	identity   DeviceIdentity
+}
+
+// Root of input class devices in sysfs.
+var sysfsInputRoot = "/sys/class/input"
+
+// readSysfsInputInfo reads information about the input device of a device node
+// such as /dev/input/event3.
+func readSysfsInputInfo(devnode string) (info sysfsInputInfo, err error) {
+	dir := filepath.Join(sysfsInputRoot, filepath.Base(devnode), "device")
+	if info.name, err = readSysfsString(filepath.Join(dir, "name")); err != nil {
+		return
+	}
+	// Not all devices have a physical location or unique ID.
+	info.phys, _ = readSysfsString(filepath.Join(dir, "phys"))
+	info.identity.Uniq, _ = readSysfsString(filepath.Join(dir, "uniq"))
+
+	for _, v := range []struct {
+		name  string
+		value *uint16
+	}{
+		{"bustype", (*uint16)(&info.identity.BusType)},
+		{"vendor", &info.identity.Vendor},
+		{"product", &info.identity.Product},
+		{"version", &info.identity.Version},
+	} {
+		var s string
+		if s, err = readSysfsString(filepath.Join(dir, "id", v.name)); err != nil {
+			return
+		}
+		var n uint64
+		if n, err = strconv.ParseUint(s, 16, 16); err != nil {
+			return
+		}
+		*v.value = uint16(n)
+	}
+	return
+}
+
+func readSysfsString(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\n"), nil
+}
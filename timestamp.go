@@ -0,0 +1,92 @@
+package chimp
+
+import "time"
+
+// deviceTimestamp extends the 32-bit microsecond counter reported by devices
+// with MSC_TIMESTAMP to a duration that does not wrap around.
+//
+// The counter wraps around after about 71 minutes and is reset by some devices
+// when they are reinitialized. The kernel time of the events is used to detect
+// when the counter can't be trusted, e.g. after a reset or when no events have
+// been reported for longer than a wraparound period, and time is then advanced
+// by the kernel time instead.
+type deviceTimestamp struct {
+	valid   bool
+	raw     uint32        // Last counter value.
+	kernel  time.Time     // Kernel time of last counter value.
+	value   time.Duration // Extended counter value.
+	updated bool          // Counter updated since last take.
+}
+
+// Largest accepted difference between time advanced by the device counter and
+// the kernel time.
+const deviceTimestampMaxDrift = time.Second
+
+// update records a counter value reported at kernel time.
+func (ts *deviceTimestamp) update(raw int32, kernel time.Time) {
+	counter := uint32(raw)
+	if ts.valid {
+		delta := time.Duration(counter-ts.raw) * time.Microsecond
+		kernelDelta := kernel.Sub(ts.kernel)
+		if drift := delta - kernelDelta; kernelDelta >= 0 && (drift > deviceTimestampMaxDrift || drift < -deviceTimestampMaxDrift) {
+			delta = kernelDelta
+		}
+		ts.value += delta
+	} else {
+		ts.value = time.Duration(counter) * time.Microsecond
+	}
+	ts.valid = true
+	ts.raw = counter
+	ts.kernel = kernel
+	ts.updated = true
+}
+
+// take returns the device time and true if the counter was updated since the
+// last call. False is returned if no counter value was reported in between.
+func (ts *deviceTimestamp) take() (time.Duration, bool) {
+	if !ts.updated {
+		return 0, false
+	}
+	ts.updated = false
+	return ts.value, true
+}
+
+// setDeviceTimestamp sets the device timestamp of events and marks it as reported.
+func setDeviceTimestamp(events []Event, deviceTimestamp time.Duration) {
+	for _, event := range events {
+		switch e := event.(type) {
+		case *EventPositionPen:
+			e.DeviceTimestamp = deviceTimestamp
+			e.HasDeviceTimestamp = true
+		case *EventPositionFinger:
+			e.DeviceTimestamp = deviceTimestamp
+			e.HasDeviceTimestamp = true
+		case *EventButton:
+			e.DeviceTimestamp = deviceTimestamp
+			e.HasDeviceTimestamp = true
+		case *EventPadRing:
+			e.DeviceTimestamp = deviceTimestamp
+			e.HasDeviceTimestamp = true
+		case *EventPadStrip:
+			e.DeviceTimestamp = deviceTimestamp
+			e.HasDeviceTimestamp = true
+		case *EventMotionPen:
+			e.DeviceTimestamp = deviceTimestamp
+			e.HasDeviceTimestamp = true
+		case *EventProximityPen:
+			e.DeviceTimestamp = deviceTimestamp
+			e.HasDeviceTimestamp = true
+		}
+	}
+}
+
+// motionTime returns the time to use when computing movement over time and
+// true if it's device time. The device timestamp is preferred as it's not
+// affected by USB batching jitter. Device time and event time have different
+// epochs, movement must be restarted when the time source changes.
+func motionTime(timestamp time.Time, deviceTimestamp time.Duration, hasDeviceTimestamp bool) (time.Time, bool) {
+	if hasDeviceTimestamp {
+		return time.Unix(0, 0).Add(deviceTimestamp), true
+	}
+	return timestamp, false
+}
@@ -0,0 +1,37 @@
+package chimp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeviceTimestampTake(t *testing.T) {
+	var ts deviceTimestamp
+	if _, ok := ts.take(); ok {
+		t.Fatal("timestamp taken before update")
+	}
+
+	// A counter value of zero is a valid device time.
+	kernel := time.Unix(1500000000, 0)
+	ts.update(0, kernel)
+	if v, ok := ts.take(); !ok || v != 0 {
+		t.Fatalf("take = %s, %t, want 0, true", v, ok)
+	}
+	if _, ok := ts.take(); ok {
+		t.Fatal("timestamp taken twice")
+	}
+
+	// The counter wraps around.
+	last := time.Duration(1<<32-1) * time.Microsecond
+	ts.update(-1, kernel.Add(last))
+	ts.update(999, kernel.Add(last+time.Millisecond))
+	if v, _ := ts.take(); v != last+time.Millisecond {
+		t.Fatalf("take after wraparound = %s, want %s", v, last+time.Millisecond)
+	}
+
+	// The kernel time is used when the counter is reset.
+	ts.update(0, kernel.Add(last+time.Second+time.Millisecond))
+	if v, _ := ts.take(); v != last+time.Second+time.Millisecond {
+		t.Fatalf("take after reset = %s, want %s", v, last+time.Second+time.Millisecond)
+	}
+}